@@ -20,9 +20,9 @@ import (
 	"net"
 	"os"
 
+	"github.com/Raghav242/adapter-template/pkg/adapter"
 	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
 	"github.com/sgnl-ai/adapter-framework/server"
-	"github.com/sgnl-ai/adapter-template/pkg/adapter"
 	"google.golang.org/grpc"
 )
 
@@ -32,6 +32,15 @@ var (
 
 	// Timeout is the timeout for the HTTP client used to make requests to the datasource (seconds).
 	Timeout = flag.Int("timeout", 30, "The timeout for the HTTP client used to make requests to the datasource (seconds)")
+
+	// MaxIdleConns is the maximum number of idle connections to all datasources.
+	MaxIdleConns = flag.Int("max_idle_conns", adapter.DefaultMaxIdleConns, "The maximum number of idle connections to all datasources")
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections to each datasource.
+	MaxIdleConnsPerHost = flag.Int("max_idle_conns_per_host", adapter.DefaultMaxIdleConnsPerHost, "The maximum number of idle connections to each datasource")
+
+	// IdleConnTimeout is the time after which idle connections to datasources are closed.
+	IdleConnTimeout = flag.Duration("idle_conn_timeout", adapter.DefaultIdleConnTimeout, "The time after which idle connections to datasources are closed")
 )
 
 func main() {
@@ -56,7 +65,13 @@ func main() {
 	// type configured on the Adapter object via the SGNL Config API.
 	//
 	// If you need to run multiple adapters on the same gRPC server, they can be registered here.
-	err = server.RegisterAdapter(adapterServer, "Test-1.0.0", adapter.NewAdapter(adapter.NewClient(*Timeout)))
+	client := adapter.NewClientWithPool(*Timeout, adapter.PoolConfig{
+		MaxIdleConns:        *MaxIdleConns,
+		MaxIdleConnsPerHost: *MaxIdleConnsPerHost,
+		IdleConnTimeout:     *IdleConnTimeout,
+	})
+
+	err = server.RegisterAdapter(adapterServer, "Test-1.0.0", adapter.NewAdapter(client))
 	if err != nil {
 		logger.Fatalf("Failed to register adapter: %v", err)
 	}
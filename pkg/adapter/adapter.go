@@ -15,10 +15,9 @@ package adapter
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	framework "github.com/sgnl-ai/adapter-framework"
@@ -26,6 +25,12 @@ import (
 	"github.com/sgnl-ai/adapter-framework/web"
 )
 
+const (
+	// DefaultAPIBaseURL is the base URL of the datasource's API if neither the
+	// config nor the request set one.
+	DefaultAPIBaseURL = "https://api.pagerduty.com"
+)
+
 // Adapter implements the framework.Adapter interface to query pages of objects
 // from datasources.
 type Adapter struct {
@@ -33,27 +38,124 @@ type Adapter struct {
 
 	// Client provides access to the datasource.
 	Client Client
+
+	// limiter bounds the number of pages requested concurrently from the
+	// datasource. Pages are not limited if nil.
+	limiter *Limiter
+
+	// idNormalizers maps entity external IDs to the functions normalizing the
+	// unique IDs of their objects.
+	idNormalizers map[string]IDNormalizer
+
+	// logger logs the adapter's diagnostic messages.
+	logger Logger
+}
+
+// IDNormalizer returns the canonical form of an object's unique ID, e.g.
+// strings.ToLower for case-insensitive email addresses.
+type IDNormalizer func(id string) string
+
+// Option configures an Adapter created with NewAdapter.
+type Option func(*Adapter)
+
+// WithLimiter sets the limiter bounding the number of pages requested
+// concurrently by the adapter. The limiter may be shared with other adapters
+// to enforce a global concurrency budget towards the same datasource.
+// By default, each adapter has its own limiter of MaxConcurrentPages.
+func WithLimiter(limiter *Limiter) Option {
+	return func(a *Adapter) {
+		a.limiter = limiter
+	}
+}
+
+// WithIDNormalizer sets the function normalizing the unique IDs of the objects
+// of the given entity, so that IDs are canonical and stable across syncs.
+// By default, unique IDs are returned as sent by the datasource.
+func WithIDNormalizer(entityExternalID string, normalizer IDNormalizer) Option {
+	return func(a *Adapter) {
+		if a.idNormalizers == nil {
+			a.idNormalizers = make(map[string]IDNormalizer)
+		}
+
+		a.idNormalizers[entityExternalID] = normalizer
+	}
+}
+
+// WithLogger sets the logger of the adapter's diagnostic messages, e.g. the
+// attributes skipped for the configured API version.
+// By default, messages are discarded.
+func WithLogger(logger Logger) Option {
+	return func(a *Adapter) {
+		a.logger = loggerOrNoop(logger)
+	}
 }
 
 // NewAdapter instantiates a new Adapter.
 //
 // SCAFFOLDING #21 - pkg/adapter/adapter.go: Add or remove parameters to match field updates above.
-func NewAdapter(client Client) framework.Adapter[Config] {
-	return &Adapter{
-		Client: client,
+func NewAdapter(client Client, opts ...Option) framework.Adapter[Config] {
+	adapter := &Adapter{
+		Client:  client,
+		limiter: NewLimiter(MaxConcurrentPages),
+		logger:  noopLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(adapter)
 	}
+
+	return adapter
+}
+
+// log returns the Adapter's Logger, or one discarding all messages, e.g. for
+// an Adapter not created with NewAdapter.
+func (a *Adapter) log() Logger {
+	return loggerOrNoop(a.logger)
 }
 
 // GetPage is called by SGNL's ingestion service to query a page of objects
 // from a datasource.
 func (a *Adapter) GetPage(ctx context.Context, request *framework.Request[Config]) framework.Response {
+	// Skip the attributes that don't exist in the configured API version.
+	if request.Config != nil {
+		supported := *request
+		supported.Entity.Attributes = supportedAttributes(&request.Entity, request.Config, a.log())
+		request = &supported
+	}
+
 	if err := a.ValidateGetPageRequest(ctx, request); err != nil {
-		return framework.NewGetPageResponseError(err)
+		return framework.NewGetPageResponseError(redactError(err, requestSecrets(request)...))
 	}
 
 	return a.RequestPageFromDatasource(ctx, request)
 }
 
+// requestPage requests a page from the datasource once the adapter's limiter
+// allows it. Each request to the datasource, including those for child
+// entities, holds its own slot of the limiter only while it is in flight.
+func (a *Adapter) requestPage(ctx context.Context, req *Request) (*Response, *framework.Error) {
+	if a.limiter != nil {
+		if err := a.limiter.Acquire(ctx); err != nil {
+			return nil, cancelledError(ctx)
+		}
+		defer a.limiter.Release()
+	}
+
+	return a.Client.GetPage(ctx, req)
+}
+
+// ValidateConnection checks that the given request is valid and that the
+// datasource can be reached and authenticated to with it, by requesting the
+// first page of a single object, which is discarded.
+// Returns nil if the datasource returned the page successfully.
+func (a *Adapter) ValidateConnection(ctx context.Context, request *framework.Request[Config]) *framework.Error {
+	probe := *request
+	probe.PageSize = 1
+	probe.Cursor = ""
+
+	return a.GetPage(ctx, &probe).Error
+}
+
 // RequestPageFromDatasource requests a page of objects from a datasource.
 func (a *Adapter) RequestPageFromDatasource(
 	ctx context.Context, request *framework.Request[Config],
@@ -63,99 +165,316 @@ func (a *Adapter) RequestPageFromDatasource(
 	// If necessary, update this entire method to query your SoR. All of the code in this function
 	// can be updated to match your SoR requirements.
 
-	apiURL := "https://api.pagerduty.com/teams"
-
-	// Create HTTP request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
+	entity, _, found := lookupEntity(request.Config, request.Entity.ExternalId)
+	if !found {
 		return framework.NewGetPageResponseError(
 			&framework.Error{
-				Message: fmt.Sprintf("Failed to create request: %v", err),
-				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				Message: fmt.Sprintf("Invalid entity external ID: %s.", request.Entity.ExternalId),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
 			},
 		)
 	}
 
-	// Set required headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Token token="+request.Auth.HTTPAuthorization)
+	req := newDatasourceRequest(request, entity)
 
-	// Perform the request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := a.requestPage(ctx, req)
 	if err != nil {
-		return framework.NewGetPageResponseError(
-			&framework.Error{
-				Message: fmt.Sprintf("Failed to perform request: %v", err),
-				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
-			},
-		)
+		// Datasource errors may echo the request, including its credentials.
+		return framework.NewGetPageResponseError(redactError(err, requestSecrets(request)...))
 	}
-	defer resp.Body.Close()
 
-	// An adapter error message is generated if the response status code is not
-	// successful (i.e. if not statusCode >= 200 && statusCode < 300).
-	if adapterErr := web.HTTPError(resp.StatusCode, resp.Header.Get("Retry-After")); adapterErr != nil {
-		return framework.NewGetPageResponseError(adapterErr)
+	objects := resp.Objects
+
+	// Child objects are added to their parent objects before any other
+	// processing, so that they are parsed along with them.
+	if len(request.Entity.ChildEntities) > 0 {
+		if err := a.addChildObjects(ctx, req, entity, request.Entity.ChildEntities, objects); err != nil {
+			return framework.NewGetPageResponseError(redactError(err, requestSecrets(request)...))
+		}
 	}
 
-	// Read the response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return framework.NewGetPageResponseError(
-			&framework.Error{
-				Message: fmt.Sprintf("Failed to read response body: %v", err),
-				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
-			},
-		)
+	if request.Config.CaseInsensitiveAttributes {
+		objects = matchAttributeKeysIgnoringCase(&request.Entity, objects, a.log())
 	}
 
-	// Parse JSON into DatasourceResponse
-	var data DatasourceResponse
-	if err := json.Unmarshal(bodyBytes, &data); err != nil {
-		return framework.NewGetPageResponseError(
-			&framework.Error{
-				Message: fmt.Sprintf("Failed to unmarshal JSON response: %v", err),
-				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
-			},
+	if len(request.Config.SyntheticIDFields) > 0 {
+		uniqueIDAttribute := entity.uniqueIDAttrExternalID
+
+		var idErr error
+
+		objects, idErr = addSyntheticIDs(objects, uniqueIDAttribute, request.Config.SyntheticIDFields)
+		if idErr != nil {
+			return framework.NewGetPageResponseError(
+				&framework.Error{
+					Message: fmt.Sprintf("Failed to compute synthetic IDs: %v.", idErr),
+					Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				},
+			)
+		}
+	}
+
+	if normalizer, found := a.idNormalizers[request.Entity.ExternalId]; found {
+		uniqueIDAttribute := entity.uniqueIDAttrExternalID
+
+		objects = normalizeUniqueIDs(objects, uniqueIDAttribute, normalizer)
+	}
+
+	// Deduplicate after unique IDs are normalized, so that IDs which differ
+	// only by their form are considered duplicates.
+	if request.Config.DeduplicateByID {
+		var dropped int
+
+		objects, dropped = deduplicateObjects(objects, entity.uniqueIDAttrExternalID)
+		if dropped > 0 {
+			a.log().Warn("Dropped duplicate objects from page.",
+				"entity", request.Entity.ExternalId, "count", dropped)
+		}
+	}
+
+	if request.Config.EmptyStrings != "" {
+		objects = normalizeEmptyValues(
+			&request.Entity, objects, request.Config.EmptyStrings, request.Config.EmptyStringAttributes,
 		)
 	}
 
-	// Use data.Teams instead of jsonData
-	parsedObjects, parserErr := web.ConvertJSONObjectList(
-		&request.Entity,
-		data.Teams, // Updated: Use Teams from the DatasourceResponse
-
-		// SCAFFOLDING #23 - pkg/adapter/adapter.go: Disable JSONPathAttributeNames.
-		// Disable JSONPathAttributeNames if your datasource does not support
-		// JSONPath attribute names. This should be enabled for most datasources.
-		web.WithJSONPathAttributeNames(),
-
-		// SCAFFOLDING #24 - pkg/adapter/adapter.go: List datetime formats supported by your SoR.
-		// Provide a list of datetime formats supported by your datasource if
-		// they are known. This will optimize the parsing of datetime values.
-		// If this is not known, you can omit this option which will try
-		// a list of common datetime formats.
-		web.WithDateTimeFormats(
-			[]web.DateTimeFormatWithTimeZone{
-				{Format: time.RFC3339, HasTimeZone: true},
-				{Format: time.RFC3339Nano, HasTimeZone: true},
-				{Format: "2006-01-02T15:04:05.000Z0700", HasTimeZone: true},
-				{Format: "2006-01-02", HasTimeZone: false},
-			}...,
-		),
-	)
-	if parserErr != nil {
-		return framework.NewGetPageResponseError(
-			&framework.Error{
-				Message: fmt.Sprintf("Failed to convert datasource response objects: %v.", parserErr),
-				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
-			},
+	jsonOptions := objectJSONOptions(request.Config)
+
+	var parsedObjects []framework.Object
+
+	if request.Config.LenientParsing {
+		var adapterErr *framework.Error
+
+		parsedObjects, adapterErr = convertJSONObjectListLeniently(
+			&request.Entity, objects, request.Config.MaxSkippedObjectsPercent, a.log(), jsonOptions...,
+		)
+		if adapterErr != nil {
+			return framework.NewGetPageResponseError(adapterErr)
+		}
+	} else {
+		var parserErr error
+
+		parsedObjects, parserErr = web.ConvertJSONObjectList(
+			&request.Entity,
+			objects,
+			jsonOptions...,
 		)
+		if parserErr != nil {
+			return framework.NewGetPageResponseError(
+				&framework.Error{
+					Message: fmt.Sprintf("Failed to convert datasource response objects: %v.", parserErr),
+					Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				},
+			)
+		}
+	}
+
+	// An entity without objects is a successful empty page, not a missing one.
+	if parsedObjects == nil {
+		parsedObjects = []framework.Object{}
 	}
 
 	page := &framework.Page{
-		Objects: parsedObjects,
+		Objects:    parsedObjects,
+		NextCursor: resp.Cursor,
 	}
 
 	return framework.NewGetPageResponseSuccess(page)
 }
+
+// objectJSONOptions returns the options to convert the datasource's objects
+// into framework objects, as configured in the given config.
+func objectJSONOptions(config *Config) []web.JSONOption {
+	var jsonOptions []web.JSONOption
+
+	// SCAFFOLDING #23 - pkg/adapter/adapter.go: Disable JSONPathAttributeNames.
+	// Disable JSONPathAttributeNames if your datasource does not support
+	// JSONPath attribute names. This should be enabled for most datasources.
+	if !config.DisableJSONPathAttributeNames {
+		jsonOptions = append(jsonOptions, web.WithJSONPathAttributeNames())
+	}
+
+	// SCAFFOLDING #24 - pkg/adapter/adapter.go: List datetime formats supported by your SoR.
+	// Provide a list of datetime formats supported by your datasource if
+	// they are known. This will optimize the parsing of datetime values.
+	// If this is not known, you can omit this option which will try
+	// a list of common datetime formats.
+	dateTimeFormats := []web.DateTimeFormatWithTimeZone{
+		{Format: time.RFC3339, HasTimeZone: true},
+		{Format: time.RFC3339Nano, HasTimeZone: true},
+		{Format: "2006-01-02T15:04:05.000Z0700", HasTimeZone: true},
+		{Format: "2006-01-02", HasTimeZone: false},
+	}
+
+	if len(config.DateTimeFormats) > 0 {
+		dateTimeFormats = make([]web.DateTimeFormatWithTimeZone, 0, len(config.DateTimeFormats))
+
+		for _, format := range config.DateTimeFormats {
+			dateTimeFormats = append(dateTimeFormats, web.DateTimeFormatWithTimeZone{
+				Format:      format.Format,
+				HasTimeZone: format.HasTimeZone,
+			})
+		}
+	}
+
+	return append(jsonOptions, web.WithDateTimeFormats(dateTimeFormats...))
+}
+
+// newDatasourceRequest returns the request to the datasource for the page
+// requested in the given GetPage request, for the given entity.
+func newDatasourceRequest(request *framework.Request[Config], entity Entity) *Request {
+	config := request.Config
+	externalID := request.Entity.ExternalId
+
+	// Without a page size, the datasource would pick an arbitrary default.
+	pageSize := request.PageSize
+	if pageSize <= 0 {
+		pageSize = config.DefaultPageSize
+	}
+
+	if pageSize <= 0 {
+		pageSize = MaxPageSize
+	}
+
+	req := &Request{
+		BaseURL:                   datasourceBaseURL(request),
+		PageSize:                  pageSize,
+		EntityExternalID:          externalID,
+		Cursor:                    request.Cursor,
+		Endpoint:                  entity.endpoint,
+		ResponseObjectsKey:        entity.responseObjectsKey,
+		AdditionalObjectsKeys:     entity.additionalObjectsKeys,
+		UniqueIDAttrExternalID:    entity.uniqueIDAttrExternalID,
+		Sources:                   config.EntitySources[externalID],
+		HTTPMethod:                config.EntityHTTPMethods[externalID],
+		RequestBodyTemplate:       config.RequestBodyTemplate,
+		HybridPagination:          config.HybridPagination,
+		TLSPinnedSHA256:           config.TLSPinnedSHA256,
+		GzipRequestBody:           config.GzipRequestBody,
+		CursorCookie:              config.CursorCookie,
+		ReconcileTotalCount:       config.ReconcileTotalCount,
+		ReconcileTolerancePercent: config.ReconcileTolerancePercent,
+		AuthMode:                  config.AuthMode,
+		AuthScheme:                config.AuthScheme,
+		TokenURL:                  config.TokenURL,
+		SubjectTokenType:          config.SubjectTokenType,
+		ClientID:                  config.ClientID,
+		ClientSecret:              config.ClientSecret,
+		Audience:                  config.Audience,
+		MaxRetries:                config.MaxRetries,
+		RetryBaseDelay:            time.Duration(config.RetryBaseDelayMillis) * time.Millisecond,
+		PaginationMode:            config.PaginationMode,
+		AtlassianPagination:       config.AtlassianPagination,
+		ObjectsJSONPath:           config.ObjectsJSONPath,
+		DuplicateKeys:             config.DuplicateKeys,
+		StartCursor:               config.StartCursor,
+		VerifyResponseDigest:      config.VerifyResponseDigest,
+		AllowedContentTypes:       config.AllowedContentTypes,
+		PageSizeHeader:            config.PageSizeHeader,
+		CursorResponseField:       config.CursorResponseField,
+		NextCursorJSONPath:        config.NextCursorJSONPath,
+		CursorHeader:              config.CursorHeader,
+		CursorQueryParam:          config.CursorQueryParam,
+		DisableKeepAlives:         config.DisableKeepAlives,
+		MultiStatus:               config.MultiStatus,
+		BookmarkField:             config.BookmarkField,
+		CursorFromNextURL:         config.CursorFromNextURL,
+		DebugDumpDir:              config.DebugDumpDir,
+		DebugDumpMaxFiles:         config.DebugDumpMaxFiles,
+		DebugDumpMaxBytes:         config.DebugDumpMaxBytes,
+		CompactCursors:            config.CompactCursors,
+		MaxCursorLength:           config.MaxCursorLength,
+		SortParam:                 config.SortParam,
+		RequestTimeout:            time.Duration(config.RequestTimeoutSeconds) * time.Second,
+		IncidentStatuses:          config.IncidentStatuses,
+		TeamIDs:                   config.TeamIDs,
+		Since:                     config.Since,
+		Until:                     config.Until,
+		ExtraHeaders:              config.ExtraHeaders,
+		AcceptHeader:              config.AcceptHeader,
+		ProxyURL:                  config.ProxyURL,
+		RootCAsPEM:                config.RootCAsPEM,
+		ContentType:               config.ContentType,
+		UserAgent:                 config.UserAgent,
+		MaxResponseBytes:          config.MaxResponseBytes,
+	}
+
+	if req.HTTPMethod == "" {
+		req.HTTPMethod = config.HTTPMethod
+	}
+
+	if request.Ordered {
+		req.SortBy = config.SortBy
+	}
+
+	if config.FieldsParam != "" {
+		req.FieldsParam = config.FieldsParam
+
+		for _, attribute := range request.Entity.Attributes {
+			req.Fields = append(req.Fields, attribute.ExternalId)
+		}
+	}
+
+	// Log entries may be scoped to a single incident.
+	if externalID == LogEntries && config.IncidentID != "" {
+		req.Endpoint = "incidents/" + url.PathEscape(config.IncidentID) + "/log_entries"
+	}
+
+	for _, stableSortEntity := range config.StableSortEntities {
+		if stableSortEntity == externalID {
+			req.StableSort = true
+		}
+	}
+
+	req.Token = authToken(request)
+
+	if request.Auth != nil {
+		if request.Auth.Basic != nil {
+			req.Username = request.Auth.Basic.Username
+			req.Password = request.Auth.Basic.Password
+		}
+	}
+
+	return req
+}
+
+// authToken returns the API token to authenticate the given request with: the
+// HTTP authorization of the request's auth credentials if set, or else the
+// config's AuthToken. Returns an empty string if neither is set.
+func authToken(request *framework.Request[Config]) string {
+	if request.Auth != nil && request.Auth.HTTPAuthorization != "" {
+		return request.Auth.HTTPAuthorization
+	}
+
+	if request.Config != nil {
+		return request.Config.AuthToken
+	}
+
+	return ""
+}
+
+// datasourceBaseURL returns the base URL of the datasource's API for the given
+// request: the configured APIBaseURL, or else the request's address, or else
+// DefaultAPIBaseURL, followed by the API version if it is part of the path.
+func datasourceBaseURL(request *framework.Request[Config]) string {
+	baseURL := DefaultAPIBaseURL
+
+	switch {
+	case request.Config.APIBaseURL != "":
+		baseURL = request.Config.APIBaseURL
+	case request.Address != "":
+		baseURL = request.Address
+
+		// The address may omit the scheme, e.g. "api.pagerduty.com".
+		if !strings.Contains(baseURL, "://") {
+			baseURL = "https://" + baseURL
+		}
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	if request.Config.APIVersionInPath {
+		baseURL += "/" + strings.Trim(request.Config.APIVersion, "/")
+	}
+
+	return baseURL
+}
@@ -0,0 +1,2495 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+func TestAdapterGetPage(t *testing.T) {
+	tests := map[string]struct {
+		request       *framework.Request[Config]
+		responses     []FakeResponse
+		wantResponse  framework.Response
+		wantErrorCode api_adapter_v1.ErrorCode
+		wantRequest   *Request
+	}{
+		"success": {
+			request: newTestRequest(),
+			responses: []FakeResponse{
+				{
+					Response: &Response{
+						Objects: []map[string]any{
+							{"id": "P1", "name": "Team 1"},
+							{"id": "P2", "name": "Team 2"},
+						},
+						Cursor: "10",
+					},
+				},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{
+					{"id": "P1", "name": "Team 1"},
+					{"id": "P2", "name": "Team 2"},
+				},
+				NextCursor: "10",
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       Teams,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"escalation_policies": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = EscalationPolicies
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+			}),
+			responses: []FakeResponse{
+				{
+					Response: &Response{
+						Objects: []map[string]any{
+							{"id": "PEP1"},
+							{"id": "PEP2"},
+						},
+					},
+				},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{
+					{"id": "PEP1"},
+					{"id": "PEP2"},
+				},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       EscalationPolicies,
+				Endpoint:               "escalation_policies",
+				ResponseObjectsKey:     "escalation_policies",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"team_members": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = Teams
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+				r.Entity.ChildEntities = []*framework.EntityConfig{
+					{
+						ExternalId: TeamMembers,
+						Attributes: []*framework.AttributeConfig{
+							{
+								ExternalId: "$.user.id",
+								Type:       framework.AttributeTypeString,
+							},
+							{
+								ExternalId: "role",
+								Type:       framework.AttributeTypeString,
+							},
+						},
+					},
+				}
+			}),
+			responses: []FakeResponse{
+				{
+					Response: &Response{
+						Objects: []map[string]any{
+							{"id": "PT1"},
+							{"id": "PT2"},
+						},
+						Cursor: "2",
+					},
+				},
+				{
+					Response: &Response{
+						Objects: []map[string]any{
+							{"user": map[string]any{"id": "PU1"}, "role": "manager"},
+						},
+						Cursor: "1",
+					},
+				},
+				{
+					Response: &Response{
+						Objects: []map[string]any{
+							{"user": map[string]any{"id": "PU2"}, "role": "responder"},
+						},
+					},
+				},
+				{
+					Response: &Response{},
+				},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{
+					{
+						"id": "PT1",
+						"members": []framework.Object{
+							{"$.user.id": "PU1", "role": "manager"},
+							{"$.user.id": "PU2", "role": "responder"},
+						},
+					},
+					{"id": "PT2"},
+				},
+				NextCursor: "2",
+			}),
+		},
+		"unsupported_child_entity": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = Users
+				r.Entity.ChildEntities = []*framework.EntityConfig{
+					{ExternalId: TeamMembers},
+				}
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"unsupported_grandchild_entity": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = Teams
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+				r.Entity.ChildEntities = []*framework.EntityConfig{
+					{
+						ExternalId: TeamMembers,
+						ChildEntities: []*framework.EntityConfig{
+							{ExternalId: "contact_methods"},
+						},
+					},
+				}
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"duplicates_kept": {
+			request: newTestRequest(),
+			responses: []FakeResponse{
+				{
+					Response: &Response{
+						Objects: []map[string]any{
+							{"id": "P1", "name": "Team 1"},
+							{"id": "P1", "name": "Team 1"},
+						},
+					},
+				},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{
+					{"id": "P1", "name": "Team 1"},
+					{"id": "P1", "name": "Team 1"},
+				},
+			}),
+		},
+		"duplicates_dropped": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.DeduplicateByID = true
+			}),
+			responses: []FakeResponse{
+				{
+					Response: &Response{
+						Objects: []map[string]any{
+							{"id": "P1", "name": "Team 1"},
+							{"id": "P2", "name": "Team 2"},
+							{"id": "P1", "name": "Team 1 renamed"},
+						},
+					},
+				},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{
+					{"id": "P1", "name": "Team 1"},
+					{"id": "P2", "name": "Team 2"},
+				},
+			}),
+		},
+		"log_entries": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = LogEntries
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       LogEntries,
+				Endpoint:               "log_entries",
+				ResponseObjectsKey:     "log_entries",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"incident_log_entries": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = LogEntries
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+				r.Config.IncidentID = "PT4KHLK"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       LogEntries,
+				Endpoint:               "incidents/PT4KHLK/log_entries",
+				ResponseObjectsKey:     "log_entries",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"blank_incident_id": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = LogEntries
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+				r.Config.IncidentID = " "
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"default_page_size": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.PageSize = 0
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               MaxPageSize,
+				EntityExternalID:       Teams,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"configured_default_page_size": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.PageSize = 0
+				r.Config.DefaultPageSize = 25
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               25,
+				EntityExternalID:       Teams,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"last_empty_page": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Cursor = "20"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+		},
+		"invalid_config": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIVersion = ""
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"blank_user_agent": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.UserAgent = "  "
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"invalid_request_body_template": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.HTTPMethod = "POST"
+				r.Config.RequestBodyTemplate = `{"cursor": "{{cursor}}"`
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"invalid_auth_scheme": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.AuthScheme = "digest"
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"auth_scheme_with_basic_auth_mode": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.AuthMode = AuthModeBasic
+				r.Config.AuthScheme = AuthSchemeBearer
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"invalid_next_cursor_json_path": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.PaginationMode = PaginationCursor
+				r.Config.NextCursorJSONPath = "pages[*].next"
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"missing_token": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Auth = nil
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"config_token_fallback": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Auth = nil
+				r.Config.AuthToken = "Token token=configtoken"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "Token token=configtoken",
+				PageSize:               10,
+				EntityExternalID:       Teams,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"auth_token_precedence": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.AuthToken = "Token token=configtoken"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       Teams,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"invalid_entity": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = "oncalls"
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"known_attributes": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.ValidateAttributes = true
+				r.Entity.Attributes = append(r.Entity.Attributes, &framework.AttributeConfig{
+					ExternalId: "$.parent.id",
+					Type:       framework.AttributeTypeString,
+				})
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+		},
+		"unknown_attribute": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.ValidateAttributes = true
+				r.Entity.Attributes[1].ExternalId = "nmae"
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"unknown_attribute_not_validated": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.Attributes[1].ExternalId = "nmae"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+		},
+		"page_size_too_large": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.PageSize = MaxPageSize + 1
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		},
+		"ordered_with_sort": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Ordered = true
+				r.Config.SortBy = "name:asc"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       Teams,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+				SortBy:                 "name:asc",
+			},
+		},
+		"ordered_without_sort": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Ordered = true
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"unordered_with_sort": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.SortBy = "name:asc"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{}},
+			},
+			wantResponse: framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			}),
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       Teams,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+		"datasource_error": {
+			request: newTestRequest(),
+			responses: []FakeResponse{
+				{
+					Err: &framework.Error{
+						Message: "Failed to authenticate with datasource.",
+						Code:    api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+					},
+				},
+			},
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: tt.responses}
+
+			got := NewAdapter(client).GetPage(context.Background(), tt.request)
+
+			// Invalid requests must not reach the datasource.
+			if requests := client.Requests(); len(requests) != len(tt.responses) {
+				t.Errorf("Expected %d datasource requests, got %d.", len(tt.responses), len(requests))
+			}
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil || got.Error.Code != tt.wantErrorCode {
+					t.Errorf("Expected error code %v, got response %+v, error %+v.", tt.wantErrorCode, got.Success, got.Error)
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.wantResponse) {
+				t.Errorf("Expected response %+v, got %+v, error %+v.", tt.wantResponse.Success, got.Success, got.Error)
+			}
+
+			if tt.wantRequest != nil {
+				requests := client.Requests()
+				if len(requests) != 1 || !reflect.DeepEqual(requests[0], tt.wantRequest) {
+					t.Errorf("Expected datasource request %+v, got %+v.", tt.wantRequest, requests)
+				}
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageChildEntityRequests(t *testing.T) {
+	client := &FakeClient{
+		Responses: []FakeResponse{
+			{Response: &Response{Objects: []map[string]any{{"id": "PT1"}, {"id": "PT/2"}}, Cursor: "2"}},
+			{Response: &Response{Objects: []map[string]any{{"role": "manager"}}, Cursor: "1"}},
+			{Response: &Response{Objects: []map[string]any{{"role": "responder"}}}},
+			{Response: &Response{}},
+		},
+	}
+
+	request := newTestRequest(func(r *framework.Request[Config]) {
+		r.Entity.ExternalId = Teams
+		r.Entity.Attributes = r.Entity.Attributes[:1]
+		r.Entity.ChildEntities = []*framework.EntityConfig{
+			{
+				ExternalId: TeamMembers,
+				Attributes: []*framework.AttributeConfig{
+					{
+						ExternalId: "role",
+						Type:       framework.AttributeTypeString,
+					},
+				},
+			},
+		}
+	})
+
+	if got := NewAdapter(client).GetPage(context.Background(), request); got.Error != nil {
+		t.Fatalf("Expected no error, got %+v.", got.Error)
+	}
+
+	// The members of each team of the page are requested from the first page,
+	// with the parent's page cursor left untouched.
+	want := []struct {
+		endpoint string
+		cursor   string
+	}{
+		{endpoint: "", cursor: ""},
+		{endpoint: "teams/PT1/members", cursor: ""},
+		{endpoint: "teams/PT1/members", cursor: "1"},
+		{endpoint: "teams/PT%2F2/members", cursor: ""},
+	}
+
+	requests := client.Requests()
+	if len(requests) != len(want) {
+		t.Fatalf("Expected %d datasource requests, got %d.", len(want), len(requests))
+	}
+
+	for i, w := range want {
+		if requests[i].Endpoint != w.endpoint || requests[i].Cursor != w.cursor {
+			t.Errorf("Expected request %d to endpoint %q with cursor %q, got endpoint %q with cursor %q.",
+				i, w.endpoint, w.cursor, requests[i].Endpoint, requests[i].Cursor)
+		}
+	}
+
+	if requests[1].EntityExternalID != TeamMembers || requests[1].ResponseObjectsKey != "members" {
+		t.Errorf("Expected members requested as entity %q with objects key %q, got %q and %q.",
+			TeamMembers, "members", requests[1].EntityExternalID, requests[1].ResponseObjectsKey)
+	}
+}
+
+func TestAdapterGetPageChildEntityConfig(t *testing.T) {
+	client := &FakeClient{
+		Responses: []FakeResponse{
+			{Response: &Response{Objects: []map[string]any{{"id": "PT1"}}}},
+			{Response: &Response{Objects: []map[string]any{{"role": "manager"}}}},
+		},
+	}
+
+	request := newTestRequest(func(r *framework.Request[Config]) {
+		r.Config.FieldsParam = "fields"
+		r.Config.ObjectsJSONPath = "$.teams[*]"
+		r.Config.EntityHTTPMethods = map[string]string{Teams: "POST"}
+		r.Config.IncidentStatuses = []string{"triggered"}
+		r.Config.TeamIDs = []string{"PT1"}
+		r.Config.Since = "2023-01-01T00:00:00Z"
+		r.Config.Until = "2023-02-01T00:00:00Z"
+		r.Entity.ExternalId = Teams
+		r.Entity.Attributes = r.Entity.Attributes[:1]
+		r.Entity.ChildEntities = []*framework.EntityConfig{
+			{
+				ExternalId: TeamMembers,
+				Attributes: []*framework.AttributeConfig{
+					{
+						ExternalId: "role",
+						Type:       framework.AttributeTypeString,
+					},
+				},
+			},
+		}
+	})
+
+	if got := NewAdapter(client).GetPage(context.Background(), request); got.Error != nil {
+		t.Fatalf("Expected no error, got %+v.", got.Error)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 datasource requests, got %d.", len(requests))
+	}
+
+	parent, child := requests[0], requests[1]
+
+	if parent.HTTPMethod != "POST" || parent.FieldsParam != "fields" || parent.ObjectsJSONPath == "" {
+		t.Errorf("Expected parent request with its entity's config, got %+v.", parent)
+	}
+
+	// Nothing specific to the parent entity is applied to its child entity.
+	if child.HTTPMethod != "" || child.RequestBodyTemplate != "" {
+		t.Errorf("Expected child request with default method and no body, got %q and %q.",
+			child.HTTPMethod, child.RequestBodyTemplate)
+	}
+
+	if child.FieldsParam != "" || child.Fields != nil {
+		t.Errorf("Expected child request without fields, got %q=%v.", child.FieldsParam, child.Fields)
+	}
+
+	if child.ObjectsJSONPath != "" {
+		t.Errorf("Expected child request without objects JSONPath, got %q.", child.ObjectsJSONPath)
+	}
+
+	if child.IncidentStatuses != nil || child.TeamIDs != nil || child.Since != "" || child.Until != "" {
+		t.Errorf("Expected child request without filters, got statuses %v, team IDs %v, since %q, until %q.",
+			child.IncidentStatuses, child.TeamIDs, child.Since, child.Until)
+	}
+
+	if child.Token != parent.Token || child.BaseURL != parent.BaseURL {
+		t.Errorf("Expected child request with the parent's connection, got base URL %q.", child.BaseURL)
+	}
+}
+
+func TestAdapterGetPageChildEntityPagesLimit(t *testing.T) {
+	// The datasource keeps returning a new cursor for the members of the team.
+	responses := []FakeResponse{
+		{Response: &Response{Objects: []map[string]any{{"id": "PT1"}}}},
+	}
+
+	for i := 1; i <= MaxChildPages; i++ {
+		responses = append(responses, FakeResponse{
+			Response: &Response{Objects: []map[string]any{{"role": "manager"}}, Cursor: fmt.Sprint(i)},
+		})
+	}
+
+	client := &FakeClient{Responses: responses}
+
+	request := newTestRequest(func(r *framework.Request[Config]) {
+		r.Entity.ExternalId = Teams
+		r.Entity.Attributes = r.Entity.Attributes[:1]
+		r.Entity.ChildEntities = []*framework.EntityConfig{
+			{
+				ExternalId: TeamMembers,
+				Attributes: []*framework.AttributeConfig{
+					{
+						ExternalId: "role",
+						Type:       framework.AttributeTypeString,
+					},
+				},
+			},
+		}
+	})
+
+	got := NewAdapter(client).GetPage(context.Background(), request)
+
+	if got.Error == nil || got.Error.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL {
+		t.Fatalf("Expected internal error, got %+v.", got.Error)
+	}
+
+	if requests := client.Requests(); len(requests) != 1+MaxChildPages {
+		t.Errorf("Expected %d datasource requests, got %d.", 1+MaxChildPages, len(requests))
+	}
+}
+
+func TestAdapterGetPageChildEntityLimiter(t *testing.T) {
+	// Each request gets its own object, valid for both the teams and their
+	// members, whichever order the requests arrive in.
+	client := &FakeClient{Delay: 10 * time.Millisecond}
+
+	for i := 0; i < 4; i++ {
+		client.Responses = append(client.Responses, FakeResponse{
+			Response: &Response{Objects: []map[string]any{{"id": "PT1", "role": "manager"}}},
+		})
+	}
+
+	request := newTestRequest(func(r *framework.Request[Config]) {
+		r.Entity.ExternalId = Teams
+		r.Entity.Attributes = r.Entity.Attributes[:1]
+		r.Entity.ChildEntities = []*framework.EntityConfig{
+			{
+				ExternalId: TeamMembers,
+				Attributes: []*framework.AttributeConfig{
+					{
+						ExternalId: "role",
+						Type:       framework.AttributeTypeString,
+					},
+				},
+			},
+		}
+	})
+
+	// Each request, including those of the child entities, acquires the single
+	// slot of the limiter in turn, so that the child requests of one page never
+	// overlap with the requests of another.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	adapter := NewAdapter(client, WithLimiter(NewLimiter(1)))
+
+	var wg sync.WaitGroup
+
+	responses := make([]framework.Response, 2)
+
+	for i := range responses {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			responses[i] = adapter.GetPage(ctx, request)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, got := range responses {
+		if got.Error != nil {
+			t.Errorf("Expected no error for page %d, got %+v.", i, got.Error)
+		}
+	}
+
+	if requests := client.Requests(); len(requests) != 4 {
+		t.Errorf("Expected 4 datasource requests, got %d.", len(requests))
+	}
+
+	if overall, _ := client.MaxInFlight(); overall != 1 {
+		t.Errorf("Expected at most 1 datasource request in flight, got %d.", overall)
+	}
+}
+
+func TestAdapterGetPageAttributeAPIVersions(t *testing.T) {
+	tests := map[string]struct {
+		apiVersion             string
+		attributeMinAPIVersion map[string]map[string]string
+		wantFields             []string
+	}{
+		"older_version_drops_newer_attribute": {
+			apiVersion:             "v1",
+			attributeMinAPIVersion: map[string]map[string]string{Teams: {"name": "v2"}},
+			wantFields:             []string{"id"},
+		},
+		"minimum_version_keeps_attribute": {
+			apiVersion:             "v2",
+			attributeMinAPIVersion: map[string]map[string]string{Teams: {"name": "v2"}},
+			wantFields:             []string{"id", "name"},
+		},
+		"other_entity_versions_ignored": {
+			apiVersion:             "v1",
+			attributeMinAPIVersion: map[string]map[string]string{Users: {"name": "v2"}},
+			wantFields:             []string{"id", "name"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{
+				Responses: []FakeResponse{
+					{Response: &Response{Objects: []map[string]any{{"id": "P1", "name": "Team 1"}}}},
+				},
+			}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIVersion = tt.apiVersion
+				r.Config.AttributeMinAPIVersions = tt.attributeMinAPIVersion
+				r.Config.FieldsParam = "fields"
+			})
+
+			if got := NewAdapter(client).GetPage(context.Background(), request); got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			requests := client.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("Expected 1 datasource request, got %d.", len(requests))
+			}
+
+			if !reflect.DeepEqual(requests[0].Fields, tt.wantFields) {
+				t.Errorf("Expected fields %v, got %v.", tt.wantFields, requests[0].Fields)
+			}
+
+			if requests[0].FieldsParam != "fields" {
+				t.Errorf("Expected fields param %q, got %q.", "fields", requests[0].FieldsParam)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageContentHeaders(t *testing.T) {
+	tests := map[string]struct {
+		acceptHeader    string
+		contentType     string
+		wantAccept      []string
+		wantContentType []string
+	}{
+		"default": {
+			wantAccept:      []string{DefaultAcceptHeader},
+			wantContentType: []string{DefaultContentType},
+		},
+		// The configured headers replace the defaults rather than being sent
+		// alongside them.
+		"configured": {
+			acceptHeader:    "application/json",
+			contentType:     "application/vnd.api+json",
+			wantAccept:      []string{"application/json"},
+			wantContentType: []string{"application/vnd.api+json"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotHeader http.Header
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header.Clone()
+				fmt.Fprint(w, `{"teams":[{"id":"P1"}]}`)
+			})
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIBaseURL = server.URL
+				r.Config.AcceptHeader = tt.acceptHeader
+				r.Config.ContentType = tt.contentType
+			})
+
+			got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			if got := gotHeader.Values("Accept"); !reflect.DeepEqual(got, tt.wantAccept) {
+				t.Errorf("Expected Accept headers %q, got %q.", tt.wantAccept, got)
+			}
+
+			if got := gotHeader.Values("Content-Type"); !reflect.DeepEqual(got, tt.wantContentType) {
+				t.Errorf("Expected Content-Type headers %q, got %q.", tt.wantContentType, got)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageRedactsExtraHeaders(t *testing.T) {
+	const apiKey = "secret-api-key"
+
+	// The datasource echoes the API key, as some do in error messages.
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+		fmt.Fprintf(w, `{"teams":[{"id":"P1"}],"key":%q}`, r.Header.Get("X-Api-Key"))
+	})
+
+	var logs bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dumpDir := t.TempDir()
+
+	adapter := NewAdapter(&Datasource{Client: server.Client(), Logger: logger}, WithLogger(logger))
+
+	var messages []string
+
+	for _, cursor := range []string{"", "10"} {
+		request := newTestRequest(func(r *framework.Request[Config]) {
+			r.Config.APIBaseURL = server.URL
+			r.Config.ExtraHeaders = map[string]string{"X-Api-Key": apiKey}
+			r.Config.DebugDumpDir = dumpDir
+			r.Cursor = cursor
+		})
+
+		if got := adapter.GetPage(context.Background(), request); got.Error != nil {
+			messages = append(messages, got.Error.Message)
+		}
+	}
+
+	// The second page fails with the API key in the error body.
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 error, got %v.", messages)
+	}
+
+	dumps, err := os.ReadDir(dumpDir)
+	if err != nil || len(dumps) == 0 {
+		t.Fatalf("Expected debug dumps, got %v (%v).", dumps, err)
+	}
+
+	outputs := map[string]string{"logs": logs.String(), "error": messages[0]}
+
+	for _, dump := range dumps {
+		data, err := os.ReadFile(filepath.Join(dumpDir, dump.Name()))
+		if err != nil {
+			t.Fatalf("Failed to read debug dump: %v.", err)
+		}
+
+		outputs[dump.Name()] = string(data)
+	}
+
+	for name, output := range outputs {
+		if strings.Contains(output, apiKey) {
+			t.Errorf("Expected the extra header value to be redacted from %s, got %q.", name, output)
+		}
+
+		if !strings.Contains(output, redactedValue) {
+			t.Errorf("Expected %s to hold the redacted extra header value, got %q.", name, output)
+		}
+	}
+}
+
+func TestAdapterGetPageLogsNoToken(t *testing.T) {
+	tests := map[string]func(*framework.Request[Config]){
+		"auth_header": func(*framework.Request[Config]) {},
+		"config_auth_token": func(r *framework.Request[Config]) {
+			r.Auth = nil
+			r.Config.AuthToken = "Token token=testtoken"
+		},
+		"invalid_config": func(r *framework.Request[Config]) {
+			r.Config.AuthToken = "Token token=testtoken"
+			r.Config.APIBaseURL = "api.pagerduty.com"
+		},
+	}
+
+	for name, modifier := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{}}}}
+
+			NewAdapter(client, WithLogger(logger)).GetPage(context.Background(), newTestRequest(modifier))
+
+			if logs.Len() == 0 {
+				t.Fatal("Expected the request to be logged.")
+			}
+
+			if strings.Contains(logs.String(), "testtoken") {
+				t.Errorf("Expected the token not to be logged, got %q.", logs.String())
+			}
+		})
+	}
+}
+
+func TestAdapterValidateConnection(t *testing.T) {
+	tests := map[string]struct {
+		request       *framework.Request[Config]
+		responses     []FakeResponse
+		wantErrorCode api_adapter_v1.ErrorCode
+	}{
+		"success": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Cursor = "50"
+			}),
+			responses: []FakeResponse{
+				{Response: &Response{Objects: []map[string]any{{"id": "P1"}}, Cursor: "1"}},
+			},
+		},
+		"invalid_config": {
+			request: newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIVersion = ""
+			}),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"authentication_failure": {
+			request: newTestRequest(),
+			responses: []FakeResponse{
+				{
+					Err: &framework.Error{
+						Message: "Failed to authenticate with datasource.",
+						Code:    api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+					},
+				},
+			},
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: tt.responses}
+
+			err := NewAdapter(client).(*Adapter).ValidateConnection(context.Background(), tt.request)
+
+			switch {
+			case tt.wantErrorCode == 0 && err != nil:
+				t.Fatalf("Expected no error, got %+v.", err)
+			case tt.wantErrorCode != 0 && (err == nil || err.Code != tt.wantErrorCode):
+				t.Fatalf("Expected error code %v, got %+v.", tt.wantErrorCode, err)
+			}
+
+			// The first page of a single object is requested.
+			for _, request := range client.Requests() {
+				if request.PageSize != 1 || request.Cursor != "" {
+					t.Errorf("Expected a first page of size 1, got size %d and cursor %q.", request.PageSize, request.Cursor)
+				}
+			}
+		})
+	}
+}
+
+func TestAdapterGetPages(t *testing.T) {
+	client := &FakeClient{
+		Responses: []FakeResponse{
+			{Response: &Response{Objects: []map[string]any{{"id": "P1"}}}},
+		},
+		Delay: 20 * time.Millisecond,
+	}
+
+	adapter := NewAdapter(client, WithLimiter(NewLimiter(2))).(*Adapter)
+
+	// Two pages of each of three entities, in order.
+	var requests []*framework.Request[Config]
+
+	for _, cursor := range []string{"10", "20"} {
+		for _, externalID := range []string{Users, Teams, Services} {
+			requests = append(requests, newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = externalID
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+				r.Cursor = cursor
+			}))
+		}
+	}
+
+	responses := adapter.GetPages(context.Background(), requests)
+
+	if len(responses) != len(requests) {
+		t.Fatalf("Expected %d responses, got %d.", len(requests), len(responses))
+	}
+
+	for i, response := range responses {
+		if response.Error != nil {
+			t.Errorf("Expected no error for request %d, got %+v.", i, response.Error)
+		}
+	}
+
+	overall, perEntity := client.MaxInFlight()
+
+	if overall > 2 {
+		t.Errorf("Expected at most 2 requests in flight, got %d.", overall)
+	}
+
+	if perEntity != 1 {
+		t.Errorf("Expected at most 1 request in flight per entity, got %d.", perEntity)
+	}
+
+	// The pages of each entity are requested in order.
+	gotCursors := make(map[string][]string)
+
+	for _, request := range client.Requests() {
+		gotCursors[request.EntityExternalID] = append(gotCursors[request.EntityExternalID], request.Cursor)
+	}
+
+	for _, externalID := range []string{Users, Teams, Services} {
+		if want := []string{"10", "20"}; !reflect.DeepEqual(gotCursors[externalID], want) {
+			t.Errorf("Expected entity %s requested with cursors %v, got %v.", externalID, want, gotCursors[externalID])
+		}
+	}
+}
+
+func TestAdapterGetPagesCancelled(t *testing.T) {
+	client := &FakeClient{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	responses := NewAdapter(client).(*Adapter).GetPages(ctx, []*framework.Request[Config]{
+		newTestRequest(),
+		newTestRequest(),
+	})
+
+	for i, response := range responses {
+		if response.Error == nil || response.Error.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL {
+			t.Errorf("Expected internal error for request %d, got %+v.", i, response.Error)
+		}
+	}
+
+	if requests := client.Requests(); len(requests) != 0 {
+		t.Errorf("Expected no datasource requests, got %d.", len(requests))
+	}
+}
+
+func TestAdapterGetPageLimiter(t *testing.T) {
+	const limit = 2
+
+	client := &FakeClient{Delay: 20 * time.Millisecond}
+	limiter := NewLimiter(limit)
+
+	// Adapters sharing a limiter share its concurrency budget.
+	adapters := []framework.Adapter[Config]{
+		NewAdapter(client, WithLimiter(limiter)),
+		NewAdapter(client, WithLimiter(limiter)),
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(adapter framework.Adapter[Config]) {
+			defer wg.Done()
+
+			if got := adapter.GetPage(context.Background(), newTestRequest()); got.Error != nil {
+				t.Errorf("Expected no error, got %+v.", got.Error)
+			}
+		}(adapters[i%len(adapters)])
+	}
+
+	wg.Wait()
+
+	if got := len(client.Requests()); got != 8 {
+		t.Errorf("Expected 8 datasource requests, got %d.", got)
+	}
+
+	if overall, _ := client.MaxInFlight(); overall > limit {
+		t.Errorf("Expected at most %d datasource requests in flight, got %d.", limit, overall)
+	}
+}
+
+func TestAdapterGetPageLimiterCancelled(t *testing.T) {
+	client := &FakeClient{}
+	limiter := NewLimiter(1)
+
+	// The only slot is taken, so the request waits until its context is done.
+	if err := limiter.Acquire(context.Background()); err != nil {
+		t.Fatalf("Failed to acquire limiter: %v.", err)
+	}
+	defer limiter.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	got := NewAdapter(client, WithLimiter(limiter)).GetPage(ctx, newTestRequest())
+
+	if got.Error == nil || got.Error.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL {
+		t.Errorf("Expected internal error, got %+v.", got.Error)
+	}
+
+	if requests := client.Requests(); len(requests) != 0 {
+		t.Errorf("Expected no datasource requests, got %d.", len(requests))
+	}
+}
+
+func TestAdapterGetPageUnboundedLimiter(t *testing.T) {
+	tests := map[string]struct {
+		max int
+	}{
+		"zero": {
+			max: 0,
+		},
+		"negative": {
+			max: -1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Delay: 50 * time.Millisecond}
+			adapter := NewAdapter(client, WithLimiter(NewLimiter(tt.max)))
+
+			// A limiter without slots would block every request until its
+			// context is done.
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					if got := adapter.GetPage(ctx, newTestRequest()); got.Error != nil {
+						t.Errorf("Expected no error, got %+v.", got.Error)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			if overall, _ := client.MaxInFlight(); overall != 3 {
+				t.Errorf("Expected 3 datasource requests in flight, got %d.", overall)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageWithoutNewAdapter(t *testing.T) {
+	client := &FakeClient{Responses: []FakeResponse{{Response: &Response{
+		Objects: []map[string]any{{"id": "P1", "name": "Team 1"}},
+	}}}}
+
+	// An Adapter literal has neither a limiter nor a logger.
+	adapter := &Adapter{Client: client}
+
+	got := adapter.GetPage(context.Background(), newTestRequest())
+	if got.Error != nil {
+		t.Fatalf("Expected no error, got %+v.", got.Error)
+	}
+
+	want := []framework.Object{{"id": "P1", "name": "Team 1"}}
+	if !reflect.DeepEqual(got.Success.Objects, want) {
+		t.Errorf("Expected objects %v, got %v.", want, got.Success.Objects)
+	}
+}
+
+func TestAdapterGetPageSyntheticIDs(t *testing.T) {
+	objects := []map[string]any{
+		{"id": "P1", "email": "alice@example.com"},
+		{"email": "bob@example.com"},
+		{"id": nil, "email": "bob@example.com"},
+		{"id": "", "email": "carol@example.com"},
+	}
+
+	getIDs := func() []string {
+		client := &FakeClient{Responses: []FakeResponse{{Response: &Response{Objects: objects}}}}
+
+		request := newTestRequest(func(r *framework.Request[Config]) {
+			r.Config.SyntheticIDFields = []string{"email"}
+		})
+
+		got := NewAdapter(client).GetPage(context.Background(), request)
+		if got.Error != nil {
+			t.Fatalf("Expected no error, got %+v.", got.Error)
+		}
+
+		ids := make([]string, 0, len(got.Success.Objects))
+		for _, object := range got.Success.Objects {
+			ids = append(ids, object["id"].(string))
+		}
+
+		return ids
+	}
+
+	ids := getIDs()
+
+	if len(ids) != len(objects) {
+		t.Fatalf("Expected %d objects, got %d.", len(objects), len(ids))
+	}
+
+	// Objects with an ID keep it.
+	if ids[0] != "P1" {
+		t.Errorf("Expected the object's own ID, got %q.", ids[0])
+	}
+
+	// Synthetic IDs are derived from the configured fields only.
+	if len(ids[1]) != 64 || ids[1] != ids[2] {
+		t.Errorf("Expected the same synthetic ID for objects with the same fields, got %q and %q.", ids[1], ids[2])
+	}
+
+	if ids[3] == ids[1] || len(ids[3]) != 64 {
+		t.Errorf("Expected a distinct synthetic ID for objects with other fields, got %q.", ids[3])
+	}
+
+	// Synthetic IDs are stable across syncs.
+	if again := getIDs(); !reflect.DeepEqual(again, ids) {
+		t.Errorf("Expected stable synthetic IDs %v, got %v.", ids, again)
+	}
+
+	// The datasource's objects are left untouched.
+	if _, found := objects[1]["id"]; found {
+		t.Error("Expected the datasource's objects not to be modified.")
+	}
+}
+
+func TestAdapterGetPageLenientParsing(t *testing.T) {
+	// Each malformed object has a name which is not a string.
+	objects := func(valid, malformed int) []map[string]any {
+		objects := make([]map[string]any, 0, valid+malformed)
+
+		for i := 0; i < valid; i++ {
+			objects = append(objects, map[string]any{"id": fmt.Sprintf("P%d", i), "name": "Team"})
+		}
+
+		for i := 0; i < malformed; i++ {
+			objects = append(objects, map[string]any{"id": fmt.Sprintf("M%d", i), "name": 42})
+		}
+
+		return objects
+	}
+
+	tests := map[string]struct {
+		lenientParsing    bool
+		maxSkippedPercent float64
+		objects           []map[string]any
+		wantObjects       int
+		wantErrorCode     api_adapter_v1.ErrorCode
+		wantWarning       bool
+	}{
+		"under_threshold": {
+			lenientParsing:    true,
+			maxSkippedPercent: 20,
+			objects:           objects(9, 1),
+			wantObjects:       9,
+			wantWarning:       true,
+		},
+		"at_threshold": {
+			lenientParsing:    true,
+			maxSkippedPercent: 10,
+			objects:           objects(9, 1),
+			wantObjects:       9,
+			wantWarning:       true,
+		},
+		"over_threshold": {
+			lenientParsing:    true,
+			maxSkippedPercent: 20,
+			objects:           objects(7, 3),
+			wantErrorCode:     api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+		"zero_threshold": {
+			lenientParsing: true,
+			objects:        objects(9, 1),
+			wantErrorCode:  api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+		"no_malformed_objects": {
+			lenientParsing: true,
+			objects:        objects(10, 0),
+			wantObjects:    10,
+		},
+		"strict": {
+			maxSkippedPercent: 20,
+			objects:           objects(9, 1),
+			wantErrorCode:     api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{Objects: tt.objects}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.LenientParsing = tt.lenientParsing
+				r.Config.MaxSkippedObjectsPercent = tt.maxSkippedPercent
+			})
+
+			got := NewAdapter(client, WithLogger(logger)).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil {
+					t.Fatalf("Expected error code %v, got no error.", tt.wantErrorCode)
+				}
+
+				if got.Error.Code != tt.wantErrorCode {
+					t.Errorf("Expected error code %v, got %v.", tt.wantErrorCode, got.Error.Code)
+				}
+
+				return
+			}
+
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			if len(got.Success.Objects) != tt.wantObjects {
+				t.Errorf("Expected %d objects, got %d.", tt.wantObjects, len(got.Success.Objects))
+			}
+
+			if gotWarning := strings.Contains(logs.String(), "Skipped datasource response objects"); gotWarning != tt.wantWarning {
+				t.Errorf("Expected warning %v, got logs %q.", tt.wantWarning, logs.String())
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageEntityHTTPMethods(t *testing.T) {
+	tests := map[string]struct {
+		httpMethod        string
+		entityHTTPMethods map[string]string
+		wantMethods       map[string]string
+	}{
+		"post_entity": {
+			entityHTTPMethods: map[string]string{Teams: http.MethodPost},
+			wantMethods:       map[string]string{Users: http.MethodGet, Teams: http.MethodPost},
+		},
+		"get_entity_overrides_default": {
+			httpMethod:        http.MethodPost,
+			entityHTTPMethods: map[string]string{Users: http.MethodGet},
+			wantMethods:       map[string]string{Users: http.MethodGet, Teams: http.MethodPost},
+		},
+		"default": {
+			wantMethods: map[string]string{Users: http.MethodGet, Teams: http.MethodGet},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+
+			gotMethods := map[string]string{}
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				entity := strings.TrimPrefix(r.URL.Path, "/")
+
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("Failed to read request body: %v.", err)
+				}
+
+				// POST searches send the pagination in the body only.
+				switch r.Method {
+				case http.MethodPost:
+					if r.URL.RawQuery != "" || !strings.Contains(string(body), `"limit":10`) {
+						t.Errorf("Expected the query in the body of the %s request, got query %q and body %q.",
+							entity, r.URL.RawQuery, body)
+					}
+				default:
+					if len(body) != 0 || r.URL.Query().Get("limit") != "10" {
+						t.Errorf("Expected the query in the URL of the %s request, got query %q and body %q.",
+							entity, r.URL.RawQuery, body)
+					}
+				}
+
+				mu.Lock()
+				gotMethods[entity] = r.Method
+				mu.Unlock()
+
+				fmt.Fprintf(w, `{%q:[{"id":"P1"}]}`, entity)
+			})
+
+			adapter := NewAdapter(&Datasource{Client: server.Client()})
+
+			for _, entity := range []string{Users, Teams} {
+				request := newTestRequest(func(r *framework.Request[Config]) {
+					r.Config.APIBaseURL = server.URL
+					r.Config.HTTPMethod = tt.httpMethod
+					r.Config.EntityHTTPMethods = tt.entityHTTPMethods
+					r.Entity.ExternalId = entity
+				})
+
+				got := adapter.GetPage(context.Background(), request)
+				if got.Error != nil {
+					t.Fatalf("Expected no error for %s, got %+v.", entity, got.Error)
+				}
+
+				if len(got.Success.Objects) != 1 {
+					t.Errorf("Expected 1 %s object, got %d.", entity, len(got.Success.Objects))
+				}
+			}
+
+			if !reflect.DeepEqual(gotMethods, tt.wantMethods) {
+				t.Errorf("Expected methods %v, got %v.", tt.wantMethods, gotMethods)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageEmptyDataset(t *testing.T) {
+	tests := map[string]struct {
+		statusCode int
+		body       string
+	}{
+		"empty_array": {
+			statusCode: http.StatusOK,
+			body:       `{"teams":[]}`,
+		},
+		"empty_array_with_pagination": {
+			statusCode: http.StatusOK,
+			body:       `{"teams":[],"offset":0,"limit":10,"more":false,"total":0}`,
+		},
+		"null_array": {
+			statusCode: http.StatusOK,
+			body:       `{"teams":null}`,
+		},
+		"empty_body": {
+			statusCode: http.StatusOK,
+		},
+		"no_content": {
+			statusCode: http.StatusNoContent,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				fmt.Fprint(w, tt.body)
+			})
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIBaseURL = server.URL
+			})
+
+			got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+
+			want := framework.NewGetPageResponseSuccess(&framework.Page{
+				Objects: []framework.Object{},
+			})
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected an empty successful page, got %+v, error %+v.", got.Success, got.Error)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageEmptyStrings(t *testing.T) {
+	objects := []map[string]any{
+		{"id": "P1", "email": "", "name": nil, "groups": nil},
+		{"id": "P2", "email": nil, "name": "", "groups": []any{"a"}},
+	}
+
+	tests := map[string]struct {
+		emptyStrings          string
+		emptyStringAttributes []string
+		wantObjects           []framework.Object
+	}{
+		"empty_string_to_null": {
+			emptyStrings: EmptyStringToNull,
+			wantObjects: []framework.Object{
+				{"id": "P1"},
+				{"id": "P2", "groups": []string{"a"}},
+			},
+		},
+		"empty_string_to_null_selected_attributes": {
+			emptyStrings:          EmptyStringToNull,
+			emptyStringAttributes: []string{"email"},
+			wantObjects: []framework.Object{
+				{"id": "P1"},
+				{"id": "P2", "name": "", "groups": []string{"a"}},
+			},
+		},
+		"null_to_empty_string": {
+			emptyStrings: NullToEmptyString,
+			wantObjects: []framework.Object{
+				{"id": "P1", "email": "", "name": ""},
+				{"id": "P2", "email": "", "name": "", "groups": []string{"a"}},
+			},
+		},
+		"null_to_empty_string_selected_attributes": {
+			emptyStrings:          NullToEmptyString,
+			emptyStringAttributes: []string{"name", "groups"},
+			wantObjects: []framework.Object{
+				{"id": "P1", "email": "", "name": ""},
+				{"id": "P2", "name": "", "groups": []string{"a"}},
+			},
+		},
+		"unset": {
+			wantObjects: []framework.Object{
+				{"id": "P1", "email": ""},
+				{"id": "P2", "name": "", "groups": []string{"a"}},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{Objects: objects}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.EmptyStrings = tt.emptyStrings
+				r.Config.EmptyStringAttributes = tt.emptyStringAttributes
+				r.Entity.Attributes = append(r.Entity.Attributes,
+					&framework.AttributeConfig{ExternalId: "email", Type: framework.AttributeTypeString},
+					&framework.AttributeConfig{ExternalId: "groups", Type: framework.AttributeTypeString, List: true},
+				)
+			})
+
+			got := NewAdapter(client).GetPage(context.Background(), request)
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			if !reflect.DeepEqual(got.Success.Objects, tt.wantObjects) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantObjects, got.Success.Objects)
+			}
+
+			// The datasource's objects are left untouched.
+			if objects[0]["email"] != "" || objects[0]["name"] != nil {
+				t.Errorf("Expected the datasource's objects not to be modified, got %v.", objects[0])
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageJSONOptions(t *testing.T) {
+	tests := map[string]struct {
+		disableJSONPathAttributeNames bool
+		dateTimeFormats               []DateTimeFormat
+		object                        map[string]any
+		attribute                     *framework.AttributeConfig
+		wantObjects                   []framework.Object
+		wantErrorCode                 api_adapter_v1.ErrorCode
+	}{
+		"json_path_attribute_names": {
+			object:    map[string]any{"id": "P1", "contact": map[string]any{"email": "alice@example.com"}},
+			attribute: &framework.AttributeConfig{ExternalId: "$.contact.email", Type: framework.AttributeTypeString},
+			wantObjects: []framework.Object{
+				{"id": "P1", "$.contact.email": "alice@example.com"},
+			},
+		},
+		"json_path_attribute_names_disabled": {
+			disableJSONPathAttributeNames: true,
+			object:                        map[string]any{"id": "P1", "contact": map[string]any{"email": "alice@example.com"}},
+			attribute:                     &framework.AttributeConfig{ExternalId: "$.contact.email", Type: framework.AttributeTypeString},
+			wantObjects: []framework.Object{
+				{"id": "P1"},
+			},
+		},
+		"default_datetime_formats": {
+			object:    map[string]any{"id": "P1", "created_at": "2024-01-02T03:04:05Z"},
+			attribute: &framework.AttributeConfig{ExternalId: "created_at", Type: framework.AttributeTypeDateTime},
+			wantObjects: []framework.Object{
+				{"id": "P1", "created_at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			},
+		},
+		"custom_datetime_format": {
+			dateTimeFormats: []DateTimeFormat{{Format: "02/01/2006 15:04:05"}},
+			object:          map[string]any{"id": "P1", "created_at": "02/01/2024 03:04:05"},
+			attribute:       &framework.AttributeConfig{ExternalId: "created_at", Type: framework.AttributeTypeDateTime},
+			wantObjects: []framework.Object{
+				{"id": "P1", "created_at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			},
+		},
+		"custom_datetime_format_with_time_zone": {
+			dateTimeFormats: []DateTimeFormat{{Format: "2006-01-02 15:04:05 -0700", HasTimeZone: true}},
+			object:          map[string]any{"id": "P1", "created_at": "2024-01-02 05:04:05 +0200"},
+			attribute:       &framework.AttributeConfig{ExternalId: "created_at", Type: framework.AttributeTypeDateTime},
+			wantObjects: []framework.Object{
+				{"id": "P1", "created_at": time.Date(2024, 1, 2, 5, 4, 5, 0, time.FixedZone("", 2*60*60))},
+			},
+		},
+		"invalid_datetime_format": {
+			dateTimeFormats: []DateTimeFormat{{HasTimeZone: true}},
+			object:          map[string]any{"id": "P1"},
+			attribute:       &framework.AttributeConfig{ExternalId: "created_at", Type: framework.AttributeTypeDateTime},
+			wantErrorCode:   api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{Objects: []map[string]any{tt.object}}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.DisableJSONPathAttributeNames = tt.disableJSONPathAttributeNames
+				r.Config.DateTimeFormats = tt.dateTimeFormats
+				r.Entity.Attributes = append(r.Entity.Attributes[:1], tt.attribute)
+			})
+
+			got := NewAdapter(client).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil || got.Error.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got response %+v, error %+v.", tt.wantErrorCode, got.Success, got.Error)
+				}
+
+				return
+			}
+
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			if !reflect.DeepEqual(got.Success.Objects, tt.wantObjects) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantObjects, got.Success.Objects)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageEntityAPIVersions(t *testing.T) {
+	tests := map[string]struct {
+		apiVersion        string
+		entityAPIVersions map[string]APIVersionRange
+		wantErrorCode     api_adapter_v1.ErrorCode
+	}{
+		"at_min_version": {
+			apiVersion:        "v2",
+			entityAPIVersions: map[string]APIVersionRange{Teams: {Min: "v2"}},
+		},
+		"below_min_version": {
+			apiVersion:        "v1",
+			entityAPIVersions: map[string]APIVersionRange{Teams: {Min: "v2"}},
+			wantErrorCode:     api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"within_range": {
+			apiVersion:        "v2.1",
+			entityAPIVersions: map[string]APIVersionRange{Teams: {Min: "v2", Max: "v3"}},
+		},
+		"above_max_version": {
+			apiVersion:        "v3.1",
+			entityAPIVersions: map[string]APIVersionRange{Teams: {Min: "v2", Max: "v3"}},
+			wantErrorCode:     api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"numeric_comparison": {
+			apiVersion:        "v10",
+			entityAPIVersions: map[string]APIVersionRange{Teams: {Min: "v9"}},
+		},
+		"other_entity_ignored": {
+			apiVersion:        "v1",
+			entityAPIVersions: map[string]APIVersionRange{"Services": {Min: "v2"}},
+		},
+		"unset": {
+			apiVersion: "v1",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIVersion = tt.apiVersion
+				r.Config.EntityAPIVersions = tt.entityAPIVersions
+			})
+
+			got := NewAdapter(client).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil || got.Error.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got response %+v, error %+v.", tt.wantErrorCode, got.Success, got.Error)
+				}
+
+				if !strings.Contains(got.Error.Message, tt.apiVersion) {
+					t.Errorf("Expected error message to hold the API version, got %q.", got.Error.Message)
+				}
+
+				// Incompatible requests must not reach the datasource.
+				if requests := client.Requests(); len(requests) != 0 {
+					t.Errorf("Expected no datasource request, got %d.", len(requests))
+				}
+
+				return
+			}
+
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageStableSortEntities(t *testing.T) {
+	tests := map[string]struct {
+		entity         string
+		wantStableSort bool
+	}{
+		"opted_in_entity": {
+			entity:         Users,
+			wantStableSort: true,
+		},
+		"other_entity": {
+			entity: Teams,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = tt.entity
+				r.Config.StableSortEntities = []string{Users}
+			})
+
+			if got := NewAdapter(client).GetPage(context.Background(), request); got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			requests := client.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("Expected 1 datasource request, got %d.", len(requests))
+			}
+
+			if requests[0].StableSort != tt.wantStableSort {
+				t.Errorf("Expected stable sort %v, got %v.", tt.wantStableSort, requests[0].StableSort)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageIDNormalizer(t *testing.T) {
+	lowercase := func(id string) string {
+		return strings.ToLower(strings.TrimSpace(id))
+	}
+
+	objects := []map[string]any{
+		{"id": "Alice@Example.com", "name": "Alice@Example.com"},
+		{"id": " alice@example.com", "name": "alice@example.com"},
+		{"id": "bob@example.com", "name": "bob@example.com"},
+	}
+
+	tests := map[string]struct {
+		normalizerEntity string
+		deduplicateByID  bool
+		wantIDs          []string
+	}{
+		"normalized": {
+			normalizerEntity: Teams,
+			wantIDs:          []string{"alice@example.com", "alice@example.com", "bob@example.com"},
+		},
+		"normalized_before_deduplication": {
+			normalizerEntity: Teams,
+			deduplicateByID:  true,
+			wantIDs:          []string{"alice@example.com", "bob@example.com"},
+		},
+		"other_entity": {
+			normalizerEntity: "Services",
+			wantIDs:          []string{"Alice@Example.com", " alice@example.com", "bob@example.com"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{Objects: objects}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.DeduplicateByID = tt.deduplicateByID
+			})
+
+			adapter := NewAdapter(client, WithIDNormalizer(tt.normalizerEntity, lowercase))
+
+			got := adapter.GetPage(context.Background(), request)
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			gotIDs := make([]string, 0, len(got.Success.Objects))
+			for _, object := range got.Success.Objects {
+				gotIDs = append(gotIDs, object["id"].(string))
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("Expected IDs %q, got %q.", tt.wantIDs, gotIDs)
+			}
+
+			// Only the unique ID is normalized, and the datasource's objects
+			// are left untouched.
+			if name := got.Success.Objects[0]["name"]; name != "Alice@Example.com" {
+				t.Errorf("Expected other attributes not to be normalized, got name %q.", name)
+			}
+
+			if objects[0]["id"] != "Alice@Example.com" {
+				t.Errorf("Expected the datasource's objects not to be modified, got %v.", objects[0])
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageAdHocEntities(t *testing.T) {
+	adHocEntities := map[string]AdHocEntity{
+		"business_services": {Endpoint: "v1/business_services", UniqueIDAttribute: "id", ObjectsKey: "services"},
+	}
+
+	tests := map[string]struct {
+		entity             string
+		allowAdHocEntities bool
+		adHocEntities      map[string]AdHocEntity
+		wantErrorCode      api_adapter_v1.ErrorCode
+		wantRequest        *Request
+		wantWarning        bool
+	}{
+		"strict_rejects_unknown_entity": {
+			entity:        "business_services",
+			adHocEntities: adHocEntities,
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"ad_hoc_allowed": {
+			entity:             "business_services",
+			allowAdHocEntities: true,
+			adHocEntities:      adHocEntities,
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       "business_services",
+				Endpoint:               "v1/business_services",
+				ResponseObjectsKey:     "services",
+				UniqueIDAttrExternalID: "id",
+			},
+			wantWarning: true,
+		},
+		"ad_hoc_allowed_undefined_entity": {
+			entity:             "incident_workflows",
+			allowAdHocEntities: true,
+			adHocEntities:      adHocEntities,
+			wantErrorCode:      api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+		"ad_hoc_allowed_incomplete_entity": {
+			entity:             "business_services",
+			allowAdHocEntities: true,
+			adHocEntities:      map[string]AdHocEntity{"business_services": {Endpoint: "business_services"}},
+			wantErrorCode:      api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		"supported_entity_not_overridden": {
+			entity:             Users,
+			allowAdHocEntities: true,
+			adHocEntities: map[string]AdHocEntity{
+				Users: {Endpoint: "v1/people", UniqueIDAttribute: "id"},
+			},
+			wantRequest: &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "testtoken",
+				PageSize:               10,
+				EntityExternalID:       Users,
+				ResponseObjectsKey:     "users",
+				UniqueIDAttrExternalID: "id",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Entity.ExternalId = tt.entity
+				r.Config.AllowAdHocEntities = tt.allowAdHocEntities
+				r.Config.AdHocEntities = tt.adHocEntities
+			})
+
+			adapter := NewAdapter(client, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+			got := adapter.GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil || got.Error.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got response %+v, error %+v.", tt.wantErrorCode, got.Success, got.Error)
+				}
+
+				if requests := client.Requests(); len(requests) != 0 {
+					t.Errorf("Expected no datasource request, got %d.", len(requests))
+				}
+
+				return
+			}
+
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			requests := client.Requests()
+			if len(requests) != 1 || !reflect.DeepEqual(requests[0], tt.wantRequest) {
+				t.Errorf("Expected datasource request %+v, got %+v.", tt.wantRequest, requests)
+			}
+
+			if gotWarning := strings.Contains(logs.String(), "ad hoc entity"); gotWarning != tt.wantWarning {
+				t.Errorf("Expected warning %v, got logs %q.", tt.wantWarning, logs.String())
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageUsers(t *testing.T) {
+	var gotPath string
+
+	// The body also holds a list of teams, which must not be taken for users.
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+
+		fmt.Fprint(w, `{"users":[`+
+			`{"id":"P1","email":"alice@example.com","name":"Alice"},`+
+			`{"id":"P2","email":"bob@example.com","name":"Bob"}`+
+			`],"teams":[{"id":"PT1"}],"more":false}`)
+	})
+
+	request := newTestRequest(func(r *framework.Request[Config]) {
+		r.Config.APIBaseURL = server.URL
+		r.Entity.ExternalId = Users
+		r.Entity.Attributes = []*framework.AttributeConfig{
+			{ExternalId: "id", Type: framework.AttributeTypeString},
+			{ExternalId: "email", Type: framework.AttributeTypeString},
+		}
+	})
+
+	got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+
+	want := framework.NewGetPageResponseSuccess(&framework.Page{
+		Objects: []framework.Object{
+			{"id": "P1", "email": "alice@example.com"},
+			{"id": "P2", "email": "bob@example.com"},
+		},
+	})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected response %+v, got %+v, error %+v.", want.Success, got.Success, got.Error)
+	}
+
+	if gotPath != "/users" {
+		t.Errorf("Expected a request to /users, got %q.", gotPath)
+	}
+}
+
+func TestAdapterGetPageEntityObjectsKeys(t *testing.T) {
+	// The datasource returns the lists of all entities in every response, so
+	// that each entity must select its own.
+	const body = `{"teams":[{"id":"PT1"}],"users":[{"id":"P1"},{"id":"P2"}],` +
+		`"escalation_policies":[{"id":"PEP1"}],"more":false}`
+
+	tests := map[string]struct {
+		entity        string
+		wantPath      string
+		wantIDs       []string
+		wantErrorCode api_adapter_v1.ErrorCode
+	}{
+		"teams": {
+			entity:   Teams,
+			wantPath: "/teams",
+			wantIDs:  []string{"PT1"},
+		},
+		"users": {
+			entity:   Users,
+			wantPath: "/users",
+			wantIDs:  []string{"P1", "P2"},
+		},
+		"escalation_policies": {
+			entity:   EscalationPolicies,
+			wantPath: "/escalation_policies",
+			wantIDs:  []string{"PEP1"},
+		},
+		"unknown_entity": {
+			entity:        "widgets",
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotPath string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				fmt.Fprint(w, body)
+			})
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIBaseURL = server.URL
+				r.Entity.ExternalId = tt.entity
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+			})
+
+			got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil || got.Error.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got response %+v, error %+v.", tt.wantErrorCode, got.Success, got.Error)
+				}
+
+				if gotPath != "" {
+					t.Errorf("Expected no datasource request, got a request to %q.", gotPath)
+				}
+
+				return
+			}
+
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			gotIDs := make([]string, 0, len(got.Success.Objects))
+			for _, object := range got.Success.Objects {
+				gotIDs = append(gotIDs, object["id"].(string))
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantIDs, gotIDs)
+			}
+
+			if gotPath != tt.wantPath {
+				t.Errorf("Expected a request to %q, got %q.", tt.wantPath, gotPath)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageServicesTeamIDs(t *testing.T) {
+	tests := map[string]struct {
+		entity      string
+		teamIDs     []string
+		wantTeamIDs []string
+	}{
+		"services": {
+			entity:      Services,
+			teamIDs:     []string{"PT1", "PT2"},
+			wantTeamIDs: []string{"PT1", "PT2"},
+		},
+		"services_without_teams": {
+			entity: Services,
+		},
+		// The teams only scope services.
+		"teams": {
+			entity:  Users,
+			teamIDs: []string{"PT1", "PT2"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotQuery url.Values
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotQuery = r.URL.Query()
+				fmt.Fprintf(w, `{"%s":[{"id":"P1"}]}`, tt.entity)
+			})
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIBaseURL = server.URL
+				r.Config.TeamIDs = tt.teamIDs
+				r.Entity.ExternalId = tt.entity
+				r.Entity.Attributes = r.Entity.Attributes[:1]
+			})
+
+			got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			if gotTeamIDs := gotQuery["team_ids[]"]; !reflect.DeepEqual(gotTeamIDs, tt.wantTeamIDs) {
+				t.Errorf("Expected team_ids[] %v, got %v.", tt.wantTeamIDs, gotTeamIDs)
+			}
+
+			if len(got.Success.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %d.", len(got.Success.Objects))
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageAPIBaseURL(t *testing.T) {
+	tests := map[string]struct {
+		basePath         string
+		apiVersion       string
+		apiVersionInPath bool
+		wantPath         string
+	}{
+		"base_url": {
+			apiVersion: "v2",
+			wantPath:   "/teams",
+		},
+		"base_url_with_path": {
+			basePath:   "/sandbox/",
+			apiVersion: "v2",
+			wantPath:   "/sandbox/teams",
+		},
+		"api_version_in_path": {
+			basePath:         "/sandbox",
+			apiVersion:       "v3",
+			apiVersionInPath: true,
+			wantPath:         "/sandbox/v3/teams",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotPath string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				fmt.Fprint(w, `{"teams":[{"id":"P1"}]}`)
+			})
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Address = "api.pagerduty.com"
+				r.Config.APIBaseURL = server.URL + tt.basePath
+				r.Config.APIVersion = tt.apiVersion
+				r.Config.APIVersionInPath = tt.apiVersionInPath
+			})
+
+			got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			// The request targets the configured base URL rather than the
+			// request's address.
+			if gotPath != tt.wantPath {
+				t.Errorf("Expected a request to %q, got %q.", tt.wantPath, gotPath)
+			}
+		})
+	}
+}
+
+func TestDatasourceBaseURL(t *testing.T) {
+	tests := map[string]struct {
+		address          string
+		apiBaseURL       string
+		apiVersionInPath bool
+		want             string
+	}{
+		"default": {
+			want: DefaultAPIBaseURL,
+		},
+		"address_without_scheme": {
+			address: "api.eu.pagerduty.com",
+			want:    "https://api.eu.pagerduty.com",
+		},
+		"address_with_scheme": {
+			address: "http://localhost:8080/",
+			want:    "http://localhost:8080",
+		},
+		"api_base_url_overrides_address": {
+			address:    "api.pagerduty.com",
+			apiBaseURL: "https://sandbox.example.com/pagerduty/",
+			want:       "https://sandbox.example.com/pagerduty",
+		},
+		"api_version_in_path": {
+			apiBaseURL:       "https://sandbox.example.com",
+			apiVersionInPath: true,
+			want:             "https://sandbox.example.com/v2",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Address = tt.address
+				r.Config.APIBaseURL = tt.apiBaseURL
+				r.Config.APIVersionInPath = tt.apiVersionInPath
+			})
+
+			if got := datasourceBaseURL(request); got != tt.want {
+				t.Errorf("Expected base URL %q, got %q.", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageBasicAuth(t *testing.T) {
+	tests := map[string]struct {
+		auth          *framework.DatasourceAuthCredentials
+		wantHeader    string
+		wantErrorCode api_adapter_v1.ErrorCode
+	}{
+		"basic_auth": {
+			auth: &framework.DatasourceAuthCredentials{
+				Basic: &framework.BasicAuthCredentials{Username: "user", Password: "pass"},
+			},
+			wantHeader: "Basic dXNlcjpwYXNz",
+		},
+		"missing_password": {
+			auth: &framework.DatasourceAuthCredentials{
+				Basic: &framework.BasicAuthCredentials{Username: "user"},
+			},
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+		// A token doesn't satisfy basic auth.
+		"missing_credentials": {
+			auth: &framework.DatasourceAuthCredentials{
+				HTTPAuthorization: "Token token=testtoken",
+			},
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				requestCount int
+				gotHeader    string
+			)
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				requestCount++
+				gotHeader = r.Header.Get("Authorization")
+
+				fmt.Fprint(w, `{"teams":[]}`)
+			})
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIBaseURL = server.URL
+				r.Config.AuthMode = AuthModeBasic
+				r.Auth = tt.auth
+			})
+
+			got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil || got.Error.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got response %+v, error %+v.", tt.wantErrorCode, got.Success, got.Error)
+				}
+
+				if requestCount != 0 {
+					t.Errorf("Expected no datasource requests, got %d.", requestCount)
+				}
+
+				return
+			}
+
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			if gotHeader != tt.wantHeader {
+				t.Errorf("Expected Authorization header %q, got %q.", tt.wantHeader, gotHeader)
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageCaseInsensitiveAttributes(t *testing.T) {
+	tests := map[string]struct {
+		caseInsensitive bool
+		object          map[string]any
+		wantObjects     []framework.Object
+		wantWarning     bool
+	}{
+		"disabled": {
+			object: map[string]any{"id": "P1", "Name": "Team 1"},
+			wantObjects: []framework.Object{
+				{"id": "P1"},
+			},
+		},
+		"mixed_case_keys": {
+			caseInsensitive: true,
+			object:          map[string]any{"Id": "P1", "NAME": "Team 1"},
+			wantObjects: []framework.Object{
+				{"id": "P1", "name": "Team 1"},
+			},
+		},
+		"exact_key_preferred": {
+			caseInsensitive: true,
+			object:          map[string]any{"ID": "P2", "id": "P1", "Name": "Team 1"},
+			wantObjects: []framework.Object{
+				{"id": "P1", "name": "Team 1"},
+			},
+			wantWarning: true,
+		},
+		// The exact key is used even though its case variant comes first in
+		// lexicographic order.
+		"exact_key_and_variant": {
+			caseInsensitive: true,
+			object:          map[string]any{"id": "P1", "Name": "Team 2", "name": "Team 1"},
+			wantObjects: []framework.Object{
+				{"id": "P1", "name": "Team 1"},
+			},
+			wantWarning: true,
+		},
+		// Of several keys differing only by case, the first one in
+		// lexicographic order is used whatever the order of the map keys.
+		"keys_differing_by_case": {
+			caseInsensitive: true,
+			object:          map[string]any{"id": "P1", "Name": "Team 2", "NAME": "Team 1"},
+			wantObjects: []framework.Object{
+				{"id": "P1", "name": "Team 1"},
+			},
+			wantWarning: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FakeClient{Responses: []FakeResponse{{Response: &Response{Objects: []map[string]any{tt.object}}}}}
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.CaseInsensitiveAttributes = tt.caseInsensitive
+			})
+
+			var logs bytes.Buffer
+
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			// Map iteration order is random, so repeat the request to catch a
+			// choice that depends on it.
+			for i := 0; i < 10; i++ {
+				response := NewAdapter(client, WithLogger(logger)).GetPage(context.Background(), request)
+				if response.Error != nil {
+					t.Fatalf("Expected no error, got %+v.", response.Error)
+				}
+
+				if !reflect.DeepEqual(response.Success.Objects, tt.wantObjects) {
+					t.Fatalf("Expected objects %v, got %v.", tt.wantObjects, response.Success.Objects)
+				}
+			}
+
+			if gotWarning := strings.Contains(logs.String(), "Several object keys match"); gotWarning != tt.wantWarning {
+				t.Errorf("Expected warning %t, got logs %q.", tt.wantWarning, logs.String())
+			}
+		})
+	}
+}
+
+func TestAdapterGetPageUniqueIDAttribute(t *testing.T) {
+	adHocEntities := map[string]AdHocEntity{
+		"business_services": {Endpoint: "business_services", UniqueIDAttribute: "sys_id"},
+	}
+
+	tests := map[string]struct {
+		uniqueIDAttribute string
+		wantObjects       []framework.Object
+		wantErrorCode     api_adapter_v1.ErrorCode
+	}{
+		"unique_id_attribute_not_id": {
+			uniqueIDAttribute: "sys_id",
+			wantObjects: []framework.Object{
+				{"sys_id": "PBS1", "name": "Support"},
+			},
+		},
+		"missing_unique_id_attribute": {
+			uniqueIDAttribute: "id",
+			wantErrorCode:     api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `{"business_services":[{"sys_id":"PBS1","name":"Support"}]}`)
+			})
+
+			request := newTestRequest(func(r *framework.Request[Config]) {
+				r.Config.APIBaseURL = server.URL
+				r.Config.AllowAdHocEntities = true
+				r.Config.AdHocEntities = adHocEntities
+				r.Entity.ExternalId = "business_services"
+				r.Entity.Attributes[0].ExternalId = tt.uniqueIDAttribute
+			})
+
+			got := NewAdapter(&Datasource{Client: server.Client()}).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if got.Error == nil || got.Error.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got response %+v, error %+v.", tt.wantErrorCode, got.Success, got.Error)
+				}
+
+				if !strings.Contains(got.Error.Message, "sys_id") {
+					t.Errorf("Expected error message to name the unique ID attribute, got %q.", got.Error.Message)
+				}
+
+				return
+			}
+
+			if got.Error != nil {
+				t.Fatalf("Expected no error, got %+v.", got.Error)
+			}
+
+			if !reflect.DeepEqual(got.Success.Objects, tt.wantObjects) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantObjects, got.Success.Objects)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+const (
+	// MaxConcurrentPages is the default maximum number of pages requested
+	// concurrently from the datasource by an adapter.
+	MaxConcurrentPages = 4
+)
+
+// GetPages queries a page of objects for each of the given requests
+// concurrently, e.g. to fetch the first page of several entities in one
+// logical operation.
+//
+// The number of requests in flight is bounded by the adapter's limiter, and
+// requests for the same entity are sent one at a time, in order, so that the
+// per-entity rate limits of the datasource are respected.
+//
+// The returned responses are in the same order as the requests. Each response
+// contains either the page or the error for its request; a failure for one
+// entity does not affect the others.
+func (a *Adapter) GetPages(ctx context.Context, requests []*framework.Request[Config]) []framework.Response {
+	responses := make([]framework.Response, len(requests))
+
+	// Group the indices of the requests by entity external ID, in order, to
+	// serialize the requests for the same entity.
+	var entities []string
+
+	entityRequests := make(map[string][]int)
+
+	for i, request := range requests {
+		if request == nil {
+			responses[i] = framework.NewGetPageResponseError(
+				&framework.Error{
+					Message: "Request is not set.",
+					Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+				},
+			)
+
+			continue
+		}
+
+		externalID := request.Entity.ExternalId
+		if _, found := entityRequests[externalID]; !found {
+			entities = append(entities, externalID)
+		}
+
+		entityRequests[externalID] = append(entityRequests[externalID], i)
+	}
+
+	var wg sync.WaitGroup
+
+	for _, externalID := range entities {
+		wg.Add(1)
+
+		go func(indices []int) {
+			defer wg.Done()
+
+			for _, i := range indices {
+				// The context may have been cancelled by a previous request.
+				if ctx.Err() != nil {
+					responses[i] = cancelledPageResponse(ctx)
+
+					continue
+				}
+
+				responses[i] = a.GetPage(ctx, requests[i])
+			}
+		}(entityRequests[externalID])
+	}
+
+	wg.Wait()
+
+	return responses
+}
+
+// cancelledPageResponse returns the response for a request that was not sent
+// because the context was done.
+func cancelledPageResponse(ctx context.Context) framework.Response {
+	return framework.NewGetPageResponseError(cancelledError(ctx))
+}
+
+// cancelledError returns the error for a request that was not sent because
+// the context was done.
+func cancelledError(ctx context.Context) *framework.Error {
+	return &framework.Error{
+		Message: fmt.Sprintf("Request was not sent to datasource: %v.", ctx.Err()),
+		Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+	}
+}
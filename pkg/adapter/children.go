@@ -0,0 +1,136 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+// MaxChildPages is the maximum number of pages requested from the datasource
+// for the child objects of a single parent object. A datasource returning more
+// pages, e.g. because it keeps returning new cursors, fails the request.
+const MaxChildPages = 100
+
+// addChildObjects queries all the pages of the requested child entities of
+// each of the given parent objects, and adds the child objects to the parent
+// object under the child entity's external ID, where they are parsed from.
+// parentRequest is the datasource request of the page of parent objects.
+func (a *Adapter) addChildObjects(
+	ctx context.Context, parentRequest *Request, parent Entity, childEntities []*framework.EntityConfig,
+	objects []map[string]any,
+) *framework.Error {
+	parentPath := parentRequest.EntityExternalID
+	if parent.endpoint != "" {
+		parentPath = parent.endpoint
+	}
+
+	for _, childEntity := range childEntities {
+		child := parent.childEntities[childEntity.ExternalId]
+
+		for _, object := range objects {
+			parentID, ok := object[parent.uniqueIDAttrExternalID].(string)
+			if !ok || parentID == "" {
+				return &framework.Error{
+					Message: fmt.Sprintf("Object of entity %s has no %s attribute to query its %s child objects.",
+						parentRequest.EntityExternalID, parent.uniqueIDAttrExternalID, childEntity.ExternalId),
+					Code: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				}
+			}
+
+			req := newChildRequest(parentRequest, childEntity.ExternalId, child)
+			req.Endpoint = strings.TrimSuffix(parentPath, "/") + "/" + url.PathEscape(parentID) + "/" + child.endpoint
+
+			childObjects, err := a.getAllObjects(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			object[childEntity.ExternalId] = childObjects
+		}
+	}
+
+	return nil
+}
+
+// newChildRequest returns the request to the datasource for the first page of
+// the given child entity. The connection, authentication and pagination
+// settings are those of the request of its parent's page, while everything
+// specific to the parent entity is reset to the child entity's own config,
+// so that the child is queried with a plain GET of its endpoint.
+// The endpoint of the request must be set to that of the parent object.
+func newChildRequest(parentRequest *Request, externalID string, child Entity) *Request {
+	req := *parentRequest
+	req.EntityExternalID = externalID
+	req.Cursor = ""
+	req.StartCursor = ""
+	req.Sources = nil
+	req.ResponseObjectsKey = child.responseObjectsKey
+	req.AdditionalObjectsKeys = child.additionalObjectsKeys
+	req.UniqueIDAttrExternalID = child.uniqueIDAttrExternalID
+	req.ObjectsJSONPath = ""
+	req.HTTPMethod = ""
+	req.RequestBodyTemplate = ""
+	req.Fields = nil
+	req.FieldsParam = ""
+	req.IncidentStatuses = nil
+	req.TeamIDs = nil
+	req.Since = ""
+	req.Until = ""
+	req.StableSort = false
+	req.SortBy = ""
+	req.ReconcileTotalCount = false
+
+	return &req
+}
+
+// getAllObjects returns the objects of all the pages returned by the
+// datasource for the given request, starting from its cursor.
+// Returns an error if the datasource returns more than MaxChildPages pages.
+func (a *Adapter) getAllObjects(ctx context.Context, req *Request) ([]any, *framework.Error) {
+	objects := []any{}
+
+	for pages := 0; ; pages++ {
+		if pages == MaxChildPages {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Datasource returned more than %d pages of %s child objects at %s.",
+					MaxChildPages, req.EntityExternalID, req.Endpoint),
+				Code: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+
+		resp, err := a.requestPage(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range resp.Objects {
+			objects = append(objects, object)
+		}
+
+		if resp.Cursor == "" || resp.Cursor == req.Cursor {
+			return objects, nil
+		}
+
+		next := *req
+		next.Cursor = resp.Cursor
+		req = &next
+	}
+}
@@ -16,6 +16,7 @@ package adapter
 
 import (
 	"context"
+	"time"
 
 	framework "github.com/sgnl-ai/adapter-framework"
 )
@@ -27,6 +28,8 @@ type Client interface {
 	// requested entity.
 	// Returns a (possibly empty) list of JSON objects, each object being
 	// unmarshaled into a map by Golang's JSON unmarshaler.
+	// If an error is returned, the Response may be non-nil, in which case its
+	// Cursor is the cursor from which to resume.
 	GetPage(ctx context.Context, request *Request) (*Response, *framework.Error)
 }
 
@@ -57,6 +60,286 @@ type Request struct {
 	// the last request for the entity.
 	// Optional. If not set, return the first page for this entity.
 	Cursor string
+
+	// HybridPagination selects the value sent as the offset when the cursor
+	// tracks both a computed offset and the X-Next-Page header.
+	// Optional. See Config.HybridPagination.
+	HybridPagination string
+
+	// TLSPinnedSHA256 is the set of allowed SHA-256 fingerprints of the
+	// datasource's TLS leaf certificate.
+	// Optional. If not set, the certificate is verified against trusted CAs.
+	TLSPinnedSHA256 []string
+
+	// GzipRequestBody indicates whether the request body, if any, must be
+	// gzip-compressed.
+	GzipRequestBody bool
+
+	// CursorCookie is the name of the cookie carrying the pagination
+	// continuation, which is echoed on the request for the next page.
+	// Optional. See Config.CursorCookie.
+	CursorCookie string
+
+	// ReconcileTotalCount indicates whether the number of objects returned
+	// across all pages is tracked in the cursor and compared to the total
+	// announced by the datasource on the last page.
+	ReconcileTotalCount bool
+
+	// ReconcileTolerancePercent is the divergence, in percent of the total,
+	// above which a warning is logged when reconciling the total count.
+	ReconcileTolerancePercent float64
+
+	// AuthMode selects how requests are authenticated with the datasource.
+	// Optional. See Config.AuthMode.
+	AuthMode string
+
+	// AuthScheme is the scheme of the Authorization header sending Token.
+	// Optional. See Config.AuthScheme.
+	AuthScheme string
+
+	// TokenURL is the URL of the OAuth2 token endpoint.
+	TokenURL string
+
+	// SubjectTokenType is the type of Token when exchanged for an access token.
+	SubjectTokenType string
+
+	// ClientID is the OAuth2 client ID used to obtain access tokens with
+	// client credentials.
+	ClientID string
+
+	// ClientSecret is the OAuth2 client secret used to obtain access tokens
+	// with client credentials.
+	ClientSecret string
+
+	// Audience is the audience requested for access tokens obtained via token
+	// exchange.
+	Audience string
+
+	// MaxRetries is the maximum number of times a failed request is retried.
+	// Optional. If not set, requests are not retried.
+	MaxRetries int
+
+	// RetryBaseDelay is the delay before the first retry of a failed request.
+	// Optional. See Config.RetryBaseDelayMillis.
+	RetryBaseDelay time.Duration
+
+	// PaginationMode is the pagination style of the datasource.
+	// Optional. See Config.PaginationMode.
+	PaginationMode PaginationType
+
+	// AtlassianPagination configures Atlassian-style pagination.
+	// Optional. See Config.AtlassianPagination.
+	AtlassianPagination *AtlassianPagination
+
+	// Sources is the list of endpoint paths whose objects are merged into the
+	// entity's pages, if the entity is queried from several endpoints.
+	// Optional. If not set, the entity is queried from its external ID's path.
+	Sources []string
+
+	// Endpoint is the path of the endpoint to query the entity.
+	// Optional. If not set, the entity is queried from its external ID's path.
+	Endpoint string
+
+	// ResponseObjectsKey is the key of the list of objects in response bodies.
+	// Optional. If not set, the key declared for the entity in
+	// ValidEntityExternalIDs is used.
+	ResponseObjectsKey string
+
+	// AdditionalObjectsKeys are the keys of further lists of objects in
+	// response bodies, merged in order after those of ResponseObjectsKey.
+	// Optional.
+	AdditionalObjectsKeys []string
+
+	// UniqueIDAttrExternalID is the external ID of the entity's unique ID
+	// attribute.
+	UniqueIDAttrExternalID string
+
+	// HTTPMethod is the HTTP method used to query the entity: GET, or POST to
+	// send the query parameters in a JSON body.
+	// Optional. Defaults to GET.
+	HTTPMethod string
+
+	// RequestBodyTemplate is the template of the JSON body of POST requests.
+	// Optional. See Config.RequestBodyTemplate.
+	RequestBodyTemplate string
+
+	// ObjectsJSONPath is the path of the list of objects in the response body.
+	// Optional. See Config.ObjectsJSONPath.
+	ObjectsJSONPath string
+
+	// DuplicateKeys selects how duplicate JSON keys in the response body are
+	// handled.
+	// Optional. See Config.DuplicateKeys.
+	DuplicateKeys string
+
+	// StartCursor is the cursor or offset of the first page, used if Cursor is
+	// not set.
+	// Optional. See Config.StartCursor.
+	StartCursor string
+
+	// VerifyResponseDigest enables the verification of the response body
+	// against the Digest or Content-MD5 response headers.
+	// Optional. See Config.VerifyResponseDigest.
+	VerifyResponseDigest bool
+
+	// AllowedContentTypes is the list of media types of response bodies that
+	// are parsed.
+	// Optional. See Config.AllowedContentTypes.
+	AllowedContentTypes []string
+
+	// PageSizeHeader is the name of the request header holding the page size.
+	// Optional. See Config.PageSizeHeader.
+	PageSizeHeader string
+
+	// CursorResponseField is the name of the response body field holding the
+	// cursor of the next page.
+	// Optional. See Config.CursorResponseField.
+	CursorResponseField string
+
+	// NextCursorJSONPath is the path of the response body field holding the
+	// cursor of the next page.
+	// Optional. See Config.NextCursorJSONPath.
+	NextCursorJSONPath string
+
+	// CursorHeader is the name of the response header holding the cursor of
+	// the next page.
+	// Optional. See Config.CursorHeader.
+	CursorHeader string
+
+	// CursorQueryParam is the name of the query parameter holding the cursor.
+	// Optional. See Config.CursorQueryParam.
+	CursorQueryParam string
+
+	// DisableKeepAlives disables the reuse of connections to the datasource.
+	// Optional. See Config.DisableKeepAlives.
+	DisableKeepAlives bool
+
+	// MultiStatus configures the parsing of multi-status responses.
+	// Optional. See Config.MultiStatus.
+	MultiStatus *MultiStatus
+
+	// BookmarkField is the name of the bookmark field in bookmark pagination.
+	// Optional. See Config.BookmarkField.
+	BookmarkField string
+
+	// CursorFromNextURL indicates whether the next cursor is extracted from the
+	// query of the URL of the next page.
+	// Optional. See Config.CursorFromNextURL.
+	CursorFromNextURL bool
+
+	// DebugDumpDir is the directory to which requests and responses are dumped.
+	// Optional. See Config.DebugDumpDir.
+	DebugDumpDir string
+
+	// DebugDumpMaxFiles is the maximum number of debug dump files written.
+	// Optional. See Config.DebugDumpMaxFiles.
+	DebugDumpMaxFiles int
+
+	// DebugDumpMaxBytes is the maximum size of each dumped body.
+	// Optional. See Config.DebugDumpMaxBytes.
+	DebugDumpMaxBytes int
+
+	// CompactCursors indicates whether composite cursors are compressed.
+	// Optional. See Config.CompactCursors.
+	CompactCursors bool
+
+	// MaxCursorLength is the maximum length of composite cursors.
+	// Optional. See Config.MaxCursorLength.
+	MaxCursorLength int
+
+	// StableSort indicates whether the objects are requested sorted by unique
+	// ID ascending when paginating by offset.
+	// Optional. See Config.StableSortEntities.
+	StableSort bool
+
+	// SortParam is the name of the query parameter holding the sort order.
+	// Optional. See Config.SortParam.
+	SortParam string
+
+	// SortBy is the sort order of the objects, set if they are requested
+	// ordered.
+	// Optional. See Config.SortBy.
+	SortBy string
+
+	// FieldsParam is the name of the query parameter listing Fields.
+	// Optional. See Config.FieldsParam.
+	FieldsParam string
+
+	// Fields are the external IDs of the requested attributes supported by the
+	// configured API version, sent if FieldsParam is set.
+	Fields []string
+
+	// RequestTimeout is the timeout of the request to the datasource.
+	// Optional. See Config.RequestTimeoutSeconds.
+	RequestTimeout time.Duration
+
+	// ProxyURL is the URL of the proxy through which requests are sent.
+	// Optional. See Config.ProxyURL.
+	ProxyURL string
+
+	// RootCAsPEM is the PEM-encoded bundle of trusted CA certificates.
+	// Optional. See Config.RootCAsPEM.
+	RootCAsPEM string
+
+	// AcceptHeader is the value of the Accept header.
+	// Optional. See Config.AcceptHeader.
+	AcceptHeader string
+
+	// ContentType is the value of the Content-Type header.
+	// Optional. See Config.ContentType.
+	ContentType string
+
+	// UserAgent is the value of the User-Agent header.
+	// Optional. See Config.UserAgent.
+	UserAgent string
+
+	// ExtraHeaders are additional headers sent with each request.
+	// Optional. See Config.ExtraHeaders.
+	ExtraHeaders map[string]string
+
+	// TeamIDs is the list of IDs of the teams whose services are returned.
+	// Optional. See Config.TeamIDs.
+	TeamIDs []string
+
+	// Since is the start of the time window of schedules.
+	// Optional. See Config.Since.
+	Since string
+
+	// Until is the end of the time window of schedules.
+	// Optional. See Config.Until.
+	Until string
+
+	// MaxResponseBytes is the maximum size of response bodies.
+	// Optional. See Config.MaxResponseBytes.
+	MaxResponseBytes int64
+
+	// IncidentStatuses is the list of statuses of the incidents to return.
+	// Optional. See Config.IncidentStatuses.
+	IncidentStatuses []string
+}
+
+// userAgent returns the User-Agent header of the requests to the datasource.
+func (r *Request) userAgent() string {
+	if r.UserAgent != "" {
+		return r.UserAgent
+	}
+
+	return DefaultUserAgent
+}
+
+// usesCompositeCursor returns whether the request's cursor is a composite
+// cursor, i.e. one created by encodeCursor.
+func (r *Request) usesCompositeCursor() bool {
+	return r.HybridPagination != "" || r.CursorCookie != "" || r.ReconcileTotalCount || len(r.Sources) > 0
+}
+
+// usesOffsetPagination returns whether the request paginates by offset, as
+// opposed to with a continuation token or cookie from the datasource.
+func (r *Request) usesOffsetPagination() bool {
+	return r.CursorCookie == "" && r.HybridPagination != HybridPaginationHeader &&
+		r.CursorResponseField == "" && r.NextCursorJSONPath == "" &&
+		r.PaginationMode != PaginationBookmark && r.PaginationMode != PaginationCursor &&
+		r.PaginationMode != PaginationHeader && r.PaginationMode != PaginationLinkHeader
 }
 
 // SCAFFOLDING #6 - pkg/adapter/client.go: Add/Remove/Update any fields to model the response from the SoR API.
@@ -81,4 +364,17 @@ type Request struct {
 type Response struct {
 	Objects []map[string]interface{} `json:"objects"` // List of objects (teams)
 	Cursor  string                   `json:"cursor"`  // Cursor for pagination
+
+	// RateLimit is the rate limit state returned by the datasource.
+	// Nil if the datasource returned no rate limit headers.
+	RateLimit *RateLimitInfo `json:"rateLimit,omitempty"`
+
+	// StatusCode is the HTTP status code of the datasource's response, to
+	// tell apart e.g. a genuinely empty 200 from a 204.
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// TotalCount is the total number of objects of the entity announced by
+	// the datasource, e.g. to report the progress of a sync.
+	// Nil if the datasource didn't announce it.
+	TotalCount *int64 `json:"totalCount,omitempty"`
 }
@@ -0,0 +1,142 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+)
+
+// FakeClient is a Client returning programmed responses, which records the
+// requests it receives.
+type FakeClient struct {
+	// Responses are returned in order by successive calls to GetPage. The last
+	// response is repeated once all have been returned.
+	Responses []FakeResponse
+
+	// Delay is the time each call to GetPage takes, during which other calls
+	// may run concurrently.
+	Delay time.Duration
+
+	mu       sync.Mutex
+	requests []*Request
+
+	// inFlight is the number of calls to GetPage in progress, overall and per
+	// entity external ID, and maxInFlight their maximum so far.
+	inFlight          int
+	maxInFlight       int
+	entityInFlight    map[string]int
+	maxEntityInFlight int
+}
+
+// FakeResponse is a response programmed in a FakeClient.
+type FakeResponse struct {
+	Response *Response
+	Err      *framework.Error
+}
+
+// GetPage records the request and returns the next programmed response.
+func (c *FakeClient) GetPage(_ context.Context, request *Request) (*Response, *framework.Error) {
+	if c.Delay > 0 {
+		c.track(request.EntityExternalID, 1)
+		time.Sleep(c.Delay)
+		c.track(request.EntityExternalID, -1)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, request)
+
+	if len(c.Responses) == 0 {
+		return &Response{}, nil
+	}
+
+	index := len(c.requests) - 1
+	if index >= len(c.Responses) {
+		index = len(c.Responses) - 1
+	}
+
+	return c.Responses[index].Response, c.Responses[index].Err
+}
+
+// track adds delta to the number of calls in progress for the given entity.
+func (c *FakeClient) track(entityExternalID string, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entityInFlight == nil {
+		c.entityInFlight = make(map[string]int)
+	}
+
+	c.inFlight += delta
+	c.entityInFlight[entityExternalID] += delta
+
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.maxEntityInFlight = max(c.maxEntityInFlight, c.entityInFlight[entityExternalID])
+}
+
+// MaxInFlight returns the maximum number of concurrent calls to GetPage so
+// far, overall and for any single entity. Only tracked if Delay is set.
+func (c *FakeClient) MaxInFlight() (overall, perEntity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.maxInFlight, c.maxEntityInFlight
+}
+
+// Requests returns the requests received so far, in order.
+func (c *FakeClient) Requests() []*Request {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*Request(nil), c.requests...)
+}
+
+// newTestRequest returns a valid GetPage request for the teams entity with
+// the id and name attributes, modified by the given functions.
+func newTestRequest(modifiers ...func(*framework.Request[Config])) *framework.Request[Config] {
+	request := &framework.Request[Config]{
+		Auth: &framework.DatasourceAuthCredentials{
+			HTTPAuthorization: "testtoken",
+		},
+		Config: &Config{
+			APIVersion: "v2",
+		},
+		Entity: framework.EntityConfig{
+			ExternalId: Teams,
+			Attributes: []*framework.AttributeConfig{
+				{
+					ExternalId: "id",
+					Type:       framework.AttributeTypeString,
+				},
+				{
+					ExternalId: "name",
+					Type:       framework.AttributeTypeString,
+				},
+			},
+		},
+		PageSize: 10,
+	}
+
+	for _, modify := range modifiers {
+		modify(request)
+	}
+
+	return request
+}
@@ -15,8 +15,29 @@
 package adapter
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HybridPaginationOffset sends the computed offset to request the next
+	// page in hybrid pagination.
+	HybridPaginationOffset = "offset"
+
+	// HybridPaginationHeader sends the token returned in the X-Next-Page
+	// response header to request the next page in hybrid pagination.
+	HybridPaginationHeader = "header"
 )
 
 // Config is the optional configuration passed in each GetPage calls to the
@@ -27,6 +48,529 @@ type Config struct {
 
 	// Example config field.
 	APIVersion string `json:"apiVersion,omitempty"`
+
+	// APIBaseURL is the base URL of the datasource's API, e.g. to point the
+	// adapter at a sandbox.
+	// Optional. If not set, the request's address is used, or else
+	// DefaultAPIBaseURL.
+	APIBaseURL string `json:"apiBaseUrl,omitempty"`
+
+	// APIVersionInPath indicates whether APIVersion is inserted in the path of
+	// requests after the base URL, e.g. "https://api.example.com/v2/teams",
+	// for datasources that version their API by path.
+	// Optional. If not set, the API version is not part of the path.
+	APIVersionInPath bool `json:"apiVersionInPath,omitempty"`
+
+	// CaseInsensitiveAttributes indicates whether the keys of the objects
+	// returned by the datasource are matched to the external IDs of the
+	// requested attributes regardless of case, e.g. to parse an `Email` key
+	// into an `email` attribute.
+	CaseInsensitiveAttributes bool `json:"caseInsensitiveAttributes,omitempty"`
+
+	// ValidateAttributes indicates whether the requested attributes of the
+	// entities supported by the adapter are checked against the fields known
+	// to exist in the datasource's objects, so that requests for misspelled
+	// attributes fail instead of returning null values. Ad hoc entities are
+	// not checked.
+	// Optional. If not set, any attribute may be requested.
+	ValidateAttributes bool `json:"validateAttributes,omitempty"`
+
+	// HybridPagination enables pagination for datasources that return both
+	// an X-Next-Page header and expect an offset, by tracking both in the
+	// cursor. The value selects which one is sent as the `offset` query
+	// parameter: HybridPaginationOffset or HybridPaginationHeader.
+	// Optional. If not set, the X-Next-Page header is used as the cursor.
+	HybridPagination string `json:"hybridPagination,omitempty"`
+
+	// TLSPinnedSHA256 is the set of hex-encoded SHA-256 fingerprints of the
+	// TLS leaf certificates accepted from the datasource. If set, the
+	// certificate is trusted only if its fingerprint is in this set, instead
+	// of being verified against trusted CAs. Colon separators are allowed.
+	TLSPinnedSHA256 []string `json:"tlsPinnedSha256,omitempty"`
+
+	// SyntheticIDFields is the list of object fields hashed to compute a
+	// synthetic unique ID for objects returned without one, for datasources
+	// whose objects have no unique identifier.
+	// Optional. If not set, no synthetic IDs are computed.
+	SyntheticIDFields []string `json:"syntheticIdFields,omitempty"`
+
+	// DeduplicateByID indicates whether objects returned several times within
+	// a page, e.g. by an eventually consistent datasource, are deduplicated by
+	// unique ID. The first occurrence is kept.
+	// Optional. Defaults to false.
+	DeduplicateByID bool `json:"deduplicateById,omitempty"`
+
+	// GzipRequestBody indicates whether the datasource accepts gzip-compressed
+	// request bodies. If true, the bodies of POST-query requests are
+	// compressed and sent with a `Content-Encoding: gzip` header.
+	GzipRequestBody bool `json:"gzipRequestBody,omitempty"`
+
+	// CursorCookie is the name of the cookie in which the datasource returns
+	// the pagination continuation. If set, the cookie's value is carried in
+	// the cursor and sent back as a cookie to request the next page.
+	// Optional. Cannot be used with HybridPagination.
+	CursorCookie string `json:"cursorCookie,omitempty"`
+
+	// ReconcileTotalCount enables the detection of truncated syncs: the number
+	// of objects returned across all pages is tracked in the cursor and a
+	// warning is logged if it diverges from the total announced by the
+	// datasource on the last page by more than ReconcileTolerancePercent.
+	ReconcileTotalCount bool `json:"reconcileTotalCount,omitempty"`
+
+	// ReconcileTolerancePercent is the tolerated divergence between the number
+	// of objects returned and the announced total, in percent of the total.
+	// Optional. Defaults to 0, i.e. any divergence is reported.
+	ReconcileTolerancePercent float64 `json:"reconcileTolerancePercent,omitempty"`
+
+	// AuthMode selects how requests are authenticated with the datasource:
+	//   - AuthModeToken: the token in the request's auth credentials is sent
+	//     as an API token.
+	//   - AuthModeBasic: the username and password in the request's auth
+	//     credentials are sent with HTTP Basic auth.
+	//   - AuthModeOAuth2TokenExchange: the token in the request's auth
+	//     credentials is exchanged at TokenURL for an access token, cf. RFC 8693.
+	//   - AuthModeOAuth2ClientCredentials: an access token is obtained at
+	//     TokenURL with ClientID and ClientSecret, cf. RFC 6749, and renewed if
+	//     the datasource rejects it.
+	// Optional. Defaults to AuthModeToken.
+	AuthMode string `json:"authMode,omitempty"`
+
+	// AuthToken is the value of the Authorization header sent as the API
+	// token, e.g. "Token token=<key>", used only if the request's auth
+	// credentials hold no HTTP authorization, which takes precedence.
+	// Optional. If not set, the request's auth credentials must hold the token
+	// in AuthModeToken and AuthModeOAuth2TokenExchange.
+	AuthToken string `json:"authToken,omitempty"`
+
+	// AuthScheme is the scheme of the Authorization header sending the API
+	// token in AuthModeToken: AuthSchemeToken, AuthSchemeBearer or
+	// AuthSchemeBasic. A token already prefixed with the scheme is sent as is.
+	// Optional. Defaults to AuthSchemeToken.
+	AuthScheme string `json:"authScheme,omitempty"`
+
+	// TokenURL is the URL of the OAuth2 token endpoint.
+	// Required if AuthMode is AuthModeOAuth2TokenExchange or
+	// AuthModeOAuth2ClientCredentials.
+	TokenURL string `json:"tokenUrl,omitempty"`
+
+	// ClientID is the OAuth2 client ID.
+	// Required if AuthMode is AuthModeOAuth2ClientCredentials.
+	ClientID string `json:"clientId,omitempty"`
+
+	// ClientSecret is the OAuth2 client secret.
+	// Required if AuthMode is AuthModeOAuth2ClientCredentials.
+	ClientSecret string `json:"clientSecret,omitempty"`
+
+	// SubjectTokenType is the type of the subject token in token exchange
+	// requests.
+	// Optional. Defaults to DefaultSubjectTokenType.
+	SubjectTokenType string `json:"subjectTokenType,omitempty"`
+
+	// Audience is the logical name of the datasource for which access tokens
+	// are requested via token exchange.
+	// Optional.
+	Audience string `json:"audience,omitempty"`
+
+	// MaxRetries is the maximum number of times a request to the datasource is
+	// retried if it fails with a transport error or a transient status code,
+	// i.e. 429, 502, 503 or 504. Retries are delayed as requested in the
+	// Retry-After response header, or else with exponential backoff, and stop
+	// at the caller's deadline. Each attempt times out after the request
+	// timeout.
+	// Optional. Defaults to 0, i.e. requests are not retried.
+	MaxRetries int `json:"maxRetries,omitempty"`
+
+	// RetryBaseDelayMillis is the delay before the first retry of a failed
+	// request, in milliseconds, when the datasource doesn't request one. The
+	// delay doubles for every subsequent retry.
+	// Optional. Defaults to DefaultRetryBaseDelay.
+	RetryBaseDelayMillis int `json:"retryBaseDelayMillis,omitempty"`
+
+	// PaginationMode is the pagination style of the datasource:
+	// PaginationOffset, PaginationCursor, PaginationHeader,
+	// PaginationLinkHeader, PaginationAtlassian or PaginationBookmark.
+	// Optional. Defaults to PaginationOffset, i.e. the datasource paginates
+	// with an `offset` query parameter and returns the next cursor in the
+	// X-Next-Page header.
+	PaginationMode PaginationType `json:"paginationMode,omitempty"`
+
+	// AtlassianPagination configures the names of the fields used if
+	// PaginationMode is PaginationAtlassian.
+	// Optional. If not set, Atlassian's names are used.
+	AtlassianPagination *AtlassianPagination `json:"atlassianPagination,omitempty"`
+
+	// LenientParsing indicates whether objects that fail to be parsed are
+	// skipped instead of failing the whole page.
+	LenientParsing bool `json:"lenientParsing,omitempty"`
+
+	// MaxSkippedObjectsPercent is the maximum percentage of the objects in a
+	// page that may be skipped with LenientParsing before the page fails.
+	// Optional. Defaults to 0, i.e. any skipped object fails the page.
+	MaxSkippedObjectsPercent float64 `json:"maxSkippedObjectsPercent,omitempty"`
+
+	// EntitySources maps the external ID of an entity to the list of endpoint
+	// paths whose objects are merged into the entity's pages, e.g. to combine
+	// `/users` and `/external_users` into one entity. The endpoints are
+	// queried one after the other, and objects with a unique ID already
+	// returned from a previous endpoint are dropped. Pages fail once more
+	// than 10000 objects with a unique ID have been returned.
+	// Optional. If not set for an entity, it is queried from its external ID.
+	EntitySources map[string][]string `json:"entitySources,omitempty"`
+
+	// EntityHTTPMethods maps the external ID of an entity to the HTTP method
+	// used to query it: GET, or POST for datasources that require a POST
+	// search, in which case the query parameters are sent in a JSON body.
+	// Optional. If not set for an entity, it is queried with HTTPMethod.
+	EntityHTTPMethods map[string]string `json:"entityHttpMethods,omitempty"`
+
+	// HTTPMethod is the HTTP method used to query the entities that are not
+	// set in EntityHTTPMethods: GET, or POST for datasources that require a
+	// POST search.
+	// Optional. Defaults to GET.
+	HTTPMethod string `json:"httpMethod,omitempty"`
+
+	// RequestBodyTemplate is the JSON body of POST search requests, in which
+	// the CursorPlaceholder and PageSizePlaceholder placeholders are replaced
+	// with the cursor of the page, as a JSON string or null for the first
+	// page, and the page size, e.g.
+	// `{"query": "*", "cursor": {{cursor}}, "limit": {{pageSize}}}`.
+	// The cursor is then sent in the body only, and not in the query string.
+	// Optional. If not set, the query parameters are sent in the JSON body.
+	RequestBodyTemplate string `json:"requestBodyTemplate,omitempty"`
+
+	// ObjectsJSONPath is the JSONPath-style path of the list of objects in the
+	// datasource's responses, for objects nested in the response body, e.g.
+	// "result.groups[*].teams" to gather the teams of all groups. Array
+	// wildcards and indices are supported. A JSON Pointer, e.g.
+	// "/data/results/items", is also accepted.
+	// Optional. If not set, the objects are read from the top-level list.
+	ObjectsJSONPath string `json:"objectsJsonPath,omitempty"`
+
+	// DuplicateKeys enables the detection of duplicate JSON keys in response
+	// bodies, of which only the last value would otherwise be kept silently:
+	// DuplicateKeysWarn logs a warning, and DuplicateKeysError fails the page.
+	// Optional. If not set, duplicate keys are not detected.
+	DuplicateKeys string `json:"duplicateKeys,omitempty"`
+
+	// StartCursor is the cursor of the first page of every entity, to start
+	// syncs from a known point instead of the beginning, e.g. when re-ingesting.
+	// Its meaning depends on the pagination type: it is the offset for offset
+	// pagination without CursorQueryParam, offset hybrid pagination and
+	// Atlassian pagination, the cookie value for cookie pagination, and the
+	// continuation token otherwise.
+	// Optional. If not set, syncs start from the beginning.
+	StartCursor string `json:"startCursor,omitempty"`
+
+	// VerifyResponseDigest enables the verification of the integrity of response
+	// bodies against the digests sent by the datasource in the Digest (sha-256,
+	// sha-512 or md5) or Content-MD5 response headers. Pages without a digest or
+	// with a mismatching digest fail.
+	// Optional. If not set, response digests are not verified.
+	VerifyResponseDigest bool `json:"verifyResponseDigest,omitempty"`
+
+	// AllowedContentTypes is the list of media types of response bodies that
+	// are parsed, e.g. ["application/json"]. Media types are matched ignoring
+	// case and parameters such as the charset, and may use a wildcard subtype,
+	// e.g. "application/*". Responses with any other content type fail.
+	// Optional. If not set, response bodies are parsed whatever their type.
+	AllowedContentTypes []string `json:"allowedContentTypes,omitempty"`
+
+	// PageSizeHeader is the name of the request header in which the page size
+	// is sent, e.g. "X-Limit", for datasources which don't accept it as a query
+	// parameter.
+	// Optional. If not set, the page size is sent as a query parameter.
+	PageSizeHeader string `json:"pageSizeHeader,omitempty"`
+
+	// CursorResponseField is the name of the top-level response body field
+	// holding the cursor of the next page, e.g. "next_cursor". A missing or null
+	// field indicates the last page.
+	// Optional. If not set, the cursor is read from the CursorHeader header.
+	CursorResponseField string `json:"cursorResponseField,omitempty"`
+
+	// NextCursorJSONPath is the path of the response body field holding the
+	// cursor of the next page, for cursors nested in the body, e.g.
+	// "pagination.next" or "/pagination/next", in the syntax of ObjectsJSONPath
+	// without array wildcards. A missing, null or empty value indicates the
+	// last page.
+	// Optional. If not set, see CursorResponseField.
+	NextCursorJSONPath string `json:"nextCursorJsonPath,omitempty"`
+
+	// CursorHeader is the name of the response header holding the cursor of
+	// the next page, e.g. "X-Cursor". If it is "Link", the cursor is extracted
+	// from the CursorQueryParam query parameter of the URL of the link with
+	// the "next" relation, cf. RFC 8288.
+	// Optional. Defaults to DefaultCursorHeader.
+	CursorHeader string `json:"cursorHeader,omitempty"`
+
+	// CursorQueryParam is the name of the query parameter in which the cursor
+	// is sent back to the datasource, e.g. "cursor", or "page" for datasources
+	// returning the next page number in the cursor header.
+	// Optional. If not set, the cursor is sent as the "offset" parameter, or
+	// DefaultCursorQueryParam in cursor pagination.
+	CursorQueryParam string `json:"cursorQueryParam,omitempty"`
+
+	// DisableKeepAlives disables the reuse of connections to the datasource, as
+	// a workaround for datasources which mishandle keep-alive and cause
+	// intermittent EOF errors on stale connections.
+	// Every request then opens a new connection, including a new TLS handshake,
+	// which increases latency and load on the datasource, so this should only
+	// be set for datasources known to need it.
+	// Optional. If not set, connections are reused.
+	DisableKeepAlives bool `json:"disableKeepAlives,omitempty"`
+
+	// EmptyStrings normalizes the attribute values of datasources which don't
+	// represent missing values as SGNL expects: EmptyStringToNull converts
+	// empty strings into nulls, and NullToEmptyString converts nulls into empty
+	// strings for string attributes.
+	// Optional. If not set, values are left unchanged.
+	EmptyStrings string `json:"emptyStrings,omitempty"`
+
+	// EmptyStringAttributes is the list of external IDs of the attributes to
+	// normalize according to EmptyStrings.
+	// Optional. If not set, all the requested attributes are normalized.
+	EmptyStringAttributes []string `json:"emptyStringAttributes,omitempty"`
+
+	// MultiStatus enables the parsing of `207 Multi-Status` responses, in which
+	// each element of the list of objects has its own status. Successful
+	// elements are returned as objects, and failed elements are skipped with a
+	// warning, up to a threshold.
+	// Optional. If not set, 207 responses are parsed like 200 responses.
+	MultiStatus *MultiStatus `json:"multiStatus,omitempty"`
+
+	// BookmarkField is the name of the response and request body field holding
+	// the bookmark if PaginationMode is PaginationBookmark.
+	// Optional. Defaults to DefaultBookmarkField.
+	BookmarkField string `json:"bookmarkField,omitempty"`
+
+	// CursorFromNextURL indicates whether the datasource returns the URL of the
+	// next page, in the X-Next-Page header or in CursorResponseField, instead of
+	// a cursor. The cursor is then extracted from the CursorQueryParam query
+	// parameter of that URL, e.g. to continue offset pagination. A URL without
+	// that parameter indicates the last page.
+	// Optional. If not set, the returned value is used as the cursor.
+	CursorFromNextURL bool `json:"cursorFromNextUrl,omitempty"`
+
+	// DebugDumpDir is the directory to which the bodies of requests to the
+	// datasource and of its responses are written, one file per request named
+	// after the time and entity, to investigate parsing issues. Credentials are
+	// redacted from the files, but the objects are not, so this should only be
+	// set temporarily.
+	// Optional. If not set, nothing is written.
+	DebugDumpDir string `json:"debugDumpDir,omitempty"`
+
+	// DebugDumpMaxFiles is the maximum number of files written to DebugDumpDir
+	// by the adapter process.
+	// Optional. Defaults to DefaultDebugDumpMaxFiles.
+	DebugDumpMaxFiles int `json:"debugDumpMaxFiles,omitempty"`
+
+	// DebugDumpMaxBytes is the maximum size in bytes of each body written to
+	// DebugDumpDir. Longer bodies are truncated.
+	// Optional. Defaults to DefaultDebugDumpMaxBytes.
+	DebugDumpMaxBytes int `json:"debugDumpMaxBytes,omitempty"`
+
+	// EntityAPIVersions maps entity external IDs to the range of API versions
+	// in which the entities exist, for entities that don't exist in all
+	// versions. Requests for an entity that doesn't exist in APIVersion fail.
+	// Optional. If not set for an entity, the range built into the adapter is
+	// used.
+	EntityAPIVersions map[string]APIVersionRange `json:"entityApiVersions,omitempty"`
+
+	// AttributeMinAPIVersions maps entity external IDs to the minimum API
+	// versions of their attributes that don't exist in all versions, keyed by
+	// attribute external ID. Attributes requiring a newer version than
+	// APIVersion are skipped with a warning.
+	// Optional. If not set for an entity, the versions built into the adapter
+	// are used.
+	AttributeMinAPIVersions map[string]map[string]string `json:"attributeMinApiVersions,omitempty"`
+
+	// CompactCursors indicates whether the cursors combining several values,
+	// e.g. with hybrid pagination or multiple sources, are compressed to keep
+	// them under the size limits of the ingestion service.
+	// Optional. If not set, cursors are not compressed.
+	CompactCursors bool `json:"compactCursors,omitempty"`
+
+	// MaxCursorLength is the maximum length of the cursors combining several
+	// values, after compaction if enabled. Pages whose cursor exceeds it fail.
+	// Optional. If not set, the length of cursors is not limited.
+	MaxCursorLength int `json:"maxCursorLength,omitempty"`
+
+	// StableSortEntities is the list of external IDs of the entities requested
+	// sorted by unique ID ascending when paginating by offset, so that objects
+	// are not skipped or duplicated if objects are created or deleted during
+	// the sync. The sort order is sent as "<unique ID attribute>:asc" in the
+	// SortParam query parameter. Only set for datasources which support it.
+	// Optional. If not set, no sort order is requested.
+	StableSortEntities []string `json:"stableSortEntities,omitempty"`
+
+	// SortParam is the name of the query parameter holding the sort order of
+	// StableSortEntities.
+	// Optional. Defaults to DefaultSortParam.
+	SortParam string `json:"sortParam,omitempty"`
+
+	// SortBy is the sort order sent in the SortParam query parameter when the
+	// objects are requested ordered, e.g. "created_at:asc".
+	// Optional. If not set, ordered requests are rejected.
+	SortBy string `json:"sortBy,omitempty"`
+
+	// FieldsParam is the name of the query parameter listing the requested
+	// attributes, comma-separated, e.g. "fields". Attributes skipped for the
+	// configured API version are not listed.
+	// Optional. If not set, the requested attributes are not sent.
+	FieldsParam string `json:"fieldsParam,omitempty"`
+
+	// AllowAdHocEntities indicates whether entities that are not supported by
+	// the adapter may be queried if they are defined in AdHocEntities, e.g. for
+	// exploratory ingestion. A warning is logged for each such request.
+	// Optional. If not set, requests for unsupported entities fail.
+	AllowAdHocEntities bool `json:"allowAdHocEntities,omitempty"`
+
+	// AdHocEntities maps the external IDs of entities that are not supported
+	// by the adapter to their definition, if AllowAdHocEntities is set.
+	// Optional.
+	AdHocEntities map[string]AdHocEntity `json:"adHocEntities,omitempty"`
+
+	// RequestTimeoutSeconds is the timeout of each attempt to send a request to
+	// the datasource, and of each token request, in seconds, e.g. for
+	// endpoints that are slow under load. A timeout set in the context with
+	// WithRequestTimeout takes precedence. Either way, it is bounded by the
+	// timeout of the adapter's HTTP client.
+	// Optional. If not set or 0, defaults to DefaultRequestTimeout.
+	RequestTimeoutSeconds int `json:"requestTimeoutSeconds,omitempty"`
+
+	// IncidentStatuses is the list of statuses of the incidents to ingest,
+	// among IncidentStatusTriggered, IncidentStatusAcknowledged and
+	// IncidentStatusResolved, sent as repeated statuses[] query parameters.
+	// Optional. If not set, incidents of all statuses are ingested.
+	IncidentStatuses []string `json:"incidentStatuses,omitempty"`
+
+	// TeamIDs is the list of IDs of the teams whose services are ingested,
+	// sent as repeated team_ids[] query parameters. Other entities are not
+	// filtered by team.
+	// Optional. If not set, the services of all teams are ingested.
+	TeamIDs []string `json:"teamIds,omitempty"`
+
+	// IncidentID is the ID of the incident whose log entries are ingested, from
+	// the /incidents/{id}/log_entries endpoint. Other entities are not scoped.
+	// Optional. If not set, the log entries of all incidents are ingested.
+	IncidentID string `json:"incidentId,omitempty"`
+
+	// Since is the start of the time window in which schedules are expanded
+	// into on-call entries, as an RFC 3339 datetime, sent as the since query
+	// parameter. Other entities are not filtered by time.
+	// Optional. If not set, the datasource's default is used.
+	Since string `json:"since,omitempty"`
+
+	// Until is the end of the time window in which schedules are expanded into
+	// on-call entries, as an RFC 3339 datetime after Since, sent as the until
+	// query parameter.
+	// Optional. If not set, the datasource's default is used.
+	Until string `json:"until,omitempty"`
+
+	// MaxResponseBytes is the maximum size in bytes of a response body read
+	// from the datasource, so that a misbehaving datasource cannot exhaust the
+	// adapter's memory. Pages with larger bodies fail.
+	// Optional. Defaults to DefaultMaxResponseBytes.
+	MaxResponseBytes int64 `json:"maxResponseBytes,omitempty"`
+
+	// DisableJSONPathAttributeNames disables JSONPath attribute names, e.g.
+	// "$.contact.email", for datasources whose field names contain characters
+	// that would be misinterpreted as JSONPath.
+	// Optional. If not set, attribute names may be JSONPath expressions.
+	DisableJSONPathAttributeNames bool `json:"disableJsonPathAttributeNames,omitempty"`
+
+	// DateTimeFormats is the list of layouts of the datetime values returned by
+	// the datasource, tried in order.
+	// Optional. If not set, RFC 3339 datetimes and dates are supported.
+	DateTimeFormats []DateTimeFormat `json:"dateTimeFormats,omitempty"`
+
+	// ExtraHeaders maps the names of additional headers sent with each request
+	// to the datasource to their values, e.g. a "From" header or a partner API
+	// key. They may override the default Accept and Content-Type headers, but
+	// not the reserved headers set by the adapter, e.g. Authorization. Their
+	// values are redacted from logs, error messages and debug dumps.
+	// Optional.
+	ExtraHeaders map[string]string `json:"extraHeaders,omitempty"`
+
+	// AcceptHeader is the Accept header sent to the datasource, e.g. to target
+	// another version of the API.
+	// Optional. Defaults to DefaultAcceptHeader.
+	AcceptHeader string `json:"acceptHeader,omitempty"`
+
+	// ContentType is the Content-Type header sent to the datasource.
+	// Optional. Defaults to DefaultContentType.
+	ContentType string `json:"contentType,omitempty"`
+
+	// UserAgent is the User-Agent header sent to the datasource and its token
+	// endpoint, by which the datasource may identify the adapter's traffic.
+	// Optional. Defaults to DefaultUserAgent.
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// DefaultPageSize is the page size requested from the datasource if the
+	// GetPage request doesn't set one. It must not exceed MaxPageSize.
+	// Optional. Defaults to MaxPageSize.
+	DefaultPageSize int64 `json:"defaultPageSize,omitempty"`
+
+	// ProxyURL is the URL of the proxy through which requests are sent to the
+	// datasource, e.g. "http://proxy.example.com:3128".
+	// Optional. If not set, the proxy is read from the environment, e.g. from
+	// HTTPS_PROXY.
+	ProxyURL string `json:"proxyUrl,omitempty"`
+
+	// RootCAsPEM is the PEM-encoded bundle of the CA certificates trusted to
+	// verify the datasource's TLS certificate, e.g. a private CA.
+	// Optional. If not set, the system's trusted CAs are used.
+	RootCAsPEM string `json:"rootCasPem,omitempty"`
+}
+
+// DateTimeFormat is the layout of datetime values returned by the datasource.
+type DateTimeFormat struct {
+	// Format is the layout of the values, as expected by time.Parse, e.g.
+	// "2006-01-02 15:04:05".
+	Format string `json:"format"`
+
+	// HasTimeZone indicates whether the layout includes a time zone. Values
+	// without a time zone are parsed as UTC.
+	HasTimeZone bool `json:"hasTimeZone,omitempty"`
+}
+
+// AdHocEntity defines an entity that is not supported by the adapter.
+type AdHocEntity struct {
+	// Endpoint is the path of the endpoint to query the entity, relative to
+	// the datasource's base URL.
+	Endpoint string `json:"endpoint"`
+
+	// UniqueIDAttribute is the external ID of the entity's unique ID attribute.
+	UniqueIDAttribute string `json:"uniqueIdAttribute"`
+
+	// ObjectsKey is the key of the list of the entity's objects in response
+	// bodies.
+	// Optional. Defaults to the entity's external ID.
+	ObjectsKey string `json:"objectsKey,omitempty"`
+
+	// AdditionalObjectsKeys are the keys of further lists of the entity's
+	// objects in response bodies, merged in order after those of ObjectsKey.
+	// Optional.
+	AdditionalObjectsKeys []string `json:"additionalObjectsKeys,omitempty"`
+}
+
+// UnmarshalJSON unmarshals a Config from the given JSON object, rejecting
+// unknown fields, e.g. misspelled ones, which would otherwise be silently
+// ignored and leave the intended field unset.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	// config has the fields of Config but not its methods, to avoid recursion.
+	type config Config
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var parsed config
+	if err := decoder.Decode(&parsed); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	*c = Config(parsed)
+
+	return nil
 }
 
 // ValidateConfig validates that a Config received in a GetPage call is valid.
@@ -38,7 +582,280 @@ func (c *Config) Validate(_ context.Context) error {
 		return errors.New("request contains no config")
 	case c.APIVersion == "":
 		return errors.New("apiVersion is not set")
+	case c.APIBaseURL != "" && !validBaseURL(c.APIBaseURL):
+		return errors.New("apiBaseUrl must be an absolute http or https URL")
+	case c.HybridPagination != "" &&
+		c.HybridPagination != HybridPaginationOffset && c.HybridPagination != HybridPaginationHeader:
+		return fmt.Errorf("hybridPagination must be %q or %q", HybridPaginationOffset, HybridPaginationHeader)
+	case c.HybridPagination != "" && c.CursorCookie != "":
+		return errors.New("hybridPagination and cursorCookie cannot both be set")
+	case c.ReconcileTolerancePercent < 0:
+		return errors.New("reconcileTolerancePercent must not be negative")
+	case c.AuthMode != "" && c.AuthMode != AuthModeToken && c.AuthMode != AuthModeBasic &&
+		c.AuthMode != AuthModeOAuth2TokenExchange && c.AuthMode != AuthModeOAuth2ClientCredentials:
+		return fmt.Errorf("authMode must be %q, %q, %q or %q",
+			AuthModeToken, AuthModeBasic, AuthModeOAuth2TokenExchange, AuthModeOAuth2ClientCredentials)
+	case c.AuthScheme != "" && c.AuthScheme != AuthSchemeToken && c.AuthScheme != AuthSchemeBearer &&
+		c.AuthScheme != AuthSchemeBasic:
+		return fmt.Errorf("authScheme must be %q, %q or %q", AuthSchemeToken, AuthSchemeBearer, AuthSchemeBasic)
+	case c.AuthScheme != "" && c.AuthMode != "" && c.AuthMode != AuthModeToken:
+		return errors.New("authScheme can only be set if authMode is token")
+	case (c.AuthMode == AuthModeOAuth2TokenExchange || c.AuthMode == AuthModeOAuth2ClientCredentials) &&
+		c.TokenURL == "":
+		return errors.New("tokenUrl is not set")
+	case c.AuthMode == AuthModeOAuth2ClientCredentials && (c.ClientID == "" || c.ClientSecret == ""):
+		return errors.New("clientId and clientSecret must be set")
+	case c.MaxRetries < 0:
+		return errors.New("maxRetries must not be negative")
+	case c.RetryBaseDelayMillis < 0:
+		return errors.New("retryBaseDelayMillis must not be negative")
+	case c.PaginationMode != "" && c.PaginationMode != PaginationOffset && c.PaginationMode != PaginationCursor &&
+		c.PaginationMode != PaginationHeader && c.PaginationMode != PaginationLinkHeader &&
+		c.PaginationMode != PaginationAtlassian && c.PaginationMode != PaginationBookmark:
+		return fmt.Errorf("paginationMode must be %q, %q, %q, %q, %q or %q", PaginationOffset, PaginationCursor,
+			PaginationHeader, PaginationLinkHeader, PaginationAtlassian, PaginationBookmark)
+	case c.PaginationMode != "" && (c.HybridPagination != "" || c.CursorCookie != ""):
+		return errors.New("paginationMode cannot be set with hybridPagination or cursorCookie")
+	case c.MaxSkippedObjectsPercent < 0 || c.MaxSkippedObjectsPercent > 100:
+		return errors.New("maxSkippedObjectsPercent must be between 0 and 100")
+	case !validHTTPMethods(c.EntityHTTPMethods):
+		return errors.New("entityHttpMethods must only contain GET or POST methods")
+	case c.HTTPMethod != "" && !validHTTPMethods(map[string]string{"": c.HTTPMethod}):
+		return errors.New("httpMethod must be GET or POST")
+	case c.RequestBodyTemplate != "" && !validRequestBodyTemplate(c.RequestBodyTemplate):
+		return errors.New("requestBodyTemplate must be valid JSON once its placeholders are replaced")
+	case c.ObjectsJSONPath != "" && !validObjectPath(c.ObjectsJSONPath):
+		return errors.New("objectsJsonPath is not a valid path")
+	case c.DuplicateKeys != "" && c.DuplicateKeys != DuplicateKeysWarn && c.DuplicateKeys != DuplicateKeysError:
+		return fmt.Errorf("duplicateKeys must be %q or %q", DuplicateKeysWarn, DuplicateKeysError)
+	case c.StartCursor != "" && c.startsFromOffset() && !validOffset(c.StartCursor):
+		return errors.New("startCursor must be a non-negative offset")
+	case !validContentTypes(c.AllowedContentTypes):
+		return errors.New("allowedContentTypes must only contain valid media types")
+	case c.CursorResponseField != "" && (c.PaginationMode == PaginationHeader ||
+		c.PaginationMode == PaginationLinkHeader || c.PaginationMode == PaginationAtlassian ||
+		c.PaginationMode == PaginationBookmark):
+		return fmt.Errorf("cursorResponseField cannot be set with paginationMode %q", c.PaginationMode)
+	case c.NextCursorJSONPath != "" && !validCursorPath(c.NextCursorJSONPath):
+		return errors.New("nextCursorJsonPath is not a valid path to a single value")
+	case c.NextCursorJSONPath != "" && c.CursorResponseField != "":
+		return errors.New("nextCursorJsonPath and cursorResponseField cannot both be set")
+	case c.NextCursorJSONPath != "" && (c.PaginationMode == PaginationHeader ||
+		c.PaginationMode == PaginationLinkHeader || c.PaginationMode == PaginationAtlassian ||
+		c.PaginationMode == PaginationBookmark):
+		return fmt.Errorf("nextCursorJsonPath cannot be set with paginationMode %q", c.PaginationMode)
+	case c.EmptyStrings != "" && c.EmptyStrings != EmptyStringToNull && c.EmptyStrings != NullToEmptyString:
+		return fmt.Errorf("emptyStrings must be %q or %q", EmptyStringToNull, NullToEmptyString)
+	case c.MultiStatus != nil && (c.MultiStatus.MaxFailedPercent < 0 || c.MultiStatus.MaxFailedPercent > 100):
+		return errors.New("multiStatus.maxFailedPercent must be between 0 and 100")
+	case c.CursorFromNextURL && (c.PaginationMode == PaginationLinkHeader ||
+		c.PaginationMode == PaginationAtlassian || c.PaginationMode == PaginationBookmark):
+		return fmt.Errorf("cursorFromNextUrl cannot be set with paginationMode %q", c.PaginationMode)
+	case c.DebugDumpMaxFiles < 0 || c.DebugDumpMaxBytes < 0:
+		return errors.New("debugDumpMaxFiles and debugDumpMaxBytes must not be negative")
+	case c.MaxCursorLength < 0:
+		return errors.New("maxCursorLength must not be negative")
+	case !validAdHocEntities(c.AdHocEntities):
+		return errors.New("adHocEntities must all have an endpoint and a uniqueIdAttribute")
+	case c.RequestTimeoutSeconds < 0:
+		return errors.New("requestTimeoutSeconds must not be negative")
+	case c.DefaultPageSize < 0 || c.DefaultPageSize > MaxPageSize:
+		return fmt.Errorf("defaultPageSize must be between 0 and %d", MaxPageSize)
+	case c.ProxyURL != "" && !validProxyURL(c.ProxyURL):
+		return errors.New("proxyUrl must be an absolute http, https or socks5 URL")
+	case c.RootCAsPEM != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(c.RootCAsPEM)):
+		return errors.New("rootCasPem must contain PEM-encoded certificates")
+	case c.MaxResponseBytes < 0:
+		return errors.New("maxResponseBytes must not be negative")
+	case c.AcceptHeader != "" && !validContentTypes(strings.Split(c.AcceptHeader, ",")):
+		return errors.New("acceptHeader must be a list of valid media types")
+	case c.ContentType != "" && !validContentTypes([]string{c.ContentType}):
+		return errors.New("contentType must be a valid media type")
+	case c.UserAgent != "" && (strings.TrimSpace(c.UserAgent) == "" || strings.ContainsAny(c.UserAgent, "\r\n")):
+		return errors.New("userAgent must not be blank or contain line breaks")
+	case !validHeaderNames(c.ExtraHeaders):
+		return errors.New("extraHeaders must only contain valid header names")
+	case reservedHeader(c.ExtraHeaders) != "":
+		return fmt.Errorf("extraHeaders cannot set the reserved header %s", reservedHeader(c.ExtraHeaders))
+	case !validDateTimeFormats(c.DateTimeFormats):
+		return errors.New("dateTimeFormats must all have a format")
+	case c.IncidentID != "" && !validIncidentID(c.IncidentID):
+		return errors.New("incidentId must not be blank or contain whitespace or URL delimiters")
+	case !validTimeWindow(c.Since, c.Until):
+		return errors.New("since and until must be RFC 3339 datetimes, with until after since")
+	case !validIncidentStatuses(c.IncidentStatuses):
+		return fmt.Errorf("incidentStatuses must only contain %q, %q or %q",
+			IncidentStatusTriggered, IncidentStatusAcknowledged, IncidentStatusResolved)
+	case !validFingerprints(c.TLSPinnedSHA256):
+		return errors.New("tlsPinnedSha256 must only contain hex-encoded SHA-256 fingerprints")
 	default:
 		return nil
 	}
 }
+
+// validFingerprints returns whether all the given fingerprints are hex-encoded
+// SHA-256 fingerprints.
+func validFingerprints(fingerprints []string) bool {
+	for _, fingerprint := range fingerprints {
+		decoded, err := hex.DecodeString(normalizeFingerprint(fingerprint))
+		if err != nil || len(decoded) != sha256.Size {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validHTTPMethods returns whether all the given HTTP methods are supported to
+// query entities.
+func validHTTPMethods(methods map[string]string) bool {
+	for _, method := range methods {
+		if method != http.MethodGet && method != http.MethodPost {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validRequestBodyTemplate returns whether the given request body template is
+// valid JSON once its placeholders are replaced.
+func validRequestBodyTemplate(template string) bool {
+	_, err := templateBody(template, "", 1)
+
+	return err == nil
+}
+
+// validObjectPath returns whether the given object-list path can be parsed.
+func validObjectPath(path string) bool {
+	_, err := parseObjectPath(path)
+
+	return err == nil
+}
+
+// startsFromOffset returns whether StartCursor is an offset with the config's
+// pagination type, as opposed to a continuation token or cookie. Plain offset
+// pagination sends a custom CursorQueryParam as is, so it may hold a token.
+func (c *Config) startsFromOffset() bool {
+	switch {
+	case c.HybridPagination == HybridPaginationOffset || c.PaginationMode == PaginationAtlassian:
+		return true
+	case c.HybridPagination != "" || c.CursorCookie != "" || c.CursorQueryParam != "":
+		return false
+	default:
+		return (c.PaginationMode == "" || c.PaginationMode == PaginationOffset) &&
+			c.CursorResponseField == "" && c.NextCursorJSONPath == ""
+	}
+}
+
+// validOffset returns whether the given string is a non-negative offset.
+func validOffset(offset string) bool {
+	parsed, err := strconv.ParseInt(offset, 10, 64)
+
+	return err == nil && parsed >= 0
+}
+
+// validAdHocEntities returns whether all the given ad hoc entities are fully
+// defined.
+func validAdHocEntities(entities map[string]AdHocEntity) bool {
+	for _, entity := range entities {
+		if entity.Endpoint == "" || entity.UniqueIDAttribute == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validHeaderNames returns whether all the keys of the given headers are
+// valid header names.
+func validHeaderNames(headers map[string]string) bool {
+	for name := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reservedHeader returns the name of one of the given headers that is set by
+// the adapter and cannot be overridden, or "" if there is none.
+func reservedHeader(headers map[string]string) string {
+	for name := range headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Cookie", "Content-Encoding", "Content-Length", "Host":
+			return name
+		}
+	}
+
+	return ""
+}
+
+// validDateTimeFormats returns whether all the given datetime formats have a
+// layout.
+func validDateTimeFormats(formats []DateTimeFormat) bool {
+	for _, format := range formats {
+		if format.Format == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validIncidentID returns whether the given incident ID can be inserted in the
+// path of the incident's log entries endpoint.
+func validIncidentID(id string) bool {
+	return strings.TrimSpace(id) != "" && !strings.ContainsAny(id, " \t\r\n/?#")
+}
+
+// validTimeWindow returns whether the given bounds of a time window, if set,
+// are RFC 3339 datetimes in chronological order.
+func validTimeWindow(since, until string) bool {
+	var sinceTime, untilTime time.Time
+
+	var err error
+
+	if since != "" {
+		if sinceTime, err = time.Parse(time.RFC3339, since); err != nil {
+			return false
+		}
+	}
+
+	if until != "" {
+		if untilTime, err = time.Parse(time.RFC3339, until); err != nil {
+			return false
+		}
+	}
+
+	return since == "" || until == "" || untilTime.After(sinceTime)
+}
+
+// validIncidentStatuses returns whether all the given incident statuses are
+// supported by the datasource.
+func validIncidentStatuses(statuses []string) bool {
+	for _, status := range statuses {
+		if status != IncidentStatusTriggered && status != IncidentStatusAcknowledged &&
+			status != IncidentStatusResolved {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validProxyURL returns whether the given URL is an absolute proxy URL.
+func validProxyURL(proxyURL string) bool {
+	parsed, err := url.Parse(proxyURL)
+
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https" || parsed.Scheme == "socks5") &&
+		parsed.Host != ""
+}
+
+// validBaseURL returns whether the given URL is an absolute HTTP(S) URL.
+func validBaseURL(baseURL string) bool {
+	parsed, err := url.Parse(baseURL)
+
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
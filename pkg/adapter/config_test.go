@@ -0,0 +1,217 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigUnmarshalJSON(t *testing.T) {
+	tests := map[string]struct {
+		data       string
+		wantConfig *Config
+		wantError  string
+	}{
+		"valid": {
+			data: `{"apiVersion":"v2","authToken":"Token token=abc","teamIds":["PT1"],` +
+				`"multiStatus":{"maxFailedPercent":10}}`,
+			wantConfig: &Config{
+				APIVersion:  "v2",
+				AuthToken:   "Token token=abc",
+				TeamIDs:     []string{"PT1"},
+				MultiStatus: &MultiStatus{MaxFailedPercent: 10},
+			},
+		},
+		"unknown_field": {
+			data:      `{"apiVersion":"v2","authTokn":"Token token=abc"}`,
+			wantError: `unknown field "authTokn"`,
+		},
+		"unknown_nested_field": {
+			data:      `{"apiVersion":"v2","multiStatus":{"maxFailedPercnt":10}}`,
+			wantError: `unknown field "maxFailedPercnt"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := new(Config)
+
+			err := json.Unmarshal([]byte(tt.data), config)
+
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("Expected error containing %q, got %v.", tt.wantError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if !reflect.DeepEqual(config, tt.wantConfig) {
+				t.Errorf("Expected config %+v, got %+v.", tt.wantConfig, config)
+			}
+		})
+	}
+}
+
+func TestConfigValidateRequestTimeout(t *testing.T) {
+	tests := map[string]struct {
+		requestTimeoutSeconds int
+		wantError             string
+	}{
+		// 0 selects DefaultRequestTimeout.
+		"default": {
+			requestTimeoutSeconds: 0,
+		},
+		"positive": {
+			requestTimeoutSeconds: 30,
+		},
+		"negative": {
+			requestTimeoutSeconds: -1,
+			wantError:             "requestTimeoutSeconds must not be negative",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{APIVersion: "v2", RequestTimeoutSeconds: tt.requestTimeoutSeconds}
+
+			err := config.Validate(context.Background())
+
+			if tt.wantError != "" {
+				if err == nil || err.Error() != tt.wantError {
+					t.Fatalf("Expected error %q, got %v.", tt.wantError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+		})
+	}
+}
+
+func TestConfigValidateIncidentStatuses(t *testing.T) {
+	tests := map[string]struct {
+		statuses  []string
+		wantError string
+	}{
+		"none": {},
+		"single": {
+			statuses: []string{IncidentStatusTriggered},
+		},
+		"multiple": {
+			statuses: []string{IncidentStatusTriggered, IncidentStatusAcknowledged, IncidentStatusResolved},
+		},
+		"invalid": {
+			statuses:  []string{IncidentStatusTriggered, "snoozed"},
+			wantError: `incidentStatuses must only contain "triggered", "acknowledged" or "resolved"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{APIVersion: "v2", IncidentStatuses: tt.statuses}
+
+			err := config.Validate(context.Background())
+
+			if tt.wantError != "" {
+				if err == nil || err.Error() != tt.wantError {
+					t.Fatalf("Expected error %q, got %v.", tt.wantError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+		})
+	}
+}
+
+func TestConfigValidateStartCursor(t *testing.T) {
+	tests := map[string]struct {
+		config    Config
+		wantError string
+	}{
+		"hybrid_offset": {
+			config: Config{StartCursor: "20", HybridPagination: HybridPaginationOffset},
+		},
+		"invalid_hybrid_offset": {
+			config:    Config{StartCursor: "abc", HybridPagination: HybridPaginationOffset},
+			wantError: "startCursor must be a non-negative offset",
+		},
+		"negative_atlassian_offset": {
+			config:    Config{StartCursor: "-5", PaginationMode: PaginationAtlassian},
+			wantError: "startCursor must be a non-negative offset",
+		},
+		"offset": {
+			config: Config{StartCursor: "20"},
+		},
+		"invalid_offset": {
+			config:    Config{StartCursor: "abc"},
+			wantError: "startCursor must be a non-negative offset",
+		},
+		"invalid_offset_pagination_mode": {
+			config:    Config{StartCursor: "abc", PaginationMode: PaginationOffset},
+			wantError: "startCursor must be a non-negative offset",
+		},
+		"offset_custom_query_param": {
+			config: Config{StartCursor: "abc", CursorQueryParam: "page_token"},
+		},
+		"offset_cursor_response_field": {
+			config: Config{StartCursor: "abc", CursorResponseField: "next_cursor"},
+		},
+		"hybrid_header": {
+			config: Config{StartCursor: "abc", HybridPagination: HybridPaginationHeader},
+		},
+		"token": {
+			config: Config{StartCursor: "abc", PaginationMode: PaginationCursor},
+		},
+		"cookie": {
+			config: Config{StartCursor: "abc", CursorCookie: "next"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.config.APIVersion = "v2"
+
+			err := tt.config.Validate(context.Background())
+
+			if tt.wantError != "" {
+				if err == nil || err.Error() != tt.wantError {
+					t.Fatalf("Expected error %q, got %v.", tt.wantError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+		})
+	}
+}
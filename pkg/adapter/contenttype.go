@@ -0,0 +1,63 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// checkContentType returns an error if the media type of the given
+// Content-Type header value is not one of the allowed media types.
+//
+// Allowed media types are matched ignoring case and parameters, e.g. an allowed
+// "application/json" matches "application/json; charset=utf-8". An allowed
+// media type may use a wildcard subtype, e.g. "application/*".
+func checkContentType(contentType string, allowed []string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("response has an invalid content type %q: %w", contentType, err)
+	}
+
+	for _, allowedType := range allowed {
+		allowedType = strings.ToLower(strings.TrimSpace(allowedType))
+
+		if prefix, found := strings.CutSuffix(allowedType, "/*"); found {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return nil
+			}
+
+			continue
+		}
+
+		if mediaType == allowedType {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("response content type %q is not one of the allowed content types %v", mediaType, allowed)
+}
+
+// validContentTypes returns whether all the given media types can be parsed.
+func validContentTypes(contentTypes []string) bool {
+	for _, contentType := range contentTypes {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// compactCursorPrefix prefixes compacted cursors. It can't be confused
+	// with the start of a cursor that is not compacted since '.' is not in the
+	// base64 URL alphabet.
+	compactCursorPrefix = "z."
+
+	// maxDecompressedCursorBytes is the maximum size of a decompressed cursor.
+	maxDecompressedCursorBytes = 16 << 20
+
+	// maxSeenDigests is the maximum number of digests of unique IDs kept in
+	// cursors to drop duplicates, which bounds the size of cursors. Entities
+	// merged from several endpoints with more objects fail rather than return
+	// duplicates.
+	maxSeenDigests = 10000
+)
+
+// compositeCursor is the pagination state returned to the ingestion service
+// as an opaque cursor when a single value is not enough to request the next
+// page from the datasource.
+type compositeCursor struct {
+	// Offset is the computed offset of the first object of the next page.
+	Offset int64 `json:"offset,omitempty"`
+
+	// Token is the continuation token returned by the datasource, e.g. in the
+	// X-Next-Page response header.
+	Token string `json:"token,omitempty"`
+
+	// Cookie is the value of the continuation cookie set by the datasource.
+	Cookie string `json:"cookie,omitempty"`
+
+	// Count is the number of objects returned in the previous pages.
+	Count int64 `json:"count,omitempty"`
+
+	// Source is the index of the endpoint to query, for entities merged from
+	// several endpoints.
+	Source int `json:"source,omitempty"`
+
+	// Seen is the set of digests of the unique IDs of the objects returned
+	// from the previous pages, for entities merged from several endpoints,
+	// oldest first. It holds at most maxSeenDigests digests.
+	Seen []string `json:"seen,omitempty"`
+}
+
+// encodeCursor serializes the given cursor into an opaque string.
+//
+// If compact is set, the serialized cursor is compressed, which keeps cursors
+// holding large sets of digests small. An error is returned if the resulting
+// cursor is longer than maxLength, unless maxLength is 0.
+func encodeCursor(cursor *compositeCursor, compact bool, maxLength int) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+
+	prefix := ""
+
+	if compact {
+		var compressed bytes.Buffer
+
+		writer := gzip.NewWriter(&compressed)
+
+		if _, err := writer.Write(data); err != nil {
+			return "", fmt.Errorf("failed to compress cursor: %w", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			return "", fmt.Errorf("failed to compress cursor: %w", err)
+		}
+
+		data = compressed.Bytes()
+		prefix = compactCursorPrefix
+	}
+
+	encoded := prefix + base64.RawURLEncoding.EncodeToString(data)
+
+	if maxLength > 0 && len(encoded) > maxLength {
+		return "", fmt.Errorf("cursor length %d exceeds the maximum of %d", len(encoded), maxLength)
+	}
+
+	return encoded, nil
+}
+
+// decodeCursor deserializes a cursor returned by encodeCursor, whether it was
+// compacted or not.
+// An empty string is decoded into a zero cursor, i.e. the first page.
+func decodeCursor(encoded string) (*compositeCursor, error) {
+	cursor := &compositeCursor{}
+
+	if encoded == "" {
+		return cursor, nil
+	}
+
+	compacted, compact := strings.CutPrefix(encoded, compactCursorPrefix)
+
+	data, err := base64.RawURLEncoding.DecodeString(compacted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cursor: %w", err)
+	}
+
+	if compact {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress cursor: %w", err)
+		}
+
+		// Bound the decompressed size to guard against forged cursors.
+		data, err = io.ReadAll(io.LimitReader(reader, maxDecompressedCursorBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress cursor: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cursor: %w", err)
+	}
+
+	return cursor, nil
+}
+
+// requestedCursor returns the cursor of the requested page, unwrapped from the
+// request's composite cursor if it uses one, which is also returned. The first
+// page starts from the request's start cursor, if any.
+func requestedCursor(request *Request) (string, *compositeCursor, error) {
+	fromStartCursor := request.Cursor == "" && request.StartCursor != ""
+
+	if !request.usesCompositeCursor() {
+		if fromStartCursor {
+			return request.StartCursor, nil, nil
+		}
+
+		return request.Cursor, nil, nil
+	}
+
+	var cursor *compositeCursor
+
+	var err error
+
+	if fromStartCursor {
+		cursor, err = startCursor(request)
+	} else {
+		cursor, err = decodeCursor(request.Cursor)
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return cursor.Token, cursor, nil
+}
+
+// startCursor returns the cursor of the first page of a request that starts
+// from the request's start cursor instead of the beginning of the entity.
+func startCursor(request *Request) (*compositeCursor, error) {
+	switch {
+	case request.HybridPagination == HybridPaginationOffset:
+		offset, err := strconv.ParseInt(request.StartCursor, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("start cursor is not a valid offset: %s", request.StartCursor)
+		}
+
+		return &compositeCursor{Offset: offset}, nil
+	case request.CursorCookie != "":
+		return &compositeCursor{Cookie: request.StartCursor}, nil
+	default:
+		return &compositeCursor{Token: request.StartCursor}, nil
+	}
+}
+
+// nextHybridCursor returns the cursor of the page following the page requested
+// with the given cursor in hybrid pagination, or nil if the datasource returned
+// no X-Next-Page token, i.e. this is the last page.
+//
+// The offset of the next page is the offset of the requested page plus the
+// page size, or the number of objects returned if the page size is not set.
+func nextHybridCursor(cursor *compositeCursor, nextPageToken string, pageSize int64, objectCount int) *compositeCursor {
+	if nextPageToken == "" {
+		return nil
+	}
+
+	step := pageSize
+	if step <= 0 {
+		step = int64(objectCount)
+	}
+
+	return &compositeCursor{
+		Offset: cursor.Offset + step,
+		Token:  nextPageToken,
+	}
+}
+
+// effectivePageSize returns the page size to use to compute the offset of the
+// next page: the limit echoed by the datasource in its response if set, since
+// the datasource may cap the requested page size, or else the requested one.
+func effectivePageSize(requestedPageSize int64, responseLimit int) int64 {
+	if responseLimit > 0 {
+		return int64(responseLimit)
+	}
+
+	return requestedPageSize
+}
+
+// nextCookieCursor returns the cursor of the page following the current page
+// in cookie pagination, or nil if the datasource did not set the named
+// continuation cookie, i.e. this is the last page.
+func nextCookieCursor(cookies []*http.Cookie, name string) *compositeCursor {
+	for _, cookie := range cookies {
+		if cookie.Name == name && cookie.Value != "" {
+			return &compositeCursor{
+				Cookie: cookie.Value,
+			}
+		}
+	}
+
+	return nil
+}
+
+// nextSourceCursor returns the cursor of the page following the current page
+// for entities merged from several endpoints, given the cursor of the next page
+// within the current source, or nil if the current source is exhausted.
+//
+// The sources are queried one after the other: once a source is exhausted, the
+// first page of the next source is requested, until all are exhausted.
+// The digests of the IDs of the objects returned from all previous pages are
+// carried over to drop duplicates.
+func nextSourceCursor(cursor, nextCursor *compositeCursor, sourceCount int) *compositeCursor {
+	switch {
+	case nextCursor != nil:
+		nextCursor.Source = cursor.Source
+	case cursor.Source+1 < sourceCount:
+		nextCursor = &compositeCursor{
+			Source: cursor.Source + 1,
+		}
+	default:
+		return nil
+	}
+
+	nextCursor.Seen = cursor.Seen
+
+	return nextCursor
+}
+
+// dropSeenObjects returns the objects whose unique ID is not in the cursor's
+// set of seen IDs, and adds their IDs to that set. Objects without a unique ID
+// are never dropped.
+// Returns an error if the set would hold more than maxSeenDigests IDs, since
+// duplicates of the IDs left out could no longer be dropped.
+func dropSeenObjects(
+	objects []map[string]any, uniqueIDAttribute string, cursor *compositeCursor,
+) ([]map[string]any, error) {
+	seen := make(map[string]struct{}, len(cursor.Seen))
+
+	for _, digest := range cursor.Seen {
+		seen[digest] = struct{}{}
+	}
+
+	unseen := make([]map[string]any, 0, len(objects))
+
+	for _, object := range objects {
+		id, found := object[uniqueIDAttribute]
+		if !found || id == nil || id == "" {
+			unseen = append(unseen, object)
+
+			continue
+		}
+
+		digest := idDigest(id)
+
+		if _, found := seen[digest]; found {
+			continue
+		}
+
+		seen[digest] = struct{}{}
+		cursor.Seen = append(cursor.Seen, digest)
+		unseen = append(unseen, object)
+	}
+
+	if len(cursor.Seen) > maxSeenDigests {
+		return nil, fmt.Errorf("more than %d objects with a unique ID were returned", maxSeenDigests)
+	}
+
+	return unseen, nil
+}
+
+// idDigest returns a short digest of the given unique ID, to keep the set of
+// seen IDs in cursors compact.
+func idDigest(id any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(id)))
+
+	return hex.EncodeToString(sum[:8])
+}
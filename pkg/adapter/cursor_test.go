@@ -0,0 +1,209 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDropSeenObjects(t *testing.T) {
+	tests := map[string]struct {
+		seen        []string
+		objects     []map[string]any
+		wantObjects []map[string]any
+		wantSeen    []string
+	}{
+		"drops_seen": {
+			seen:        []string{idDigest("P1")},
+			objects:     []map[string]any{{"id": "P1"}, {"id": "P2"}, {"id": "P2"}},
+			wantObjects: []map[string]any{{"id": "P2"}},
+			wantSeen:    []string{idDigest("P1"), idDigest("P2")},
+		},
+		"keeps_objects_without_id": {
+			objects:     []map[string]any{{"name": "a"}, {"id": nil}, {"id": ""}, {"name": "a"}},
+			wantObjects: []map[string]any{{"name": "a"}, {"id": nil}, {"id": ""}, {"name": "a"}},
+		},
+		"non_string_ids": {
+			objects:     []map[string]any{{"id": float64(1)}, {"id": float64(1)}, {"id": float64(2)}},
+			wantObjects: []map[string]any{{"id": float64(1)}, {"id": float64(2)}},
+			wantSeen:    []string{idDigest(float64(1)), idDigest(float64(2))},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cursor := &compositeCursor{Seen: tt.seen}
+
+			got, err := dropSeenObjects(tt.objects, "id", cursor)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantObjects) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantObjects, got)
+			}
+
+			if !reflect.DeepEqual(cursor.Seen, tt.wantSeen) {
+				t.Errorf("Expected seen digests %v, got %v.", tt.wantSeen, cursor.Seen)
+			}
+		})
+	}
+}
+
+func TestDropSeenObjectsBound(t *testing.T) {
+	cursor := &compositeCursor{}
+
+	objects := make([]map[string]any, 0, maxSeenDigests)
+	for i := 0; i < maxSeenDigests; i++ {
+		objects = append(objects, map[string]any{"id": fmt.Sprintf("P%d", i)})
+	}
+
+	if _, err := dropSeenObjects(objects, "id", cursor); err != nil {
+		t.Fatalf("Expected no error, got %v.", err)
+	}
+
+	// The oldest digests are never dropped, so duplicates of the first objects
+	// are still detected.
+	got, err := dropSeenObjects([]map[string]any{{"id": "P0"}, {"name": "no ID"}}, "id", cursor)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v.", err)
+	}
+
+	if want := []map[string]any{{"name": "no ID"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected objects %v, got %v.", want, got)
+	}
+
+	// One more unique ID can't be kept.
+	if _, err := dropSeenObjects([]map[string]any{{"id": "P-new"}}, "id", cursor); err == nil {
+		t.Errorf("Expected an error beyond %d seen IDs, got none.", maxSeenDigests)
+	}
+}
+
+func TestEncodeCursor(t *testing.T) {
+	// A cursor holding as many digests as kept for entities merged from
+	// several endpoints.
+	large := &compositeCursor{Offset: 1000, Token: "token", Count: 5000, Source: 1}
+	for i := 0; i < maxSeenDigests; i++ {
+		large.Seen = append(large.Seen, idDigest(fmt.Sprintf("P%d", i)))
+	}
+
+	uncompacted, err := encodeCursor(large, false, 0)
+	if err != nil {
+		t.Fatalf("Failed to encode cursor: %v.", err)
+	}
+
+	tests := map[string]struct {
+		cursor      *compositeCursor
+		compact     bool
+		maxLength   int
+		wantErrText string
+	}{
+		"uncompacted": {
+			cursor: large,
+		},
+		"compacted": {
+			cursor:  large,
+			compact: true,
+		},
+		// Compaction keeps the cursor under a maximum that the uncompacted
+		// cursor exceeds.
+		"compacted_under_max_length": {
+			cursor:    large,
+			compact:   true,
+			maxLength: len(uncompacted) * 3 / 4,
+		},
+		"uncompacted_over_max_length": {
+			cursor:      large,
+			maxLength:   len(uncompacted) * 3 / 4,
+			wantErrText: "exceeds the maximum",
+		},
+		"compacted_over_max_length": {
+			cursor:      large,
+			compact:     true,
+			maxLength:   100,
+			wantErrText: "exceeds the maximum of 100",
+		},
+		"small": {
+			cursor:  &compositeCursor{Token: "abc"},
+			compact: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := encodeCursor(tt.cursor, tt.compact, tt.maxLength)
+
+			if tt.wantErrText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Errorf("Expected error containing %q, got %v.", tt.wantErrText, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if tt.maxLength > 0 && len(encoded) > tt.maxLength {
+				t.Errorf("Expected cursor of at most %d bytes, got %d.", tt.maxLength, len(encoded))
+			}
+
+			if got := strings.HasPrefix(encoded, compactCursorPrefix); got != tt.compact {
+				t.Errorf("Expected compacted cursor %v, got %q.", tt.compact, encoded)
+			}
+
+			decoded, err := decodeCursor(encoded)
+			if err != nil {
+				t.Fatalf("Failed to decode cursor: %v.", err)
+			}
+
+			if !reflect.DeepEqual(decoded, tt.cursor) {
+				t.Errorf("Expected cursor %+v after a round trip, got %+v.", tt.cursor, decoded)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := map[string]struct {
+		encoded     string
+		wantErrText string
+	}{
+		"invalid_base64": {
+			encoded:     "not base64!",
+			wantErrText: "failed to decode cursor",
+		},
+		"invalid_compressed_data": {
+			encoded:     compactCursorPrefix + "YWJj",
+			wantErrText: "failed to decompress cursor",
+		},
+		"invalid_json": {
+			encoded:     "YWJj",
+			wantErrText: "failed to unmarshal cursor",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.encoded); err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+				t.Errorf("Expected error containing %q, got %v.", tt.wantErrText, err)
+			}
+		})
+	}
+}
@@ -15,21 +15,76 @@
 package adapter
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	framework "github.com/sgnl-ai/adapter-framework"
 	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+	"github.com/sgnl-ai/adapter-framework/web"
 )
 
 const (
 	// SCAFFOLDING #11 - pkg/adapter/datasource.go: Update the set of valid entity types this adapter supports.
-	Teams string = "teams"
+	Teams              string = "teams"
+	Users              string = "users"
+	Incidents          string = "incidents"
+	EscalationPolicies string = "escalationPolicies"
+	Services           string = "services"
+	Schedules          string = "schedules"
+	LogEntries         string = "logEntries"
+
+	// TeamMembers is the child entity of Teams holding the team's members.
+	TeamMembers string = "members"
+)
+
+const (
+	// DefaultMaxResponseBytes is the maximum size of a response body read from
+	// the datasource if none is configured.
+	DefaultMaxResponseBytes = 50 << 20
+
+	// DefaultAcceptHeader is the Accept header sent to the datasource if none
+	// is configured, which selects version 2 of the PagerDuty API.
+	DefaultAcceptHeader = "application/vnd.pagerduty+json;version=2"
+
+	// DefaultContentType is the Content-Type header sent to the datasource if
+	// none is configured.
+	DefaultContentType = "application/json"
+
+	// DefaultUserAgent is the User-Agent header sent to the datasource if none
+	// is configured.
+	DefaultUserAgent = "sgnl-adapter/1.0"
+
+	// CursorPlaceholder is the placeholder of the cursor in
+	// Config.RequestBodyTemplate.
+	CursorPlaceholder = "{{cursor}}"
+
+	// PageSizePlaceholder is the placeholder of the page size in
+	// Config.RequestBodyTemplate.
+	PageSizePlaceholder = "{{pageSize}}"
+)
+
+const (
+	// IncidentStatusTriggered, IncidentStatusAcknowledged and
+	// IncidentStatusResolved are the statuses by which incidents can be
+	// filtered with Config.IncidentStatuses.
+	IncidentStatusTriggered    = "triggered"
+	IncidentStatusAcknowledged = "acknowledged"
+	IncidentStatusResolved     = "resolved"
 )
 
 // Entity contains entity specific information, such as the entity's unique ID attribute and the
@@ -41,23 +96,187 @@ type Entity struct {
 
 	// uniqueIDAttrExternalID is the external ID of the entity's uniqueId attribute.
 	uniqueIDAttrExternalID string
+
+	// attributeMinAPIVersions maps the external IDs of the entity's attributes
+	// that don't exist in all API versions to the minimum API version that
+	// supports them. Such attributes are not requested with older versions.
+	// It can be overridden with Config.AttributeMinAPIVersions.
+	attributeMinAPIVersions map[string]string
+
+	// apiVersions is the range of API versions in which the entity exists.
+	// It can be overridden with Config.EntityAPIVersions.
+	apiVersions APIVersionRange
+
+	// endpoint is the path of the endpoint to query the entity.
+	// Optional. If not set, the entity is queried from its external ID's path.
+	endpoint string
+
+	// responseObjectsKey is the key of the list of the entity's objects in
+	// the datasource's response bodies.
+	responseObjectsKey string
+
+	// additionalObjectsKeys are the keys of further lists of the
+	// entity's objects in the datasource's response bodies, e.g. related
+	// records, which are merged in order after those of responseObjectsKey.
+	// Optional.
+	additionalObjectsKeys []string
+
+	// knownAttributes is the set of top-level fields of the entity's objects,
+	// against which requested attributes are checked if
+	// Config.ValidateAttributes is set.
+	// Optional. If not set, requested attributes are not checked.
+	knownAttributes map[string]struct{}
+
+	// childEntities maps the external IDs of the entity's child entities to
+	// their definition. A child entity's objects are queried for each parent
+	// object from the child's endpoint, relative to the parent object's path,
+	// e.g. "teams/{id}/members". Child entities can't have child entities.
+	// Optional. If not set, the entity has no child entities.
+	childEntities map[string]Entity
+}
+
+// attributeSet returns the set of the given attribute external IDs.
+func attributeSet(externalIDs ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(externalIDs))
+
+	for _, externalID := range externalIDs {
+		set[externalID] = struct{}{}
+	}
+
+	return set
 }
 
 // Datasource directly implements a Client interface to allow querying
 // an external datasource.
 type Datasource struct {
 	Client *http.Client
+
+	// Logger logs the warnings about the datasource's responses.
+	// Optional. If not set, messages are discarded.
+	Logger Logger
+
+	// Metrics records the metrics of each page requested from the datasource.
+	// Optional. If not set, metrics are discarded.
+	Metrics MetricsRecorder
+
+	// clients caches the HTTP clients derived from Client for requests which
+	// customize the transport, by transport configuration.
+	clients   map[transportConfig]*http.Client
+	clientsMu sync.Mutex
+
+	// tokens caches the access tokens obtained from OAuth2 token endpoints.
+	tokens   map[string]cachedToken
+	tokensMu sync.Mutex
+
+	// retriesExhausted counts the requests that failed after all retries.
+	retriesExhausted atomic.Int64
+
+	// debugDumps counts the debug dump files written.
+	debugDumps atomic.Int64
 }
 
 type DatasourceResponse struct {
 	// SCAFFOLDING #13  - pkg/adapter/datasource.go: Add or remove fields in the response as necessary. This is used to unmarshal the response from the SoR.
 
-	// SCAFFOLDING #14 - pkg/adapter/datasource.go: Update `objects` with field name in the SoR response that contains the list of objects.
-	Teams  []map[string]interface{} `json:"teams,omitempty"`
-	Limit  int                      `json:"limit"`
-	Offset int                      `json:"offset"`
-	Total  *int                     `json:"total,omitempty"`
-	More   bool                     `json:"more"`
+	// SCAFFOLDING #14 - pkg/adapter/datasource.go: Update `responseObjectsKey` in ValidEntityExternalIDs with the
+	// field name in the SoR response that contains the list of objects of each entity.
+	Limit  int  `json:"limit"`
+	Offset int  `json:"offset"`
+	Total  *int `json:"total,omitempty"`
+	More   bool `json:"more"`
+}
+
+// responseObjects returns the list of objects held in the given top-level key
+// of the given response body, and whether the key is present. A missing key is
+// an empty list.
+func responseObjects(body []byte, key string) ([]map[string]interface{}, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false, err
+	}
+
+	raw, found := fields[key]
+	if !found {
+		return nil, false, nil
+	}
+
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(raw, &objects); err != nil {
+		// The key commonly holds a single object, or a list of IDs, instead
+		// of a list of objects.
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, true, &objectsTypeError{
+				Field:    key,
+				Found:    typeErr.Value,
+				Expected: jsonTypeName(typeErr.Type),
+			}
+		}
+
+		return nil, true, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+	}
+
+	return objects, true, nil
+}
+
+// objectsTypeError is the error returned if the field of a response body
+// holding a list of objects holds a value of another JSON type.
+type objectsTypeError struct {
+	// Field is the key of the field holding the list of objects.
+	Field string
+
+	// Found is the JSON type found, e.g. "object" or "string".
+	Found string
+
+	// Expected is the JSON type expected, i.e. "array" for the field, or
+	// "object" for its elements.
+	Expected string
+}
+
+func (e *objectsTypeError) Error() string {
+	if e.Expected == "object" {
+		return fmt.Sprintf("field %s holds an array of %s values, expected an array of objects", e.Field, e.Found)
+	}
+
+	return fmt.Sprintf("field %s holds a JSON %s, expected an array of objects", e.Field, e.Found)
+}
+
+// jsonTypeName returns the name of the JSON type unmarshaled into the given Go
+// type.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Interface:
+		return "value"
+	default:
+		return "number"
+	}
+}
+
+// topLevelKeys returns the sorted keys of the given JSON object, or nil if it
+// is not an object.
+func topLevelKeys(body []byte) []string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(fields))
+
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
 }
 
 var (
@@ -68,26 +287,202 @@ var (
 	ValidEntityExternalIDs = map[string]Entity{
 		Teams: {
 			uniqueIDAttrExternalID: "id",
+			responseObjectsKey:     "teams",
+			knownAttributes: attributeSet(
+				"id", "type", "summary", "self", "html_url", "name", "description", "parent", "default_role",
+			),
+			childEntities: map[string]Entity{
+				TeamMembers: {
+					endpoint:           "members",
+					responseObjectsKey: "members",
+				},
+			},
+		},
+		Users: {
+			uniqueIDAttrExternalID: "id",
+			responseObjectsKey:     "users",
+			knownAttributes: attributeSet(
+				"id", "type", "summary", "self", "html_url", "name", "email", "time_zone", "color", "role",
+				"avatar_url", "description", "invitation_sent", "job_title", "teams", "contact_methods",
+				"notification_rules", "license",
+			),
+		},
+		Incidents: {
+			uniqueIDAttrExternalID: "id",
+			responseObjectsKey:     "incidents",
+			knownAttributes: attributeSet(
+				"id", "type", "summary", "self", "html_url", "incident_number", "title", "description",
+				"created_at", "updated_at", "status", "incident_key", "service", "assignments", "assigned_via",
+				"last_status_change_at", "last_status_change_by", "resolved_at", "first_trigger_log_entry",
+				"alert_counts", "is_mergeable", "escalation_policy", "teams", "pending_actions",
+				"acknowledgements", "alert_grouping", "priority", "resolve_reason", "conference_bridge",
+				"incidents_responders", "responder_requests", "urgency",
+			),
+		},
+		EscalationPolicies: {
+			uniqueIDAttrExternalID: "id",
+			endpoint:               "escalation_policies",
+			responseObjectsKey:     "escalation_policies",
+			knownAttributes: attributeSet(
+				"id", "type", "summary", "self", "html_url", "name", "description", "num_loops",
+				"on_call_handoff_notifications", "escalation_rules", "services", "teams",
+			),
+		},
+		Services: {
+			uniqueIDAttrExternalID: "id",
+			responseObjectsKey:     "services",
+			knownAttributes: attributeSet(
+				"id", "type", "summary", "self", "html_url", "name", "description", "auto_resolve_timeout",
+				"acknowledgement_timeout", "created_at", "updated_at", "status", "last_incident_timestamp",
+				"escalation_policy", "response_play", "teams", "integrations", "incident_urgency_rule",
+				"support_hours", "scheduled_actions", "alert_creation", "alert_grouping_parameters",
+				"auto_pause_notifications_parameters",
+			),
+		},
+		Schedules: {
+			uniqueIDAttrExternalID: "id",
+			responseObjectsKey:     "schedules",
+			knownAttributes: attributeSet(
+				"id", "type", "summary", "self", "html_url", "name", "time_zone", "description",
+				"escalation_policies", "users", "teams", "schedule_layers", "final_schedule",
+				"overrides_subschedule",
+			),
+		},
+		LogEntries: {
+			uniqueIDAttrExternalID: "id",
+			endpoint:               "log_entries",
+			responseObjectsKey:     "log_entries",
+			knownAttributes: attributeSet(
+				"id", "type", "summary", "self", "html_url", "created_at", "agent", "channel", "service",
+				"incident", "teams", "contexts", "event_details", "user", "assignees", "acknowledgement_timeout",
+			),
 		},
 	}
 )
 
-// NewClient returns a Client to query the datasource.
+// lookupEntity returns the entity with the given external ID, built into the
+// adapter or, if the config allows it, defined ad hoc in the config.
+func lookupEntity(config *Config, externalID string) (entity Entity, adHoc bool, found bool) {
+	if entity, found := ValidEntityExternalIDs[externalID]; found {
+		return entity, false, true
+	}
+
+	if config == nil || !config.AllowAdHocEntities {
+		return Entity{}, false, false
+	}
+
+	adHocEntity, found := config.AdHocEntities[externalID]
+	if !found {
+		return Entity{}, false, false
+	}
+
+	objectsKey := adHocEntity.ObjectsKey
+	if objectsKey == "" {
+		objectsKey = externalID
+	}
+
+	return Entity{
+		uniqueIDAttrExternalID: adHocEntity.UniqueIDAttribute,
+		endpoint:               adHocEntity.Endpoint,
+		responseObjectsKey:     objectsKey,
+		additionalObjectsKeys:  adHocEntity.AdditionalObjectsKeys,
+	}, true, true
+}
+
+// logger returns the Datasource's Logger, or one discarding all messages.
+func (d *Datasource) logger() Logger {
+	return loggerOrNoop(d.Logger)
+}
+
+// NewClient returns a Client to query the datasource, with the default
+// connection pool configuration.
 func NewClient(timeout int) Client {
+	return NewClientWithPool(timeout, PoolConfig{})
+}
+
+// NewClientWithPool returns a Client to query the datasource whose connections
+// are pooled with the given configuration, e.g. to keep more connections open
+// for high-throughput syncs.
+func NewClientWithPool(timeout int, pool PoolConfig) Client {
+	return NewClientWithTransport(timeout, newPooledTransport(pool))
+}
+
+// NewClientWithTransport returns a Client to query the datasource which sends
+// requests with the given transport, e.g. to go through a proxy or trust a
+// private CA. If the transport is nil, http.DefaultTransport is used.
+//
+// Requests whose config customizes the transport, e.g. with TLS pinning, a
+// proxy URL or root CAs, require the transport to be an *http.Transport.
+func NewClientWithTransport(timeout int, transport http.RoundTripper) Client {
 	return &Datasource{
 		Client: &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
+			Transport: transport,
+			Timeout:   time.Duration(timeout) * time.Second,
 		},
 	}
 }
 
+// GetPage returns a page of JSON objects from the datasource for the requested
+// entity.
+//
+// If the page cannot be returned, e.g. because the response body is malformed,
+// the returned Response holds the cursor from which to resume, i.e. the
+// request's cursor, which is the last one known to be good.
 func (d *Datasource) GetPage(ctx context.Context, request *Request) (*Response, *framework.Error) {
-	var req *http.Request
+	recorder := metricsRecorderOrNoop(d.Metrics)
+	recorder.RequestStarted(request.EntityExternalID)
+
+	var metrics RequestMetrics
+
+	start := time.Now()
+
+	response, err := d.getPage(ctx, request, &metrics)
+
+	metrics.Duration = time.Since(start)
+	metrics.Err = err
+	recorder.RequestFinished(request.EntityExternalID, metrics)
+
+	if err != nil {
+		return &Response{Cursor: request.Cursor}, err
+	}
+
+	return response, nil
+}
+
+// getPage returns a page of JSON objects from the datasource for the requested
+// entity, and sets the status code and size of the response in the given
+// metrics.
+func (d *Datasource) getPage(ctx context.Context, request *Request, metrics *RequestMetrics) (*Response, *framework.Error) {
+	pageCursor, requestCursor, err := requestedCursor(request)
+	if err != nil {
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to parse cursor: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		}
+	}
+
+	path := request.EntityExternalID
+	if request.Endpoint != "" {
+		path = request.Endpoint
+	}
+
+	// If the entity is merged from several endpoints, query the endpoint of
+	// the source tracked in the cursor.
+	if len(request.Sources) > 0 {
+		if requestCursor.Source < 0 || requestCursor.Source >= len(request.Sources) {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Cursor refers to an unknown source: %d.", requestCursor.Source),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+			}
+		}
+
+		path = request.Sources[requestCursor.Source]
+	}
 
 	// SCAFFOLDING #16 - pkg/adapter/datasource.go: Create the SoR API URL
 	// Populate the request with the appropriate path, headers, and query parameters to query the
 	// datasource.
-	fullURL := request.BaseURL + "/" + request.EntityExternalID // Join the base URL and path
+	fullURL := request.BaseURL + "/" + strings.TrimPrefix(path, "/") // Join the base URL and path
 
 	url, err := url.Parse(fullURL) // Now parse the *combined* URL
 	if err != nil {
@@ -97,17 +492,123 @@ func (d *Datasource) GetPage(ctx context.Context, request *Request) (*Response,
 		}
 	}
 
-	q := url.Query()
+	page := newPageRequest(request, pageCursor, requestCursor, url.Query())
+	paginator := newPaginator(request)
+
+	if adapterErr := paginator.buildRequest(page); adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	q := page.query
+
+	// The page size is sent as a query parameter, unless a header is configured.
 	pageSize := int(request.PageSize)
-	if pageSize > 0 {
-		q.Add("limit", fmt.Sprintf("%d", pageSize))
+	if pageSize > 0 && request.PageSizeHeader == "" {
+		q.Add(page.pageSizeParam, fmt.Sprintf("%d", pageSize))
+	}
+
+	sortParam := DefaultSortParam
+	if request.SortParam != "" {
+		sortParam = request.SortParam
+	}
+
+	// Sort objects by unique ID so that offsets remain stable if objects are
+	// created or deleted during the sync, unless they are requested ordered.
+	switch {
+	case request.SortBy != "":
+		q.Set(sortParam, request.SortBy)
+	case request.StableSort && request.usesOffsetPagination() && request.UniqueIDAttrExternalID != "":
+		q.Set(sortParam, request.UniqueIDAttrExternalID+":asc")
+	}
+
+	if request.FieldsParam != "" && len(request.Fields) > 0 {
+		q.Set(request.FieldsParam, strings.Join(request.Fields, ","))
+	}
+
+	// Incidents are filtered by status with a repeated query parameter.
+	if request.EntityExternalID == Incidents {
+		for _, status := range request.IncidentStatuses {
+			q.Add("statuses[]", status)
+		}
+	}
+
+	// Services are scoped to teams with a repeated query parameter.
+	if request.EntityExternalID == Services {
+		for _, teamID := range request.TeamIDs {
+			q.Add("team_ids[]", teamID)
+		}
+	}
+
+	// Schedules are expanded into on-call entries within a time window.
+	if request.EntityExternalID == Schedules {
+		if request.Since != "" {
+			q.Set("since", request.Since)
+		}
+
+		if request.Until != "" {
+			q.Set("until", request.Until)
+		}
+	}
+
+	var body io.Reader
+
+	var payload []byte
+
+	var contentEncoding string
+
+	// POST-query requests send the pagination parameters in a JSON body
+	// instead of the query string.
+	//
+	// With a body template, the body holds the cursor and page size, and the
+	// other query parameters, e.g. filters, remain in the query string.
+	switch {
+	case page.method == http.MethodPost && request.RequestBodyTemplate != "":
+		payload, err = templateBody(request.RequestBodyTemplate, page.cursor, pageSize)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to create request body from template: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+			}
+		}
+
+		q.Del(page.cursorParam)
+		q.Del(page.pageSizeParam)
+		url.RawQuery = q.Encode()
+	case page.method == http.MethodPost:
+		query := queryBody(q)
+
+		for name, value := range page.bodyFields {
+			query[name] = value
+		}
+
+		payload, err = json.Marshal(query)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to marshal request body: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+	default:
+		url.RawQuery = q.Encode()
+	}
+
+	if payload != nil {
+		body, contentEncoding, err = newRequestBody(payload, request.GzipRequestBody)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to create request body: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
 	}
-	if request.Cursor != "" {
-		q.Add("offset", request.Cursor)
+
+	// The page may be requested from another URL, e.g. from the URL of the next
+	// page returned by the datasource in link header pagination.
+	if page.url != nil {
+		url = page.url
 	}
-	url.RawQuery = q.Encode()
 
-	req, err = http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, page.method, url.String(), body)
 	if err != nil {
 		return nil, &framework.Error{
 			Message: "Failed to create HTTP request to datasource.",
@@ -115,43 +616,275 @@ func (d *Datasource) GetPage(ctx context.Context, request *Request) (*Response,
 		}
 	}
 
-	// Timeout API calls that take longer than 5 seconds
-	apiCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
 
-	req = req.WithContext(apiCtx)
+	// Each attempt to send the request, and each token request, times out
+	// after the request timeout.
+	timeout := d.requestTimeout(ctx, request)
 
 	// SCAFFOLDING #17 - pkg/adapter/datasource.go: Add any headers required to communicate with the SoR APIs.
-	req.Header.Add("Accept", "application/vnd.pagerduty+json;version=2")
-	req.Header.Add("Content-Type", "application/json")
+	acceptHeader := DefaultAcceptHeader
+	if request.AcceptHeader != "" {
+		acceptHeader = request.AcceptHeader
+	}
+
+	contentType := DefaultContentType
+	if request.ContentType != "" {
+		contentType = request.ContentType
+	}
+
+	req.Header.Add("Accept", acceptHeader)
+	req.Header.Add("Content-Type", contentType)
+	req.Header.Set("User-Agent", request.userAgent())
+
+	// Large pages are much smaller compressed. Since the header is set
+	// explicitly, the body is not transparently decompressed by the transport.
+	req.Header.Set("Accept-Encoding", "gzip")
 
-	if request.Token == "" {
+	if pageSize > 0 && request.PageSizeHeader != "" {
+		req.Header.Set(request.PageSizeHeader, strconv.Itoa(pageSize))
+	}
+
+	// Reserved headers such as Authorization are rejected when validating the
+	// config, and would be overwritten below anyway.
+	for name, value := range request.ExtraHeaders {
+		req.Header.Set(name, value)
+	}
+
+	client, err := d.httpClient(request)
+	if err != nil {
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to configure HTTP client: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	tokenClient, err := d.tokenHTTPClient(request)
+	if err != nil {
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to configure HTTP client for token endpoint: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	if request.Token == "" && request.AuthMode != AuthModeOAuth2ClientCredentials &&
+		request.AuthMode != AuthModeBasic {
 		return nil, &framework.Error{
 			Message: "PagerDuty auth is missing required token.",
 			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
 		}
-	} else {
-		req.Header.Add("Authorization", "Token token="+request.Token) // Correctly use the token from request.Token
+	}
+
+	// secrets are redacted from debug dumps.
+	secrets := append(authorizationSecrets(request.Token), request.Password, request.ClientSecret)
+
+	for _, value := range request.ExtraHeaders {
+		secrets = append(secrets, value)
+	}
+
+	switch request.AuthMode {
+	case AuthModeBasic:
+		req.SetBasicAuth(request.Username, request.Password)
+	case AuthModeOAuth2TokenExchange:
+		accessToken, err := d.exchangeToken(ctx, tokenClient, request)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to exchange token with token endpoint: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+			}
+		}
+
+		secrets = append(secrets, accessToken)
+
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+	case AuthModeOAuth2ClientCredentials:
+		accessToken, err := d.clientCredentialsToken(ctx, tokenClient, request)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to obtain access token from token endpoint: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+			}
+		}
+
+		secrets = append(secrets, accessToken)
+
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+	default:
+		req.Header.Add("Authorization", authorizationHeader(request.AuthScheme, request.Token))
+	}
+
+	if page.cookie != nil {
+		req.AddCookie(page.cookie)
 	}
 
 	// Sending the request
-	res, err := d.Client.Do(req)
+	res, adapterErr := d.doWithRetries(ctx, client, req, timeout, request.MaxRetries, request.RetryBaseDelay, metrics)
+	if adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	// Close the body of the final response, after a possible resend below, so
+	// that the connection can be reused.
+	defer func() {
+		if res != nil && res.Body != nil {
+			res.Body.Close()
+		}
+	}()
+
+	// An access token may be revoked before its announced expiry, so the
+	// request is sent once more with a new token if the datasource rejects it.
+	if res.StatusCode == http.StatusUnauthorized && request.AuthMode == AuthModeOAuth2ClientCredentials {
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		d.invalidateClientCredentialsToken(request)
+
+		accessToken, err := d.clientCredentialsToken(ctx, tokenClient, request)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to refresh access token from token endpoint: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+			}
+		}
+
+		secrets = append(secrets, accessToken)
+
+		req, err = rewoundRequest(req)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to rewind request body for retry: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		res, adapterErr = d.doWithRetries(ctx, client, req, timeout, request.MaxRetries, request.RetryBaseDelay, metrics)
+		if adapterErr != nil {
+			return nil, adapterErr
+		}
+	}
+
+	metrics.StatusCode = res.StatusCode
+
+	resBody, err := responseBody(res)
 	if err != nil {
 		return nil, &framework.Error{
-			Message: "Failed to send request to datasource.",
+			Message: fmt.Sprintf("Failed to decompress response body: %v.", err),
 			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
 		}
 	}
 
-	// Read and unmarshal response body
-	bodyBytes, err := io.ReadAll(res.Body)
+	// Error statuses are reported before the body is parsed, since error
+	// bodies hold no objects. The start of the body usually explains the
+	// error, e.g. an invalid token or an unknown endpoint.
+	if adapterErr := statusError(res.StatusCode, res.Header.Get("Retry-After")); adapterErr != nil {
+		adapterErr.Message += fmt.Sprintf(" Response status: %d", res.StatusCode)
+
+		if snippet := errorBodySnippet(resBody, secrets...); snippet != "" {
+			adapterErr.Message += ", body: " + snippet
+		}
+
+		adapterErr.Message += "."
+
+		return nil, adapterErr
+	}
+
+	// Responses without content have no content type to check.
+	if len(request.AllowedContentTypes) > 0 && res.StatusCode != http.StatusNoContent && res.ContentLength != 0 {
+		if err := checkContentType(res.Header.Get("Content-Type"), request.AllowedContentTypes); err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Refusing to parse response body: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+	}
+
+	maxResponseBytes := request.MaxResponseBytes
+	if maxResponseBytes <= 0 {
+		maxResponseBytes = DefaultMaxResponseBytes
+	}
+
+	// Read and unmarshal response body, up to one byte more than the maximum
+	// size to detect larger bodies without reading them whole into memory.
+	bodyBytes, err := io.ReadAll(io.LimitReader(resBody, maxResponseBytes+1))
+
+	metrics.BytesRead = int64(len(bodyBytes))
+
 	if err != nil {
+		if abortErr := abortedRequestError(ctx, attemptTimedOut(res), "reading the response body"); abortErr != nil {
+			return nil, abortErr
+		}
+
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to read response body: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	if int64(len(bodyBytes)) > maxResponseBytes {
 		return nil, &framework.Error{
-			Message: "Failed to read response body.",
+			Message: fmt.Sprintf("Response body exceeds the maximum size of %d bytes.", maxResponseBytes),
 			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
 		}
 	}
 
+	if request.DebugDumpDir != "" {
+		exchange := debugExchange{
+			entityExternalID: request.EntityExternalID,
+			method:           page.method,
+			url:              req.URL.String(),
+			requestBody:      payload,
+			statusCode:       res.StatusCode,
+			responseBody:     bodyBytes,
+		}
+
+		// Debug dumps must not fail the request.
+		if err := d.dumpExchange(request, exchange, secrets...); err != nil {
+			d.logger().Warn("Failed to write debug dump.", "entity", request.EntityExternalID, "error", err)
+		}
+	}
+
+	// Successful statuses other than 200 often explain a page without objects,
+	// e.g. a misrouted request answered with 204.
+	if res.StatusCode != http.StatusOK && res.StatusCode >= 200 && res.StatusCode < 300 {
+		d.logger().Debug("Datasource returned a successful status other than 200.",
+			"entity", request.EntityExternalID, "status", res.StatusCode, "bodyBytes", len(bodyBytes))
+	}
+
+	if request.VerifyResponseDigest {
+		if err := verifyResponseDigest(res.Header, bodyBytes); err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to verify response body integrity: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+	}
+
+	if request.DuplicateKeys != "" {
+		// Malformed bodies are reported when deserializing them below.
+		if duplicate, err := findDuplicateKey(bodyBytes); err == nil && duplicate != "" {
+			if request.DuplicateKeys == DuplicateKeysError {
+				return nil, &framework.Error{
+					Message: fmt.Sprintf("Response body contains duplicate key: %s.", duplicate),
+					Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				}
+			}
+
+			d.logger().Warn("Response body contains duplicate key, keeping the last value.",
+				"entity", request.EntityExternalID, "key", duplicate)
+		}
+	}
+
+	// A response without content, e.g. a 204 or a 200 with an empty body, is a
+	// page without objects rather than malformed JSON.
+	noContent := len(bytes.TrimSpace(bodyBytes)) == 0
+	if noContent {
+		bodyBytes = []byte("{}")
+	}
+
 	// Deserialize JSON into the datastructure
 	var response DatasourceResponse
 	if err := json.Unmarshal(bodyBytes, &response); err != nil {
@@ -161,18 +894,354 @@ func (d *Datasource) GetPage(ctx context.Context, request *Request) (*Response,
 		}
 	}
 
-	// Check the 'X-Next-Page' header for pagination
-	cursor := ""
-	if res.Header.Get("X-Next-Page") != "" {
-		cursor = res.Header.Get("X-Next-Page")
-	} else {
-		// If there's no cursor, set cursor to empty string to indicate the end of pagination
-		cursor = ""
+	objects, adapterErr := responsePageObjects(request, bodyBytes, noContent)
+	if adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	// objectCount is the number of objects returned by the datasource in the
+	// page, to compute the next offset.
+	objectCount := len(objects)
+
+	// Keep only the successful elements of multi-status responses.
+	if res.StatusCode == http.StatusMultiStatus && request.MultiStatus != nil {
+		objects, err = splitMultiStatus(objects, request.MultiStatus.withDefaults(), d.logger())
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to parse multi-status response: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+	}
+
+	// An entity without objects is a successful empty page, not a missing one.
+	if objects == nil {
+		objects = []map[string]any{}
+	}
+
+	// Drop the objects already returned from a previous source.
+	if len(request.Sources) > 0 {
+		objects, err = dropSeenObjects(objects, request.UniqueIDAttrExternalID, requestCursor)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to drop the objects returned from previous sources: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+	}
+
+	next, adapterErr := paginator.nextCursor(page, &pageResponse{
+		header:        res.Header,
+		cookies:       res.Cookies(),
+		url:           req.URL,
+		body:          bodyBytes,
+		fields:        &response,
+		objectCount:   objectCount,
+		returnedCount: len(objects),
+	})
+	if adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	cursor, adapterErr := d.responseCursor(page, next, len(objects), response.Total)
+	if adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	var totalCount *int64
+
+	if response.Total != nil {
+		total := int64(*response.Total)
+		totalCount = &total
 	}
 
 	// Return a valid response containing the objects and cursor
 	return &Response{
-		Objects: response.Teams, // Parse the teams
-		Cursor:  cursor,         // Handle cursor if provided in the header
+		Objects: objects,
+		Cursor:  cursor,
+
+		RateLimit:  parseRateLimit(res.Header, time.Now()),
+		StatusCode: res.StatusCode,
+		TotalCount: totalCount,
 	}, nil
 }
+
+// responsePageObjects returns the objects of the requested entity held in the
+// given response body, at the configured path or else in the entity's objects
+// keys. A body without content holds no objects.
+func responsePageObjects(request *Request, bodyBytes []byte, noContent bool) ([]map[string]any, *framework.Error) {
+	var objects []map[string]interface{}
+
+	switch {
+	case request.ObjectsJSONPath != "":
+		var document any
+		if err := json.Unmarshal(bodyBytes, &document); err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to deserialize response body: %v", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+
+		objects, err := extractObjects(document, request.ObjectsJSONPath)
+		if err != nil {
+			return nil, &framework.Error{
+				Message: fmt.Sprintf("Failed to extract objects from response body: %v.", err),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+			}
+		}
+
+		return objects, nil
+	default:
+		objectsKey := request.ResponseObjectsKey
+		if objectsKey == "" {
+			entity, found := ValidEntityExternalIDs[request.EntityExternalID]
+			if !found {
+				return nil, &framework.Error{
+					Message: fmt.Sprintf("Invalid entity external ID: %s.", request.EntityExternalID),
+					Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+				}
+			}
+
+			objectsKey = entity.responseObjectsKey
+		}
+
+		// The objects of each key are merged in order, keeping their order.
+		for i, key := range append([]string{objectsKey}, request.AdditionalObjectsKeys...) {
+			keyObjects, found, err := responseObjects(bodyBytes, key)
+			if err != nil {
+				return nil, &framework.Error{
+					Message: fmt.Sprintf("Failed to deserialize response body: %v", err),
+					Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				}
+			}
+
+			// A body without the entity's key is most likely not a page of
+			// the entity, e.g. if the endpoint is misconfigured, rather than
+			// an empty page. Additional keys may be omitted.
+			if !found && i == 0 && !noContent {
+				return nil, &framework.Error{
+					Message: fmt.Sprintf("Response body has no %s key holding the objects of entity %s. "+
+						"Found top-level keys: [%s].",
+						key, request.EntityExternalID, strings.Join(topLevelKeys(bodyBytes), ", ")),
+					Code: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				}
+			}
+
+			objects = append(objects, keyObjects...)
+		}
+	}
+
+	return objects, nil
+}
+
+// newRequestBody returns the body to send to the datasource for the given
+// payload, and the value of the Content-Encoding header to send with it, if any.
+func newRequestBody(payload []byte, gzipBody bool) (io.Reader, string, error) {
+	if !gzipBody {
+		return bytes.NewReader(payload), "", nil
+	}
+
+	var compressed bytes.Buffer
+
+	writer := gzip.NewWriter(&compressed)
+
+	if _, err := writer.Write(payload); err != nil {
+		return nil, "", fmt.Errorf("failed to compress request body: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to compress request body: %w", err)
+	}
+
+	return &compressed, "gzip", nil
+}
+
+// responseBody returns the reader of the given response's body, decompressed
+// if the datasource returned it gzip-compressed. The response body must still
+// be closed by the caller.
+func responseBody(res *http.Response) (io.Reader, error) {
+	if res.Uncompressed || !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return res.Body, nil
+	}
+
+	// Error responses and 204s may announce the encoding without any content.
+	if res.ContentLength == 0 {
+		return http.NoBody, nil
+	}
+
+	return gzip.NewReader(res.Body)
+}
+
+// authorizationHeader returns the Authorization header sending the given API
+// token with the given scheme, which defaults to AuthSchemeToken. A token
+// already holding the scheme's prefix, e.g. "Token token=<key>", is returned
+// as is.
+func authorizationHeader(scheme, token string) string {
+	var prefix string
+
+	switch scheme {
+	case AuthSchemeBearer:
+		prefix = "Bearer "
+	case AuthSchemeBasic:
+		prefix = "Basic "
+	default:
+		prefix = "Token token="
+	}
+
+	if strings.HasPrefix(strings.ToLower(token), strings.ToLower(prefix)) {
+		return token
+	}
+
+	return prefix + token
+}
+
+// statusError returns the error to report for the given HTTP status code of a
+// datasource response, or nil for a success status. A rejected token (401) is
+// reported as a configuration error, and a token lacking the permissions to
+// read the entity (403) as an authentication failure with a distinct message.
+func statusError(statusCode int, retryAfterHeader string) *framework.Error {
+	adapterErr := web.HTTPError(statusCode, retryAfterHeader)
+	if adapterErr == nil {
+		return nil
+	}
+
+	switch statusCode {
+	case http.StatusUnauthorized:
+		adapterErr.Message = "Datasource rejected the authentication credentials. Check the configured token and try again."
+		adapterErr.Code = api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG
+	case http.StatusForbidden:
+		adapterErr.Message = "Datasource denied access due to insufficient scope. Check the permissions granted to the token and try again."
+		adapterErr.Code = api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED
+	}
+
+	return adapterErr
+}
+
+// responseCursor returns the cursor returned for the page following the
+// requested one, given the next cursor computed by the request's paginator, or
+// an empty string if the requested page is the last one.
+//
+// Composite cursors also track the source of entities merged from several
+// endpoints and the number of objects returned so far, and are encoded into an
+// opaque string.
+func (d *Datasource) responseCursor(
+	page *pageRequest, next *compositeCursor, returnedCount int, total *int,
+) (string, *framework.Error) {
+	request := page.request
+
+	if !request.usesCompositeCursor() {
+		if next == nil {
+			return "", nil
+		}
+
+		return next.Token, nil
+	}
+
+	if len(request.Sources) > 0 {
+		next = nextSourceCursor(page.composite, next, len(request.Sources))
+	}
+
+	if request.ReconcileTotalCount {
+		count := page.composite.Count + int64(returnedCount)
+
+		if next != nil {
+			next.Count = count
+		} else {
+			d.reconcileTotalCount(request.EntityExternalID, count, total, request.ReconcileTolerancePercent)
+		}
+	}
+
+	if next == nil {
+		return "", nil
+	}
+
+	cursor, err := encodeCursor(next, request.CompactCursors, request.MaxCursorLength)
+	if err != nil {
+		return "", &framework.Error{
+			Message: fmt.Sprintf("Failed to create next cursor: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return cursor, nil
+}
+
+// reconcileTotalCount logs a warning if the number of objects returned for an
+// entity across all pages diverges from the total announced by the datasource
+// by more than the given tolerance, which may indicate silent truncation.
+func (d *Datasource) reconcileTotalCount(entityExternalID string, count int64, total *int, tolerancePercent float64) {
+	if total == nil {
+		return
+	}
+
+	diff := math.Abs(float64(count - int64(*total)))
+
+	if *total == 0 {
+		if diff > 0 {
+			d.logger().Warn("Received objects from datasource but it announced none.",
+				"entity", entityExternalID, "count", count)
+		}
+
+		return
+	}
+
+	if divergence := diff / float64(*total) * 100; divergence > tolerancePercent {
+		d.logger().Warn("Received a number of objects diverging from the total announced by the datasource.",
+			"entity", entityExternalID, "count", count, "total", *total, "divergencePercent", divergence)
+	}
+}
+
+// templateBody returns the JSON body of a POST search request built from the
+// given template, with the cursor placeholder replaced with the given cursor,
+// as a JSON string or null if empty, and the page size placeholder replaced
+// with the given page size.
+func templateBody(template, cursor string, pageSize int) ([]byte, error) {
+	cursorValue := []byte("null")
+
+	if cursor != "" {
+		var err error
+
+		cursorValue, err = json.Marshal(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	body := strings.NewReplacer(
+		CursorPlaceholder, string(cursorValue),
+		PageSizePlaceholder, strconv.Itoa(pageSize),
+	).Replace(template)
+
+	if !json.Valid([]byte(body)) {
+		return nil, errors.New("request body template is not valid JSON once its placeholders are replaced")
+	}
+
+	return []byte(body), nil
+}
+
+// queryBody returns the JSON body of a POST-query request holding the given
+// query parameters. Integer values are sent as JSON numbers, and parameters
+// with several values as arrays.
+func queryBody(query url.Values) map[string]any {
+	body := make(map[string]any, len(query))
+
+	for name, values := range query {
+		converted := make([]any, 0, len(values))
+
+		for _, value := range values {
+			if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+				converted = append(converted, number)
+			} else {
+				converted = append(converted, value)
+			}
+		}
+
+		if len(converted) == 1 {
+			body[name] = converted[0]
+		} else {
+			body[name] = converted
+		}
+	}
+
+	return body
+}
@@ -0,0 +1,3549 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+// newTestServer returns a server calling the given handler, which is closed
+// at the end of the test.
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newTestDatasourceRequest returns a request for the teams entity of the given
+// server.
+func newTestDatasourceRequest(server *httptest.Server) *Request {
+	return &Request{
+		BaseURL:                server.URL,
+		Token:                  "testtoken",
+		PageSize:               10,
+		EntityExternalID:       Teams,
+		UniqueIDAttrExternalID: "id",
+	}
+}
+
+func TestDatasourceGetPageMaxResponseBytes(t *testing.T) {
+	body := `{"teams":[{"id":"P1"},{"id":"P2"}]}`
+
+	tests := map[string]struct {
+		maxResponseBytes int64
+		wantMessage      string
+	}{
+		"default": {},
+		"at_limit": {
+			maxResponseBytes: int64(len(body)),
+		},
+		"oversized": {
+			maxResponseBytes: int64(len(body)) - 1,
+			wantMessage:      fmt.Sprintf("Response body exceeds the maximum size of %d bytes.", len(body)-1),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(body))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.MaxResponseBytes = tt.maxResponseBytes
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+
+			if tt.wantMessage != "" {
+				if err == nil || err.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL || err.Message != tt.wantMessage {
+					t.Fatalf("Expected error %q, got %+v.", tt.wantMessage, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 2 {
+				t.Errorf("Expected 2 objects, got %v.", response.Objects)
+			}
+		})
+	}
+}
+
+// closeRecordingRoundTripper is an http.RoundTripper answering requests with
+// the responses returned by respond, which records whether their bodies are
+// closed.
+type closeRecordingRoundTripper struct {
+	respond func(*http.Request) (int, string)
+
+	mu     sync.Mutex
+	bodies []*closeRecordingBody
+}
+
+func (rt *closeRecordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	statusCode, body := rt.respond(req)
+
+	recordingBody := &closeRecordingBody{Reader: strings.NewReader(body)}
+
+	rt.mu.Lock()
+	rt.bodies = append(rt.bodies, recordingBody)
+	rt.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       recordingBody,
+		Request:    req,
+	}, nil
+}
+
+// unclosedBodies returns the number of response bodies not closed, and the
+// total number of responses.
+func (rt *closeRecordingRoundTripper) unclosedBodies() (unclosed, total int) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, body := range rt.bodies {
+		if !body.closed.Load() {
+			unclosed++
+		}
+	}
+
+	return unclosed, len(rt.bodies)
+}
+
+// closeRecordingBody is a response body recording whether it is closed.
+type closeRecordingBody struct {
+	io.Reader
+
+	closed atomic.Bool
+}
+
+func (b *closeRecordingBody) Close() error {
+	b.closed.Store(true)
+
+	return nil
+}
+
+func TestDatasourceGetPageClosesResponseBody(t *testing.T) {
+	var datasourceRequests int
+
+	tests := map[string]struct {
+		respond       func(*http.Request) (int, string)
+		modifier      func(*Request)
+		wantResponses int
+		wantError     bool
+	}{
+		"success": {
+			respond: func(*http.Request) (int, string) {
+				return http.StatusOK, `{"teams":[{"id":"P1"}]}`
+			},
+			wantResponses: 1,
+		},
+		"malformed_body": {
+			respond: func(*http.Request) (int, string) {
+				return http.StatusOK, `{"teams":`
+			},
+			wantResponses: 1,
+			wantError:     true,
+		},
+		"oversized_body": {
+			respond: func(*http.Request) (int, string) {
+				return http.StatusOK, `{"teams":[{"id":"P1"}]}`
+			},
+			modifier: func(r *Request) {
+				r.MaxResponseBytes = 4
+			},
+			wantResponses: 1,
+			wantError:     true,
+		},
+		"error_status": {
+			respond: func(*http.Request) (int, string) {
+				return http.StatusInternalServerError, `{"error":{"message":"Internal Server Error"}}`
+			},
+			wantResponses: 1,
+			wantError:     true,
+		},
+		"retries_exhausted": {
+			respond: func(*http.Request) (int, string) {
+				return http.StatusServiceUnavailable, `{"error":{"message":"Service Unavailable"}}`
+			},
+			modifier: func(r *Request) {
+				r.MaxRetries = 1
+				r.RetryBaseDelay = time.Millisecond
+			},
+			wantResponses: 2,
+			wantError:     true,
+		},
+		"token_refreshed": {
+			respond: func(r *http.Request) (int, string) {
+				switch {
+				case r.URL.Path == "/token":
+					return http.StatusOK, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`
+				case datasourceRequests == 0:
+					datasourceRequests++
+
+					return http.StatusUnauthorized, `{"error":{"message":"Unauthorized"}}`
+				default:
+					return http.StatusOK, `{"teams":[{"id":"P1"}]}`
+				}
+			},
+			modifier: func(r *Request) {
+				r.AuthMode = AuthModeOAuth2ClientCredentials
+				r.TokenURL = "https://api.pagerduty.com/token"
+				r.ClientID = "client-id"
+				r.ClientSecret = "client-secret"
+			},
+			// Two token requests and two requests to the datasource.
+			wantResponses: 4,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport := &closeRecordingRoundTripper{respond: tt.respond}
+			datasourceRequests = 0
+
+			request := &Request{
+				BaseURL:                "https://api.pagerduty.com",
+				Token:                  "Token token=testtoken",
+				PageSize:               10,
+				EntityExternalID:       Users,
+				ResponseObjectsKey:     "teams",
+				UniqueIDAttrExternalID: "id",
+			}
+
+			if tt.modifier != nil {
+				tt.modifier(request)
+			}
+
+			_, err := NewClientWithTransport(5, transport).GetPage(context.Background(), request)
+			if gotError := err != nil; gotError != tt.wantError {
+				t.Fatalf("Expected error: %v, got %+v.", tt.wantError, err)
+			}
+
+			unclosed, total := transport.unclosedBodies()
+
+			if total != tt.wantResponses {
+				t.Errorf("Expected %d responses, got %d.", tt.wantResponses, total)
+			}
+
+			if unclosed != 0 {
+				t.Errorf("Expected all response bodies to be closed, got %d unclosed.", unclosed)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageStatusErrors(t *testing.T) {
+	tests := map[string]struct {
+		statusCode     int
+		retryAfter     string
+		body           string
+		wantErrorCode  api_adapter_v1.ErrorCode
+		wantMessage    string
+		wantRetryAfter time.Duration
+	}{
+		"unauthorized": {
+			statusCode:    http.StatusUnauthorized,
+			body:          `{"error":{"message":"Unauthorized","code":2006}}`,
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+			wantMessage:   "rejected the authentication credentials",
+		},
+		"forbidden": {
+			statusCode:    http.StatusForbidden,
+			body:          `{"error":{"message":"Access Denied","code":2010}}`,
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_AUTHENTICATION_FAILED,
+			wantMessage:   "insufficient scope",
+		},
+		"too_many_requests": {
+			statusCode:     http.StatusTooManyRequests,
+			retryAfter:     "30",
+			body:           `{"error":{"message":"Rate Limit Exceeded","code":2020}}`,
+			wantErrorCode:  api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_TOO_MANY_REQUESTS,
+			wantRetryAfter: 30 * time.Second,
+		},
+		// Error bodies with a list of objects must not be taken for a page.
+		"internal_server_error": {
+			statusCode:    http.StatusInternalServerError,
+			body:          `{"teams":[{"id":"P1"}]}`,
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_DATASOURCE_FAILED,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+
+				w.WriteHeader(tt.statusCode)
+				fmt.Fprint(w, tt.body)
+			})
+
+			response, err := NewClient(5).GetPage(context.Background(), newTestDatasourceRequest(server))
+			if err == nil {
+				t.Fatalf("Expected error code %v, got response %+v.", tt.wantErrorCode, response)
+			}
+
+			if err.Code != tt.wantErrorCode {
+				t.Errorf("Expected error code %v, got %v.", tt.wantErrorCode, err.Code)
+			}
+
+			if !strings.Contains(err.Message, tt.wantMessage) {
+				t.Errorf("Expected error message to contain %q, got %q.", tt.wantMessage, err.Message)
+			}
+
+			if tt.wantRetryAfter != 0 && (err.RetryAfter == nil || *err.RetryAfter != tt.wantRetryAfter) {
+				t.Errorf("Expected retry after %v, got %v.", tt.wantRetryAfter, err.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageObjectsJSONPath(t *testing.T) {
+	tests := map[string]struct {
+		body            string
+		objectsJSONPath string
+		wantIDs         []string
+	}{
+		"two_levels_dotted": {
+			body:            `{"data":{"users":[{"id":"P1"},{"id":"P2"}]}}`,
+			objectsJSONPath: "data.users",
+			wantIDs:         []string{"P1", "P2"},
+		},
+		"two_levels_pointer": {
+			body:            `{"data":{"users":[{"id":"P1"},{"id":"P2"}]}}`,
+			objectsJSONPath: "/data/users",
+			wantIDs:         []string{"P1", "P2"},
+		},
+		"three_levels_dotted": {
+			body:            `{"data":{"results":{"items":[{"id":"P1"},{"id":"P2"},{"id":"P3"}]}}}`,
+			objectsJSONPath: "data.results.items",
+			wantIDs:         []string{"P1", "P2", "P3"},
+		},
+		"three_levels_pointer": {
+			body:            `{"data":{"results":{"items":[{"id":"P1"},{"id":"P2"},{"id":"P3"}]}}}`,
+			objectsJSONPath: "/data/results/items",
+			wantIDs:         []string{"P1", "P2", "P3"},
+		},
+		"pointer_array_index": {
+			body:            `{"pages":[{"items":[{"id":"P1"}]},{"items":[{"id":"P2"}]}]}`,
+			objectsJSONPath: "/pages/1/items",
+			wantIDs:         []string{"P2"},
+		},
+		"unset": {
+			body:    `{"teams":[{"id":"P1"}]}`,
+			wantIDs: []string{"P1"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(tt.body))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.ObjectsJSONPath = tt.objectsJSONPath
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			var gotIDs []string
+			for _, object := range response.Objects {
+				gotIDs = append(gotIDs, object["id"].(string))
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantIDs, gotIDs)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageSortBy(t *testing.T) {
+	tests := map[string]struct {
+		sortBy     string
+		stableSort bool
+		wantSortBy string
+	}{
+		"ordered": {
+			sortBy:     "created_at:desc",
+			wantSortBy: "created_at:desc",
+		},
+		"ordered_overrides_stable_sort": {
+			sortBy:     "created_at:desc",
+			stableSort: true,
+			wantSortBy: "created_at:desc",
+		},
+		"stable_sort": {
+			stableSort: true,
+			wantSortBy: "id:asc",
+		},
+		"unordered": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotSortBy string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotSortBy = r.URL.Query().Get(DefaultSortParam)
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.SortBy = tt.sortBy
+			request.StableSort = tt.stableSort
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotSortBy != tt.wantSortBy {
+				t.Errorf("Expected sort order %q, got %q.", tt.wantSortBy, gotSortBy)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageFields(t *testing.T) {
+	tests := map[string]struct {
+		fieldsParam string
+		fields      []string
+		// wantFields is the value of the fields parameter, or nil if not sent.
+		wantFields []string
+	}{
+		"fields": {
+			fieldsParam: "fields",
+			fields:      []string{"id", "email"},
+			wantFields:  []string{"id,email"},
+		},
+		"no_fields_param": {
+			fields: []string{"id", "email"},
+		},
+		"no_fields": {
+			fieldsParam: "fields",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotFields []string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotFields = r.URL.Query()["fields"]
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.FieldsParam = tt.fieldsParam
+			request.Fields = tt.fields
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if !reflect.DeepEqual(gotFields, tt.wantFields) {
+				t.Errorf("Expected fields parameter %v, got %v.", tt.wantFields, gotFields)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageSources(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/teams" && r.URL.Query().Get("offset") == "":
+			w.Header().Set(DefaultCursorHeader, "2")
+			w.Write([]byte(`{"teams":[{"id":"P1"},{"id":"P2"}]}`))
+		case r.URL.Path == "/teams":
+			w.Write([]byte(`{"teams":[{"id":"P3"}]}`))
+		case r.URL.Path == "/external_users":
+			w.Write([]byte(`{"teams":[{"id":"P2"},{"id":"P4"},{"name":"no ID"},{"name":"no ID"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.Sources = []string{"teams", "external_users"}
+
+	// The pages of the first source are returned, then those of the second,
+	// without the objects returned before. Objects without a unique ID can't
+	// be told apart and are never dropped.
+	want := [][]map[string]any{
+		{{"id": "P1"}, {"id": "P2"}},
+		{{"id": "P3"}},
+		{{"id": "P4"}, {"name": "no ID"}, {"name": "no ID"}},
+	}
+
+	client := NewClient(5)
+
+	for i, wantObjects := range want {
+		response, err := client.GetPage(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Expected no error for page %d, got %+v.", i, err)
+		}
+
+		if !reflect.DeepEqual(response.Objects, wantObjects) {
+			t.Errorf("Expected page %d objects %v, got %v.", i, wantObjects, response.Objects)
+		}
+
+		if i == len(want)-1 {
+			if response.Cursor != "" {
+				t.Errorf("Expected no cursor after the last source, got %q.", response.Cursor)
+			}
+
+			break
+		}
+
+		if response.Cursor == "" {
+			t.Fatalf("Expected a cursor after page %d, got none.", i)
+		}
+
+		request.Cursor = response.Cursor
+	}
+}
+
+func TestDatasourceGetPageOffsetCursor(t *testing.T) {
+	tests := map[string]struct {
+		cursor        string
+		wantOffset    string
+		wantErrorCode api_adapter_v1.ErrorCode
+	}{
+		"empty": {
+			cursor:     "",
+			wantOffset: "",
+		},
+		"zero": {
+			cursor:     "0",
+			wantOffset: "0",
+		},
+		"numeric": {
+			cursor:     "100",
+			wantOffset: "100",
+		},
+		"negative": {
+			cursor:        "-10",
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		},
+		"non_numeric": {
+			cursor:        "abc",
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		},
+		"decimal": {
+			cursor:        "10.5",
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		},
+		"overflow": {
+			cursor:        "99999999999999999999",
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests int
+
+			var gotOffset string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				gotOffset = r.URL.Query().Get("offset")
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.Cursor = tt.cursor
+
+			_, err := NewClient(5).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if err == nil || err.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got %+v.", tt.wantErrorCode, err)
+				}
+
+				if !strings.Contains(err.Message, "Cursor is not a valid offset: "+tt.cursor) {
+					t.Errorf("Expected the invalid cursor in the error message, got %q.", err.Message)
+				}
+
+				// Malformed cursors are rejected before reaching the datasource.
+				if requests != 0 {
+					t.Errorf("Expected no request to the datasource, got %d.", requests)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotOffset != tt.wantOffset {
+				t.Errorf("Expected offset %q, got %q.", tt.wantOffset, gotOffset)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageCursorRoundTrip(t *testing.T) {
+	const (
+		total    = 25
+		pageSize = 10
+	)
+
+	tests := map[string]struct {
+		// echoOffset indicates whether the server returns the offset of the
+		// page in the response body.
+		echoOffset bool
+	}{
+		"offset_echoed": {
+			echoOffset: true,
+		},
+		"offset_not_echoed": {
+			echoOffset: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests int
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+				teams := []map[string]any{}
+				for i := offset; i < offset+limit && i < total; i++ {
+					teams = append(teams, map[string]any{"id": fmt.Sprintf("P%d", i)})
+				}
+
+				body := map[string]any{
+					"teams": teams,
+					"limit": limit,
+					"more":  offset+limit < total,
+				}
+
+				if tt.echoOffset {
+					body["offset"] = offset
+				}
+
+				json.NewEncoder(w).Encode(body)
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.PageSize = pageSize
+
+			seen := map[string]bool{}
+
+			for page := 0; ; page++ {
+				if page > total {
+					t.Fatalf("Expected pagination to end, got more than %d pages.", total)
+				}
+
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error, got %+v.", err)
+				}
+
+				for _, object := range response.Objects {
+					id := object["id"].(string)
+					if seen[id] {
+						t.Errorf("Expected object %s to be returned once, got it again on page %d.", id, page)
+					}
+
+					seen[id] = true
+				}
+
+				if response.Cursor == "" {
+					break
+				}
+
+				request.Cursor = response.Cursor
+			}
+
+			if len(seen) != total {
+				t.Errorf("Expected %d objects, got %d.", total, len(seen))
+			}
+
+			if requests != 3 {
+				t.Errorf("Expected 3 pages, got %d.", requests)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageNextOffset(t *testing.T) {
+	tests := map[string]struct {
+		cursor     string
+		nextHeader string
+		body       string
+		wantCursor string
+	}{
+		"header": {
+			nextHeader: "10",
+			body:       `{"teams":[{"id":"P1"}]}`,
+			wantCursor: "10",
+		},
+		"header_takes_precedence": {
+			nextHeader: "50",
+			body:       `{"teams":[{"id":"P1"}],"offset":0,"limit":10,"more":true}`,
+			wantCursor: "50",
+		},
+		"body": {
+			body:       `{"teams":[{"id":"P1"}],"offset":20,"limit":10,"more":true}`,
+			wantCursor: "30",
+		},
+		"body_without_offset": {
+			cursor:     "20",
+			body:       `{"teams":[{"id":"P1"}],"limit":10,"more":true}`,
+			wantCursor: "30",
+		},
+		"body_without_limit": {
+			body:       `{"teams":[{"id":"P1"},{"id":"P2"}],"offset":0,"more":true}`,
+			wantCursor: "2",
+		},
+		"terminal_page": {
+			cursor: "20",
+			body:   `{"teams":[{"id":"P1"}],"offset":20,"limit":10,"more":false}`,
+		},
+		"terminal_page_without_more": {
+			body: `{"teams":[{"id":"P1"}],"offset":0,"limit":10}`,
+		},
+		"more_without_objects": {
+			body: `{"teams":[],"offset":20,"more":true}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				if tt.nextHeader != "" {
+					w.Header().Set("X-Next-Page", tt.nextHeader)
+				}
+
+				fmt.Fprint(w, tt.body)
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.Cursor = tt.cursor
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if response.Cursor != tt.wantCursor {
+				t.Errorf("Expected cursor %q, got %q.", tt.wantCursor, response.Cursor)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageTotalCount(t *testing.T) {
+	total := func(n int64) *int64 {
+		return &n
+	}
+
+	tests := map[string]struct {
+		body          string
+		wantTotal     *int64
+		wantErrorCode api_adapter_v1.ErrorCode
+	}{
+		"total": {
+			body:      `{"teams":[{"id":"P1"}],"more":true,"total":42}`,
+			wantTotal: total(42),
+		},
+		"zero_total": {
+			body:      `{"teams":[],"more":false,"total":0}`,
+			wantTotal: total(0),
+		},
+		"missing_total": {
+			body: `{"teams":[{"id":"P1"}],"more":true}`,
+		},
+		"null_total": {
+			body: `{"teams":[{"id":"P1"}],"more":true,"total":null}`,
+		},
+		"invalid_total": {
+			body:          `{"teams":[{"id":"P1"}],"more":true,"total":"many"}`,
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, tt.body)
+			})
+
+			response, err := NewClient(5).GetPage(context.Background(), newTestDatasourceRequest(server))
+
+			if tt.wantErrorCode != 0 {
+				if err == nil || err.Code != tt.wantErrorCode {
+					t.Fatalf("Expected error code %v, got %+v.", tt.wantErrorCode, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if !reflect.DeepEqual(response.TotalCount, tt.wantTotal) {
+				t.Errorf("Expected total count %v, got %v.", tt.wantTotal, response.TotalCount)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageResponseLimit(t *testing.T) {
+	tests := map[string]struct {
+		maxLimit         int
+		hybridPagination string
+		wantOffsets      []string
+	}{
+		"capped": {
+			maxLimit:    4,
+			wantOffsets: []string{"", "4", "8", "12", "16", "20", "24"},
+		},
+		"not_capped": {
+			maxLimit:    100,
+			wantOffsets: []string{"", "10", "20"},
+		},
+		"hybrid_capped": {
+			maxLimit:         4,
+			hybridPagination: HybridPaginationOffset,
+			wantOffsets:      []string{"", "4", "8", "12", "16", "20", "24"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotOffsets []string
+
+			// The datasource returns 25 objects, capping the page size to
+			// maxLimit and echoing the limit it applied, without the offset.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotOffsets = append(gotOffsets, r.URL.Query().Get("offset"))
+
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				limit = min(limit, tt.maxLimit)
+
+				teams := []map[string]any{}
+				for i := offset; i < min(offset+limit, 25); i++ {
+					teams = append(teams, map[string]any{"id": fmt.Sprintf("P%d", i)})
+				}
+
+				// In hybrid pagination, the datasource also returns a token,
+				// which is only used to detect the last page.
+				if tt.hybridPagination != "" && offset+limit < 25 {
+					w.Header().Set("X-Next-Page", "next")
+				}
+
+				json.NewEncoder(w).Encode(map[string]any{
+					"teams": teams,
+					"limit": limit,
+					"more":  offset+limit < 25,
+				})
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.HybridPagination = tt.hybridPagination
+
+			var objects int
+
+			for page := 0; ; page++ {
+				if page > 25 {
+					t.Fatalf("Expected pagination to end, got more than 25 pages.")
+				}
+
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				objects += len(response.Objects)
+
+				if response.Cursor == "" {
+					break
+				}
+
+				request.Cursor = response.Cursor
+			}
+
+			if objects != 25 {
+				t.Errorf("Expected 25 objects, got %d.", objects)
+			}
+
+			// The next offset is computed with the limit echoed by the
+			// datasource rather than the requested page size of 10.
+			if !reflect.DeepEqual(gotOffsets, tt.wantOffsets) {
+				t.Errorf("Expected offsets %v, got %v.", tt.wantOffsets, gotOffsets)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageReconcileTotalCount(t *testing.T) {
+	tests := map[string]struct {
+		total            int
+		tolerancePercent float64
+		wantWarning      string
+	}{
+		"matching_total": {
+			total: 25,
+		},
+		"total_exceeds_count": {
+			total:       40,
+			wantWarning: "diverging from the total",
+		},
+		"within_tolerance": {
+			total:            26,
+			tolerancePercent: 10,
+		},
+		"beyond_tolerance": {
+			total:            30,
+			tolerancePercent: 10,
+			wantWarning:      "diverging from the total",
+		},
+		"zero_total": {
+			total:       0,
+			wantWarning: "announced none",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The datasource returns 25 objects, 10 per page, regardless of the
+			// total it announces.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+				teams := []map[string]any{}
+				for i := offset; i < min(offset+10, 25); i++ {
+					teams = append(teams, map[string]any{"id": fmt.Sprintf("P%d", i)})
+				}
+
+				json.NewEncoder(w).Encode(map[string]any{
+					"teams":  teams,
+					"offset": offset,
+					"limit":  10,
+					"more":   offset+10 < 25,
+					"total":  tt.total,
+				})
+			})
+
+			var logs bytes.Buffer
+
+			client := &Datasource{
+				Client: server.Client(),
+				Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+			}
+
+			request := newTestDatasourceRequest(server)
+			request.ReconcileTotalCount = true
+			request.ReconcileTolerancePercent = tt.tolerancePercent
+
+			for page := 0; ; page++ {
+				if page > 3 {
+					t.Fatalf("Expected pagination to end, got more than 3 pages.")
+				}
+
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				// The count is only reconciled on the last page.
+				if response.Cursor != "" && logs.Len() > 0 {
+					t.Errorf("Expected no warning before the last page, got %q.", logs.String())
+				}
+
+				if response.Cursor == "" {
+					break
+				}
+
+				request.Cursor = response.Cursor
+			}
+
+			if tt.wantWarning == "" && logs.Len() > 0 {
+				t.Errorf("Expected no warning, got %q.", logs.String())
+			}
+
+			if tt.wantWarning != "" {
+				if !strings.Contains(logs.String(), tt.wantWarning) {
+					t.Errorf("Expected a warning containing %q, got %q.", tt.wantWarning, logs.String())
+				}
+
+				if !strings.Contains(logs.String(), "count=25") {
+					t.Errorf("Expected the warning to include the count of 25, got %q.", logs.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageHybridPagination(t *testing.T) {
+	tests := map[string]struct {
+		hybridPagination string
+		wantOffsets      []string
+	}{
+		"offset": {
+			hybridPagination: HybridPaginationOffset,
+			wantOffsets:      []string{"", "10", "20"},
+		},
+		"header": {
+			hybridPagination: HybridPaginationHeader,
+			wantOffsets:      []string{"", "token-1", "token-2"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotOffsets []string
+
+			// The datasource returns 25 objects, 10 per page, with a token to
+			// request the next page in the X-Next-Page header.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotOffsets = append(gotOffsets, r.URL.Query().Get("offset"))
+
+				page := len(gotOffsets)
+				if page < 3 {
+					w.Header().Set("X-Next-Page", fmt.Sprintf("token-%d", page))
+				}
+
+				teams := []string{}
+				for i := (page - 1) * 10; i < min(page*10, 25); i++ {
+					teams = append(teams, fmt.Sprintf(`{"id":"P%d"}`, i))
+				}
+
+				fmt.Fprintf(w, `{"teams":[%s]}`, strings.Join(teams, ","))
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.HybridPagination = tt.hybridPagination
+
+			var objects int
+
+			for page := 0; page < 3; page++ {
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				objects += len(response.Objects)
+				request.Cursor = response.Cursor
+			}
+
+			if request.Cursor != "" {
+				t.Errorf("Expected no cursor after the last page, got %q.", request.Cursor)
+			}
+
+			if objects != 25 {
+				t.Errorf("Expected 25 objects, got %d.", objects)
+			}
+
+			// The cursors carry both the offset and the token, either of which
+			// is sent to request the next page.
+			if !reflect.DeepEqual(gotOffsets, tt.wantOffsets) {
+				t.Errorf("Expected offsets %v, got %v.", tt.wantOffsets, gotOffsets)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageStartCursor(t *testing.T) {
+	tests := map[string]struct {
+		startCursor      string
+		cursor           string
+		hybridPagination string
+		paginationMode   PaginationType
+		wantQuery        url.Values
+		wantErrorCode    api_adapter_v1.ErrorCode
+	}{
+		"offset": {
+			startCursor: "20",
+			wantQuery:   url.Values{"offset": {"20"}},
+		},
+		"hybrid_offset": {
+			startCursor:      "20",
+			hybridPagination: HybridPaginationOffset,
+			wantQuery:        url.Values{"offset": {"20"}},
+		},
+		"atlassian": {
+			startCursor:    "20",
+			paginationMode: PaginationAtlassian,
+			wantQuery:      url.Values{"startAt": {"20"}},
+		},
+		"cursor": {
+			startCursor:    "abc",
+			paginationMode: PaginationCursor,
+			wantQuery:      url.Values{DefaultCursorQueryParam: {"abc"}},
+		},
+		"cursor_takes_precedence": {
+			startCursor: "20",
+			cursor:      "30",
+			wantQuery:   url.Values{"offset": {"30"}},
+		},
+		"invalid_offset": {
+			startCursor:   "abc",
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		},
+		"invalid_hybrid_offset": {
+			startCursor:      "-1",
+			hybridPagination: HybridPaginationOffset,
+			wantErrorCode:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotQuery url.Values
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotQuery = r.URL.Query()
+
+				fmt.Fprint(w, `{"teams":[{"id":"P1"}]}`)
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.StartCursor = tt.startCursor
+			request.Cursor = tt.cursor
+			request.HybridPagination = tt.hybridPagination
+			request.PaginationMode = tt.paginationMode
+
+			_, err := NewClient(5).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if err == nil {
+					t.Fatalf("Expected error code %v, got no error.", tt.wantErrorCode)
+				}
+
+				if err.Code != tt.wantErrorCode {
+					t.Errorf("Expected error code %v, got %v.", tt.wantErrorCode, err.Code)
+				}
+
+				if gotQuery != nil {
+					t.Errorf("Expected no request to be sent, got query %v.", gotQuery)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			for key, want := range tt.wantQuery {
+				if got := gotQuery[key]; !reflect.DeepEqual(got, want) {
+					t.Errorf("Expected query parameter %s %v, got %v.", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageCursorCookie(t *testing.T) {
+	tests := map[string]struct {
+		startCursor string
+		wantCookies []string
+	}{
+		"first_page": {
+			wantCookies: []string{"", "page-1", "page-2"},
+		},
+		"start_cursor": {
+			startCursor: "page-0",
+			wantCookies: []string{"page-0", "page-1", "page-2"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotCookies []string
+
+			// The datasource returns 25 objects, 10 per page, with the
+			// continuation to request the next page in the "next" cookie.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				var value string
+				if cookie, err := r.Cookie("next"); err == nil {
+					value = cookie.Value
+				}
+
+				if r.URL.Query().Has("offset") {
+					t.Errorf("Expected no offset query parameter, got %q.", r.URL.RawQuery)
+				}
+
+				gotCookies = append(gotCookies, value)
+
+				page := len(gotCookies)
+				if page < 3 {
+					http.SetCookie(w, &http.Cookie{Name: "next", Value: fmt.Sprintf("page-%d", page)})
+				}
+
+				// An unrelated cookie must not be taken for the continuation.
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+
+				teams := []string{}
+				for i := (page - 1) * 10; i < min(page*10, 25); i++ {
+					teams = append(teams, fmt.Sprintf(`{"id":"P%d"}`, i))
+				}
+
+				fmt.Fprintf(w, `{"teams":[%s]}`, strings.Join(teams, ","))
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.CursorCookie = "next"
+			request.StartCursor = tt.startCursor
+
+			var objects int
+
+			for page := 0; page < 3; page++ {
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				if page < 2 && response.Cursor == "" {
+					t.Fatalf("Expected a cursor for page %d, got none.", page)
+				}
+
+				objects += len(response.Objects)
+				request.Cursor = response.Cursor
+			}
+
+			if request.Cursor != "" {
+				t.Errorf("Expected no cursor after the last page, got %q.", request.Cursor)
+			}
+
+			if objects != 25 {
+				t.Errorf("Expected 25 objects, got %d.", objects)
+			}
+
+			if !reflect.DeepEqual(gotCookies, tt.wantCookies) {
+				t.Errorf("Expected cookies %v, got %v.", tt.wantCookies, gotCookies)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPagePageSizeHeaderBodyCursor(t *testing.T) {
+	tests := map[string]struct {
+		paginationMode      PaginationType
+		cursorResponseField string
+		cursorQueryParam    string
+		wantParam           string
+	}{
+		"cursor_pagination": {
+			paginationMode: PaginationCursor,
+			wantParam:      DefaultCursorQueryParam,
+		},
+		"custom_names": {
+			cursorResponseField: "next_token",
+			cursorQueryParam:    "page_token",
+			wantParam:           "page_token",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			responseField := tt.cursorResponseField
+			if responseField == "" {
+				responseField = DefaultCursorResponseField
+			}
+
+			var gotCursors []string
+
+			// The datasource returns two pages, reading the page size from the
+			// X-Limit header and returning the next cursor in the body.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("X-Limit"); got != "10" {
+					t.Errorf("Expected X-Limit header %q, got %q.", "10", got)
+				}
+
+				if r.URL.Query().Has("limit") || r.URL.Query().Has("offset") {
+					t.Errorf("Expected no limit or offset query parameter, got %q.", r.URL.RawQuery)
+				}
+
+				gotCursors = append(gotCursors, r.URL.Query().Get(tt.wantParam))
+
+				if len(gotCursors) == 1 {
+					fmt.Fprintf(w, `{"teams":[{"id":"P1"}],%q:"abc"}`, responseField)
+
+					return
+				}
+
+				fmt.Fprintf(w, `{"teams":[{"id":"P2"}],%q:null}`, responseField)
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.PageSizeHeader = "X-Limit"
+			request.PaginationMode = tt.paginationMode
+			request.CursorResponseField = tt.cursorResponseField
+			request.CursorQueryParam = tt.cursorQueryParam
+
+			var gotIDs []string
+
+			for page := 0; page < 2; page++ {
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				for _, object := range response.Objects {
+					gotIDs = append(gotIDs, object["id"].(string))
+				}
+
+				request.Cursor = response.Cursor
+			}
+
+			if request.Cursor != "" {
+				t.Errorf("Expected no cursor after the last page, got %q.", request.Cursor)
+			}
+
+			if want := []string{"P1", "P2"}; !reflect.DeepEqual(gotIDs, want) {
+				t.Errorf("Expected objects %v, got %v.", want, gotIDs)
+			}
+
+			if want := []string{"", "abc"}; !reflect.DeepEqual(gotCursors, want) {
+				t.Errorf("Expected cursors %v, got %v.", want, gotCursors)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageBookmarkPagination(t *testing.T) {
+	tests := map[string]struct {
+		bookmarkField string
+		wantField     string
+	}{
+		"default_field": {
+			wantField: DefaultBookmarkField,
+		},
+		"custom_field": {
+			bookmarkField: "next",
+			wantField:     "next",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotBookmarks []any
+
+			// The datasource returns two pages of objects, then a page without
+			// objects, always with a bookmark.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("Expected a POST request, got %s.", r.Method)
+				}
+
+				var body map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("Failed to decode request body: %v.", err)
+				}
+
+				gotBookmarks = append(gotBookmarks, body[tt.wantField])
+
+				page := len(gotBookmarks)
+
+				teams := "[]"
+				if page < 3 {
+					teams = fmt.Sprintf(`[{"id":"P%d"}]`, page)
+				}
+
+				fmt.Fprintf(w, `{"teams":%s,%q:"g1AAAA-%d"}`, teams, tt.wantField, page)
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.PaginationMode = PaginationBookmark
+			request.BookmarkField = tt.bookmarkField
+
+			var gotIDs []string
+
+			for page := 0; ; page++ {
+				if page > 3 {
+					t.Fatalf("Expected pagination to end, got more than 3 pages.")
+				}
+
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				for _, object := range response.Objects {
+					gotIDs = append(gotIDs, object["id"].(string))
+				}
+
+				if response.Cursor == "" {
+					break
+				}
+
+				request.Cursor = response.Cursor
+			}
+
+			if want := []string{"P1", "P2"}; !reflect.DeepEqual(gotIDs, want) {
+				t.Errorf("Expected objects %v, got %v.", want, gotIDs)
+			}
+
+			// The bookmark of each page is sent back in the body of the next
+			// request, and pagination ends on the page without objects.
+			if want := []any{nil, "g1AAAA-1", "g1AAAA-2"}; !reflect.DeepEqual(gotBookmarks, want) {
+				t.Errorf("Expected bookmarks %v, got %v.", want, gotBookmarks)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageCursorFromNextURL(t *testing.T) {
+	tests := map[string]struct {
+		cursorResponseField string
+		nextCursorJSONPath  string
+		cursorQueryParam    string
+		wantParam           string
+	}{
+		"response_field": {
+			cursorResponseField: "next",
+			wantParam:           "offset",
+		},
+		"json_path": {
+			nextCursorJSONPath: "links.next",
+			wantParam:          "offset",
+		},
+		"custom_param": {
+			cursorResponseField: "next",
+			cursorQueryParam:    "start",
+			wantParam:           "start",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotOffsets []string
+
+			// The datasource returns 25 objects, 10 per page, with the URL of
+			// the next page, which carries its offset, in the body.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotOffsets = append(gotOffsets, r.URL.Query().Get(tt.wantParam))
+
+				offset, _ := strconv.Atoi(r.URL.Query().Get(tt.wantParam))
+
+				teams := []map[string]any{}
+				for i := offset; i < min(offset+10, 25); i++ {
+					teams = append(teams, map[string]any{"id": fmt.Sprintf("P%d", i)})
+				}
+
+				var next any
+				if offset+10 < 25 {
+					next = fmt.Sprintf("https://api.example.com/teams?limit=10&%s=%d", tt.wantParam, offset+10)
+				}
+
+				body := map[string]any{"teams": teams}
+				if tt.nextCursorJSONPath != "" {
+					body["links"] = map[string]any{"next": next}
+				} else {
+					body[tt.cursorResponseField] = next
+				}
+
+				json.NewEncoder(w).Encode(body)
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.CursorFromNextURL = true
+			request.CursorResponseField = tt.cursorResponseField
+			request.NextCursorJSONPath = tt.nextCursorJSONPath
+			request.CursorQueryParam = tt.cursorQueryParam
+
+			var objects int
+
+			for page := 0; ; page++ {
+				if page > 3 {
+					t.Fatalf("Expected pagination to end, got more than 3 pages.")
+				}
+
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				objects += len(response.Objects)
+
+				if response.Cursor == "" {
+					break
+				}
+
+				request.Cursor = response.Cursor
+			}
+
+			if objects != 25 {
+				t.Errorf("Expected 25 objects, got %d.", objects)
+			}
+
+			// The offset of each page is extracted from the next URL rather
+			// than the whole URL being sent back.
+			if want := []string{"", "10", "20"}; !reflect.DeepEqual(gotOffsets, want) {
+				t.Errorf("Expected offsets %v, got %v.", want, gotOffsets)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageStableSort(t *testing.T) {
+	tests := map[string]struct {
+		stableSort   bool
+		sortParam    string
+		cursorCookie string
+		wantQuery    url.Values
+	}{
+		"default_param": {
+			stableSort: true,
+			wantQuery:  url.Values{DefaultSortParam: {"id:asc"}},
+		},
+		"custom_param": {
+			stableSort: true,
+			sortParam:  "order_by",
+			wantQuery:  url.Values{"order_by": {"id:asc"}},
+		},
+		// Cursors don't drift when objects change during the sync.
+		"cookie_pagination": {
+			stableSort:   true,
+			cursorCookie: "next",
+			wantQuery:    url.Values{},
+		},
+		"unset": {
+			wantQuery: url.Values{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotQuery url.Values
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotQuery = r.URL.Query()
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.StableSort = tt.stableSort
+			request.SortParam = tt.sortParam
+			request.CursorCookie = tt.cursorCookie
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			for _, param := range []string{DefaultSortParam, "order_by"} {
+				if got, want := gotQuery.Get(param), tt.wantQuery.Get(param); got != want {
+					t.Errorf("Expected %s %q, got %q.", param, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageAtlassianPagination(t *testing.T) {
+	tests := map[string]struct {
+		names        *AtlassianPagination
+		isLast       bool
+		total        bool
+		wantStartAts []string
+	}{
+		"is_last": {
+			isLast:       true,
+			wantStartAts: []string{"0", "10"},
+		},
+		"total": {
+			total:        true,
+			wantStartAts: []string{"0", "10"},
+		},
+		"short_page": {
+			wantStartAts: []string{"0", "10"},
+		},
+		"custom_names": {
+			names: &AtlassianPagination{
+				StartAtField:    "start",
+				MaxResultsField: "size",
+				IsLastField:     "last",
+			},
+			isLast:       true,
+			wantStartAts: []string{"0", "10"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			names := tt.names.withDefaults()
+
+			var gotStartAts, gotLimits []string
+
+			// The datasource returns 15 objects in two pages.
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotStartAts = append(gotStartAts, r.URL.Query().Get(names.StartAtField))
+				gotLimits = append(gotLimits, r.URL.Query().Get(names.MaxResultsField))
+
+				startAt, _ := strconv.Atoi(r.URL.Query().Get(names.StartAtField))
+
+				teams := []map[string]any{}
+				for i := startAt; i < min(startAt+10, 15); i++ {
+					teams = append(teams, map[string]any{"id": fmt.Sprintf("P%d", i)})
+				}
+
+				body := map[string]any{
+					"teams":               teams,
+					names.StartAtField:    startAt,
+					names.MaxResultsField: 10,
+				}
+
+				if tt.isLast {
+					body[names.IsLastField] = startAt+10 >= 15
+				}
+
+				if tt.total {
+					body[names.TotalField] = 15
+				}
+
+				json.NewEncoder(w).Encode(body)
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.PaginationMode = PaginationAtlassian
+			request.AtlassianPagination = tt.names
+
+			var objects int
+
+			for page := 0; ; page++ {
+				if page > 2 {
+					t.Fatalf("Expected pagination to end, got more than 2 pages.")
+				}
+
+				response, err := client.GetPage(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+				}
+
+				objects += len(response.Objects)
+
+				if response.Cursor == "" {
+					break
+				}
+
+				request.Cursor = response.Cursor
+			}
+
+			if objects != 15 {
+				t.Errorf("Expected 15 objects, got %d.", objects)
+			}
+
+			if !reflect.DeepEqual(gotStartAts, tt.wantStartAts) {
+				t.Errorf("Expected %s values %v, got %v.", names.StartAtField, tt.wantStartAts, gotStartAts)
+			}
+
+			for _, limit := range gotLimits {
+				if limit != "10" {
+					t.Errorf("Expected %s of 10, got %q.", names.MaxResultsField, limit)
+				}
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageAtlassianPaginationInvalidCursor(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to be sent for an invalid cursor.")
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.PaginationMode = PaginationAtlassian
+	request.Cursor = "-10"
+
+	_, err := NewClient(5).GetPage(context.Background(), request)
+	if err == nil {
+		t.Fatal("Expected an error, got none.")
+	}
+
+	if err.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG {
+		t.Errorf("Expected error code %v, got %v.", api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG, err.Code)
+	}
+}
+
+func TestDatasourceGetPageRateLimit(t *testing.T) {
+	tests := map[string]struct {
+		header        http.Header
+		wantRateLimit bool
+	}{
+		"headers_set": {
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"42"},
+				"X-Ratelimit-Reset":     {"30"},
+			},
+			wantRateLimit: true,
+		},
+		"headers_absent": {
+			header: http.Header{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range tt.header {
+					w.Header()[key] = values
+				}
+
+				fmt.Fprint(w, `{"teams":[{"id":"P1"}]}`)
+			})
+
+			start := time.Now()
+
+			response, err := NewClient(5).GetPage(context.Background(), newTestDatasourceRequest(server))
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if !tt.wantRateLimit {
+				if response.RateLimit != nil {
+					t.Errorf("Expected no rate limit, got %+v.", response.RateLimit)
+				}
+
+				return
+			}
+
+			if response.RateLimit == nil {
+				t.Fatal("Expected a rate limit, got none.")
+			}
+
+			if got := response.RateLimit.Remaining; got == nil || *got != 42 {
+				t.Errorf("Expected 42 remaining requests, got %v.", got)
+			}
+
+			// The reset is relative to the time the response was received.
+			if reset := response.RateLimit.Reset; reset.Before(start.Add(30*time.Second)) ||
+				reset.After(time.Now().Add(30*time.Second)) {
+				t.Errorf("Expected reset in 30s, got %v.", reset)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageDuplicateKeys(t *testing.T) {
+	tests := map[string]struct {
+		duplicateKeys string
+		wantErrorCode api_adapter_v1.ErrorCode
+		wantWarning   bool
+	}{
+		"error": {
+			duplicateKeys: DuplicateKeysError,
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+		"warn": {
+			duplicateKeys: DuplicateKeysWarn,
+			wantWarning:   true,
+		},
+		"unset": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `{"teams":[{"id":"P1","email":"old@example.com","email":"new@example.com"}]}`)
+			})
+
+			var logs bytes.Buffer
+
+			client := &Datasource{
+				Client: server.Client(),
+				Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+			}
+
+			request := newTestDatasourceRequest(server)
+			request.DuplicateKeys = tt.duplicateKeys
+
+			response, err := client.GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if err == nil {
+					t.Fatalf("Expected error code %v, got no error.", tt.wantErrorCode)
+				}
+
+				if err.Code != tt.wantErrorCode {
+					t.Errorf("Expected error code %v, got %v.", tt.wantErrorCode, err.Code)
+				}
+
+				if !strings.Contains(err.Message, "$.teams[0].email") {
+					t.Errorf("Expected error message to hold the duplicate key, got %q.", err.Message)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			// The last value of a duplicate key is kept.
+			if len(response.Objects) != 1 || response.Objects[0]["email"] != "new@example.com" {
+				t.Errorf("Expected the last value of the duplicate key, got %v.", response.Objects)
+			}
+
+			if gotWarning := strings.Contains(logs.String(), "$.teams[0].email"); gotWarning != tt.wantWarning {
+				t.Errorf("Expected warning %v, got logs %q.", tt.wantWarning, logs.String())
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageVerifyResponseDigest(t *testing.T) {
+	const body = `{"teams":[{"id":"P1"}]}`
+
+	sum := sha256.Sum256([]byte(body))
+	digest := "sha-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := map[string]struct {
+		digest        string
+		gzip          bool
+		wantErrorCode api_adapter_v1.ErrorCode
+	}{
+		"matching": {
+			digest: digest,
+		},
+		"matching_gzip": {
+			digest: digest,
+			gzip:   true,
+		},
+		"mismatching": {
+			digest:        "sha-256=" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size)),
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+		"missing": {
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				if tt.digest != "" {
+					w.Header().Set("Digest", tt.digest)
+				}
+
+				// The digest is computed over the decompressed body.
+				if tt.gzip {
+					w.Header().Set("Content-Encoding", "gzip")
+
+					writer := gzip.NewWriter(w)
+					writer.Write([]byte(body))
+					writer.Close()
+
+					return
+				}
+
+				fmt.Fprint(w, body)
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.VerifyResponseDigest = true
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if err == nil {
+					t.Fatalf("Expected error code %v, got no error.", tt.wantErrorCode)
+				}
+
+				if err.Code != tt.wantErrorCode {
+					t.Errorf("Expected error code %v, got %v.", tt.wantErrorCode, err.Code)
+				}
+
+				if !strings.Contains(err.Message, "Failed to verify response body integrity") {
+					t.Errorf("Expected an integrity error, got %q.", err.Message)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %d.", len(response.Objects))
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageMultiStatus(t *testing.T) {
+	const body = `{"teams":[` +
+		`{"status":200,"body":{"id":"P1"}},` +
+		`{"status":404,"body":{"error":"user not found"}},` +
+		`{"status":"200 OK","body":{"id":"P2"}}` +
+		`]}`
+
+	tests := map[string]struct {
+		multiStatus   *MultiStatus
+		wantIDs       []string
+		wantErrorCode api_adapter_v1.ErrorCode
+	}{
+		"failures_under_threshold": {
+			multiStatus: &MultiStatus{MaxFailedPercent: 50},
+			wantIDs:     []string{"P1", "P2"},
+		},
+		"failures_over_threshold": {
+			multiStatus:   &MultiStatus{MaxFailedPercent: 10},
+			wantErrorCode: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusMultiStatus)
+				fmt.Fprint(w, body)
+			})
+
+			var logs bytes.Buffer
+
+			client := &Datasource{
+				Client: server.Client(),
+				Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+			}
+
+			request := newTestDatasourceRequest(server)
+			request.MultiStatus = tt.multiStatus
+
+			response, err := client.GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if err == nil {
+					t.Fatalf("Expected error code %v, got no error.", tt.wantErrorCode)
+				}
+
+				if err.Code != tt.wantErrorCode {
+					t.Errorf("Expected error code %v, got %v.", tt.wantErrorCode, err.Code)
+				}
+
+				if !strings.Contains(err.Message, "user not found") {
+					t.Errorf("Expected error message to hold the failed element, got %q.", err.Message)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			var gotIDs []string
+			for _, object := range response.Objects {
+				gotIDs = append(gotIDs, object["id"].(string))
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantIDs, gotIDs)
+			}
+
+			// The failed elements are skipped with a warning.
+			if !strings.Contains(logs.String(), "user not found") {
+				t.Errorf("Expected a warning with the failed element, got logs %q.", logs.String())
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageEscalationPolicies(t *testing.T) {
+	var gotPath string
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"escalation_policies":[{"id":"PEP1","name":"Primary"},{"id":"PEP2","name":"Secondary"}],"more":false}`))
+	})
+
+	entity := ValidEntityExternalIDs[EscalationPolicies]
+
+	request := newTestDatasourceRequest(server)
+	request.EntityExternalID = EscalationPolicies
+	request.Endpoint = entity.endpoint
+	request.ResponseObjectsKey = entity.responseObjectsKey
+	request.UniqueIDAttrExternalID = entity.uniqueIDAttrExternalID
+
+	response, err := NewClient(5).GetPage(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	if gotPath != "/escalation_policies" {
+		t.Errorf("Expected request to /escalation_policies, got %s.", gotPath)
+	}
+
+	wantObjects := []map[string]any{
+		{"id": "PEP1", "name": "Primary"},
+		{"id": "PEP2", "name": "Secondary"},
+	}
+
+	if !reflect.DeepEqual(response.Objects, wantObjects) {
+		t.Errorf("Expected objects %v, got %v.", wantObjects, response.Objects)
+	}
+
+	if response.Cursor != "" {
+		t.Errorf("Expected no next cursor, got %q.", response.Cursor)
+	}
+}
+
+func TestDatasourceGetPageEndpoint(t *testing.T) {
+	tests := map[string]struct {
+		entityExternalID string
+		endpoint         string
+		wantPath         string
+	}{
+		"external_id": {
+			entityExternalID: Users,
+			wantPath:         "/users",
+		},
+		"endpoint": {
+			entityExternalID: EscalationPolicies,
+			endpoint:         "escalation_policies",
+			wantPath:         "/escalation_policies",
+		},
+		"nested_endpoint": {
+			entityExternalID: LogEntries,
+			endpoint:         "incidents/PI1/log_entries",
+			wantPath:         "/incidents/PI1/log_entries",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotPath string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				fmt.Fprintf(w, `{%q:[]}`, path.Base(r.URL.Path))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.EntityExternalID = tt.entityExternalID
+			request.Endpoint = tt.endpoint
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotPath != tt.wantPath {
+				t.Errorf("Expected request to %s, got %s.", tt.wantPath, gotPath)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageIncidentStatuses(t *testing.T) {
+	tests := map[string]struct {
+		entityExternalID string
+		statuses         []string
+		wantStatuses     []string
+	}{
+		"single_status": {
+			entityExternalID: Incidents,
+			statuses:         []string{IncidentStatusTriggered},
+			wantStatuses:     []string{"triggered"},
+		},
+		"multiple_statuses": {
+			entityExternalID: Incidents,
+			statuses:         []string{IncidentStatusTriggered, IncidentStatusAcknowledged},
+			wantStatuses:     []string{"triggered", "acknowledged"},
+		},
+		"no_statuses": {
+			entityExternalID: Incidents,
+		},
+		// The statuses only filter incidents.
+		"other_entity": {
+			entityExternalID: Users,
+			statuses:         []string{IncidentStatusResolved},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotPath string
+			var gotStatuses []string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotStatuses = r.URL.Query()["statuses[]"]
+				fmt.Fprintf(w, `{"%s":[{"id":"P1"}]}`, tt.entityExternalID)
+			})
+
+			entity := ValidEntityExternalIDs[tt.entityExternalID]
+
+			request := newTestDatasourceRequest(server)
+			request.EntityExternalID = tt.entityExternalID
+			request.ResponseObjectsKey = entity.responseObjectsKey
+			request.UniqueIDAttrExternalID = entity.uniqueIDAttrExternalID
+			request.IncidentStatuses = tt.statuses
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if wantPath := "/" + tt.entityExternalID; gotPath != wantPath {
+				t.Errorf("Expected request to %s, got %s.", wantPath, gotPath)
+			}
+
+			if !reflect.DeepEqual(gotStatuses, tt.wantStatuses) {
+				t.Errorf("Expected statuses %v, got %v.", tt.wantStatuses, gotStatuses)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %d.", len(response.Objects))
+			}
+		})
+	}
+}
+
+// recordingMetrics is a MetricsRecorder recording the metrics of the pages
+// requested from the datasource.
+type recordingMetrics struct {
+	started  []string
+	finished []RequestMetrics
+}
+
+func (m *recordingMetrics) RequestStarted(entityExternalID string) {
+	m.started = append(m.started, entityExternalID)
+}
+
+func (m *recordingMetrics) RequestFinished(_ string, metrics RequestMetrics) {
+	m.finished = append(m.finished, metrics)
+}
+
+func TestDatasourceGetPageMetrics(t *testing.T) {
+	tests := map[string]struct {
+		statusCode int
+		body       string
+		wantErr    bool
+	}{
+		"success": {
+			statusCode: http.StatusOK,
+			body:       `{"teams":[{"id":"P1"}]}`,
+		},
+		"error_status": {
+			statusCode: http.StatusInternalServerError,
+			body:       `{"error":{"message":"Internal Server Error"}}`,
+			wantErr:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			const delay = 10 * time.Millisecond
+
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				time.Sleep(delay)
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			})
+
+			metrics := &recordingMetrics{}
+
+			client := NewClient(5).(*Datasource)
+			client.Metrics = metrics
+
+			_, err := client.GetPage(context.Background(), newTestDatasourceRequest(server))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error: %v, got %+v.", tt.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(metrics.started, []string{Teams}) {
+				t.Errorf("Expected one request started for %s, got %v.", Teams, metrics.started)
+			}
+
+			if len(metrics.finished) != 1 {
+				t.Fatalf("Expected one request finished, got %d.", len(metrics.finished))
+			}
+
+			got := metrics.finished[0]
+
+			if got.StatusCode != tt.statusCode {
+				t.Errorf("Expected status code %d, got %d.", tt.statusCode, got.StatusCode)
+			}
+
+			if got.Duration < delay {
+				t.Errorf("Expected a duration of at least %v, got %v.", delay, got.Duration)
+			}
+
+			if (got.Err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %+v.", tt.wantErr, got.Err)
+			}
+
+			// The body of error responses is only partially read for the error
+			// message.
+			if !tt.wantErr && got.BytesRead != int64(len(tt.body)) {
+				t.Errorf("Expected %d bytes read, got %d.", len(tt.body), got.BytesRead)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageLinkHeader(t *testing.T) {
+	var gotQueries []string
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotQueries = append(gotQueries, r.URL.RawQuery)
+
+		if r.URL.Query().Get("page_token") == "" {
+			w.Header().Set("Link", `</users?page_token=abc&limit=10>; rel="next"`)
+			w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+
+			return
+		}
+
+		w.Write([]byte(`{"teams":[{"id":"P2"}]}`))
+	})
+
+	client := NewClient(5)
+	request := newTestDatasourceRequest(server)
+	request.PaginationMode = PaginationLinkHeader
+
+	response, err := client.GetPage(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	wantCursor := server.URL + "/users?page_token=abc&limit=10"
+	if response.Cursor != wantCursor {
+		t.Fatalf("Expected cursor %q, got %q.", wantCursor, response.Cursor)
+	}
+
+	request.Cursor = response.Cursor
+
+	response, err = client.GetPage(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	if response.Cursor != "" || len(response.Objects) != 1 || response.Objects[0]["id"] != "P2" {
+		t.Errorf("Expected the last page with object P2, got %+v.", response)
+	}
+
+	// The next page is requested from the Link URL verbatim.
+	wantQueries := []string{"limit=10", "page_token=abc&limit=10"}
+	if !reflect.DeepEqual(gotQueries, wantQueries) {
+		t.Errorf("Expected queries %v, got %v.", wantQueries, gotQueries)
+	}
+}
+
+func TestDatasourceGetPageLinkHeaderCrossHost(t *testing.T) {
+	var requests int
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.PaginationMode = PaginationLinkHeader
+	request.Cursor = "https://attacker.example.com/users?page_token=abc"
+
+	response, err := NewClient(5).GetPage(context.Background(), request)
+	if err == nil || err.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG {
+		t.Fatalf("Expected an invalid page request error, got %+v.", err)
+	}
+
+	if response.Cursor != request.Cursor {
+		t.Errorf("Expected the request's cursor to resume from, got %q.", response.Cursor)
+	}
+
+	if requests != 0 {
+		t.Errorf("Expected no request to the datasource, got %d.", requests)
+	}
+}
+
+func TestDatasourceGetPageUserAgent(t *testing.T) {
+	tests := map[string]struct {
+		userAgent     string
+		wantUserAgent string
+	}{
+		"default": {
+			wantUserAgent: DefaultUserAgent,
+		},
+		"configured": {
+			userAgent:     "acme-sync/2.3 (ops@acme.example.com)",
+			wantUserAgent: "acme-sync/2.3 (ops@acme.example.com)",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotUserAgent string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotUserAgent = r.Header.Get("User-Agent")
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.UserAgent = tt.userAgent
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotUserAgent != tt.wantUserAgent {
+				t.Errorf("Expected User-Agent %q, got %q.", tt.wantUserAgent, gotUserAgent)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageAllowedContentTypes(t *testing.T) {
+	tests := map[string]struct {
+		contentType         string
+		allowedContentTypes []string
+		wantErrorCode       api_adapter_v1.ErrorCode
+	}{
+		"allowed": {
+			contentType:         "application/json",
+			allowedContentTypes: []string{"application/json"},
+		},
+		"allowed_with_parameters": {
+			contentType:         "Application/JSON; charset=utf-8",
+			allowedContentTypes: []string{"application/json"},
+		},
+		"allowed_wildcard_subtype": {
+			contentType:         "application/vnd.api+json",
+			allowedContentTypes: []string{"application/*"},
+		},
+		"disallowed": {
+			contentType:         "text/html",
+			allowedContentTypes: []string{"application/json"},
+			wantErrorCode:       api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+		"disallowed_wildcard_subtype": {
+			contentType:         "text/plain",
+			allowedContentTypes: []string{"application/*"},
+			wantErrorCode:       api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+		"invalid": {
+			contentType:         "json;;",
+			allowedContentTypes: []string{"application/json"},
+			wantErrorCode:       api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		},
+		"unset": {
+			contentType: "text/html",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, `{"teams":[{"id":"P1"}]}`)
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.AllowedContentTypes = tt.allowedContentTypes
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+
+			if tt.wantErrorCode != 0 {
+				if err == nil {
+					t.Fatalf("Expected error code %v, got no error.", tt.wantErrorCode)
+				}
+
+				if err.Code != tt.wantErrorCode {
+					t.Errorf("Expected error code %v, got %v.", tt.wantErrorCode, err.Code)
+				}
+
+				if !strings.Contains(err.Message, "Refusing to parse response body") {
+					t.Errorf("Expected a content type error, got %q.", err.Message)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %d.", len(response.Objects))
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageEmptyResponse(t *testing.T) {
+	tests := map[string]struct {
+		statusCode          int
+		allowedContentTypes []string
+		objectsJSONPath     string
+	}{
+		"no_content": {
+			statusCode: http.StatusNoContent,
+		},
+		"no_content_with_allowed_content_types": {
+			statusCode:          http.StatusNoContent,
+			allowedContentTypes: []string{"application/json"},
+		},
+		"empty_body": {
+			statusCode: http.StatusOK,
+		},
+		"empty_body_with_objects_path": {
+			statusCode:      http.StatusOK,
+			objectsJSONPath: "data.users",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.AllowedContentTypes = tt.allowedContentTypes
+			request.ObjectsJSONPath = tt.objectsJSONPath
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if response.Objects == nil || len(response.Objects) != 0 || response.Cursor != "" {
+				t.Errorf("Expected an empty last page, got %+v.", response)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageSuccessfulStatus(t *testing.T) {
+	tests := map[string]struct {
+		statusCode int
+		body       string
+		wantLog    bool
+	}{
+		"ok": {
+			statusCode: http.StatusOK,
+			body:       `{"teams":[]}`,
+		},
+		"no_content": {
+			statusCode: http.StatusNoContent,
+			wantLog:    true,
+		},
+		"partial_content": {
+			statusCode: http.StatusPartialContent,
+			body:       `{"teams":[]}`,
+			wantLog:    true,
+		},
+		"accepted": {
+			statusCode: http.StatusAccepted,
+			wantLog:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			})
+
+			var logs bytes.Buffer
+
+			client := &Datasource{
+				Client: server.Client(),
+				Logger: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			}
+
+			response, err := client.GetPage(context.Background(), newTestDatasourceRequest(server))
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 0 || response.Cursor != "" {
+				t.Errorf("Expected an empty last page, got %+v.", response)
+			}
+
+			if response.StatusCode != tt.statusCode {
+				t.Errorf("Expected status code %d, got %d.", tt.statusCode, response.StatusCode)
+			}
+
+			// Successful statuses other than 200 are logged at debug level with
+			// their code.
+			wantLog := fmt.Sprintf(`level=DEBUG msg="Datasource returned a successful status other than 200." entity=teams status=%d`,
+				tt.statusCode)
+			if gotLog := strings.Contains(logs.String(), wantLog); gotLog != tt.wantLog {
+				t.Errorf("Expected %q logged: %v, got logs %q.", wantLog, tt.wantLog, logs.String())
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageTimeout(t *testing.T) {
+	tests := map[string]struct {
+		parentTimeout  time.Duration
+		requestTimeout time.Duration
+		wantMessage    string
+	}{
+		"parent_deadline_shorter": {
+			parentTimeout:  50 * time.Millisecond,
+			requestTimeout: 5 * time.Second,
+			wantMessage:    "reached the caller's deadline",
+		},
+		"parent_deadline_longer": {
+			parentTimeout:  5 * time.Second,
+			requestTimeout: 50 * time.Millisecond,
+			wantMessage:    "timed out",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			release := make(chan struct{})
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			})
+			defer close(release)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.parentTimeout)
+			defer cancel()
+
+			request := newTestDatasourceRequest(server)
+			request.RequestTimeout = tt.requestTimeout
+
+			start := time.Now()
+
+			_, err := NewClient(10).GetPage(ctx, request)
+			if err == nil {
+				t.Fatal("Expected an error, got none.")
+			}
+
+			if !strings.Contains(err.Message, tt.wantMessage) {
+				t.Errorf("Expected error message to contain %q, got %q.", tt.wantMessage, err.Message)
+			}
+
+			// The request is aborted at the earlier of both deadlines.
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Expected request to be aborted within the shorter timeout, took %v.", elapsed)
+			}
+		})
+	}
+}
+
+// cancellingRoundTripper is an http.RoundTripper sending requests with
+// http.DefaultTransport, and calling cancel, if set, once the headers of the
+// response are received.
+type cancellingRoundTripper struct {
+	cancel context.CancelFunc
+}
+
+func (rt *cancellingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+
+	if rt.cancel != nil {
+		rt.cancel()
+	}
+
+	return res, err
+}
+
+func TestDatasourceGetPageCancelled(t *testing.T) {
+	tests := map[string]struct {
+		// cancelAt is when the parent context is cancelled: "before" the
+		// request is sent, "request" while the server holds the response, or
+		// "body" once the server has sent part of the body.
+		cancelAt    string
+		wantMessage string
+	}{
+		"before_request": {
+			cancelAt:    "before",
+			wantMessage: "cancelled while",
+		},
+		"during_request": {
+			cancelAt:    "request",
+			wantMessage: "cancelled while sending the request",
+		},
+		"during_body": {
+			cancelAt:    "body",
+			wantMessage: "cancelled while reading the response body",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var requests int
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				if tt.cancelAt == "body" {
+					w.Write([]byte(`{"teams":[`))
+					w.(http.Flusher).Flush()
+				} else {
+					cancel()
+				}
+
+				<-r.Context().Done()
+			})
+
+			if tt.cancelAt == "before" {
+				cancel()
+			}
+
+			transport := &cancellingRoundTripper{}
+			if tt.cancelAt == "body" {
+				transport.cancel = cancel
+			}
+
+			request := newTestDatasourceRequest(server)
+			request.RequestTimeout = 5 * time.Second
+
+			_, err := NewClientWithTransport(10, transport).GetPage(ctx, request)
+			if err == nil {
+				t.Fatal("Expected an error, got none.")
+			}
+
+			if err.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL {
+				t.Errorf("Expected error code %v, got %v.", api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL, err.Code)
+			}
+
+			if !strings.Contains(err.Message, tt.wantMessage) {
+				t.Errorf("Expected error message to contain %q, got %q.", tt.wantMessage, err.Message)
+			}
+
+			// A cancelled request is not retried.
+			if tt.cancelAt != "before" && requests != 1 {
+				t.Errorf("Expected 1 request, got %d.", requests)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageTransportError(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {})
+
+	request := newTestDatasourceRequest(server)
+	// The server is closed, so the request fails without being cancelled.
+	server.Close()
+
+	_, err := NewClient(10).GetPage(context.Background(), request)
+	if err == nil {
+		t.Fatal("Expected an error, got none.")
+	}
+
+	for _, aborted := range []string{"cancelled", "deadline", "timed out"} {
+		if strings.Contains(err.Message, aborted) {
+			t.Errorf("Expected a transport error, got %q.", err.Message)
+		}
+	}
+}
+
+func TestDatasourceRequestTimeout(t *testing.T) {
+	datasource := NewClient(60).(*Datasource)
+	request := &Request{RequestTimeout: 20 * time.Second}
+
+	if got := datasource.requestTimeout(context.Background(), request); got != 20*time.Second {
+		t.Errorf("Expected the configured timeout without a parent deadline, got %v.", got)
+	}
+
+	longer, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if got := datasource.requestTimeout(longer, request); got != 20*time.Second {
+		t.Errorf("Expected the configured timeout with a longer parent deadline, got %v.", got)
+	}
+
+	shorter, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if got := datasource.requestTimeout(shorter, request); got > 2*time.Second || got < time.Second {
+		t.Errorf("Expected the time remaining before the parent deadline, got %v.", got)
+	}
+}
+
+func TestDatasourceRequestTimeoutContextValue(t *testing.T) {
+	tests := map[string]struct {
+		clientTimeout  int
+		requestTimeout time.Duration
+		ctxTimeout     any
+		want           time.Duration
+	}{
+		"context_value_overrides_config": {
+			clientTimeout:  60,
+			requestTimeout: 20 * time.Second,
+			ctxTimeout:     10 * time.Second,
+			want:           10 * time.Second,
+		},
+		"context_value_without_config": {
+			clientTimeout: 60,
+			ctxTimeout:    30 * time.Second,
+			want:          30 * time.Second,
+		},
+		"context_value_bounded_by_client_timeout": {
+			clientTimeout:  15,
+			requestTimeout: 5 * time.Second,
+			ctxTimeout:     30 * time.Second,
+			want:           15 * time.Second,
+		},
+		"zero_context_value_ignored": {
+			clientTimeout:  60,
+			requestTimeout: 20 * time.Second,
+			ctxTimeout:     time.Duration(0),
+			want:           20 * time.Second,
+		},
+		"wrong_type_context_value_ignored": {
+			clientTimeout:  60,
+			requestTimeout: 20 * time.Second,
+			ctxTimeout:     10,
+			want:           20 * time.Second,
+		},
+		"no_context_value": {
+			clientTimeout: 60,
+			want:          DefaultRequestTimeout,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			datasource := NewClient(tt.clientTimeout).(*Datasource)
+			request := &Request{RequestTimeout: tt.requestTimeout}
+
+			ctx := context.Background()
+			if tt.ctxTimeout != nil {
+				ctx = context.WithValue(ctx, RequestTimeoutContextKey, tt.ctxTimeout)
+			}
+
+			if got := datasource.requestTimeout(ctx, request); got != tt.want {
+				t.Errorf("Expected timeout %v, got %v.", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageContextTimeout(t *testing.T) {
+	release := make(chan struct{})
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+
+	request := newTestDatasourceRequest(server)
+	request.RequestTimeout = 5 * time.Second
+
+	ctx := WithRequestTimeout(context.Background(), 50*time.Millisecond)
+
+	start := time.Now()
+
+	_, err := NewClient(10).GetPage(ctx, request)
+	if err == nil {
+		t.Fatal("Expected an error, got none.")
+	}
+
+	if !strings.Contains(err.Message, "timed out") {
+		t.Errorf("Expected error message to contain %q, got %q.", "timed out", err.Message)
+	}
+
+	// The request is aborted at the timeout carried by the context rather
+	// than the configured one.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected request to be aborted within the context timeout, took %v.", elapsed)
+	}
+}
+
+func TestDatasourceGetPagePostSearch(t *testing.T) {
+	var gotBodies []map[string]any
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.RawQuery != "" {
+			t.Errorf("Expected a POST without query parameters, got %s %s.", r.Method, r.URL)
+		}
+
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Expected a JSON body, got error: %v.", err)
+		}
+
+		gotBodies = append(gotBodies, body)
+
+		if body["cursor"] == nil {
+			w.Write([]byte(`{"teams":[{"id":"P1"}],"cursor":"abc"}`))
+
+			return
+		}
+
+		w.Write([]byte(`{"teams":[{"id":"P2"}]}`))
+	})
+
+	client := NewClient(5)
+	request := newTestDatasourceRequest(server)
+	request.HTTPMethod = http.MethodPost
+	request.PaginationMode = PaginationCursor
+	request.RequestBodyTemplate = `{"query":"*","cursor":{{cursor}},"limit":{{pageSize}}}`
+
+	var gotIDs []string
+
+	for page := 0; page < 3; page++ {
+		response, err := client.GetPage(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %+v.", err)
+		}
+
+		for _, object := range response.Objects {
+			gotIDs = append(gotIDs, object["id"].(string))
+		}
+
+		if response.Cursor == "" {
+			break
+		}
+
+		request.Cursor = response.Cursor
+	}
+
+	if wantIDs := []string{"P1", "P2"}; !reflect.DeepEqual(gotIDs, wantIDs) {
+		t.Errorf("Expected objects %v, got %v.", wantIDs, gotIDs)
+	}
+
+	wantBodies := []map[string]any{
+		{"query": "*", "cursor": nil, "limit": float64(10)},
+		{"query": "*", "cursor": "abc", "limit": float64(10)},
+	}
+
+	if !reflect.DeepEqual(gotBodies, wantBodies) {
+		t.Errorf("Expected request bodies %v, got %v.", wantBodies, gotBodies)
+	}
+}
+
+func TestDatasourceGetPageGzipRequestBody(t *testing.T) {
+	tests := map[string]struct {
+		gzipRequestBody bool
+		method          string
+		wantEncoding    string
+		wantBody        string
+	}{
+		"gzip": {
+			gzipRequestBody: true,
+			method:          http.MethodPost,
+			wantEncoding:    "gzip",
+			wantBody:        `{"query":"*","limit":10}`,
+		},
+		"plain": {
+			method:   http.MethodPost,
+			wantBody: `{"query":"*","limit":10}`,
+		},
+		// Requests without a body are sent as is.
+		"gzip_without_body": {
+			gzipRequestBody: true,
+			method:          http.MethodGet,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotEncoding string
+
+			var gotBody []byte
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotEncoding = r.Header.Get("Content-Encoding")
+
+				body := io.Reader(r.Body)
+
+				if gotEncoding == "gzip" {
+					reader, err := gzip.NewReader(r.Body)
+					if err != nil {
+						t.Errorf("Expected a gzip body, got error: %v.", err)
+
+						return
+					}
+
+					body = reader
+				}
+
+				gotBody, _ = io.ReadAll(body)
+
+				w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.HTTPMethod = tt.method
+			request.GzipRequestBody = tt.gzipRequestBody
+
+			if tt.method == http.MethodPost {
+				request.RequestBodyTemplate = `{"query":"*","limit":{{pageSize}}}`
+			}
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotEncoding != tt.wantEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q.", tt.wantEncoding, gotEncoding)
+			}
+
+			if string(gotBody) != tt.wantBody {
+				t.Errorf("Expected request body %q, got %q.", tt.wantBody, gotBody)
+			}
+		})
+	}
+}
+
+func TestNewRequestBody(t *testing.T) {
+	payload := []byte(`{"query":"*"}`)
+
+	tests := map[string]struct {
+		gzipBody     bool
+		wantEncoding string
+	}{
+		"plain": {},
+		"gzip": {
+			gzipBody:     true,
+			wantEncoding: "gzip",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			body, encoding, err := newRequestBody(payload, tt.gzipBody)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if encoding != tt.wantEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q.", tt.wantEncoding, encoding)
+			}
+
+			if encoding == "gzip" {
+				body, err = gzip.NewReader(body)
+				if err != nil {
+					t.Fatalf("Expected a gzip body, got error: %v.", err)
+				}
+			}
+
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Expected no error reading the body, got %v.", err)
+			}
+
+			if !bytes.Equal(got, payload) {
+				t.Errorf("Expected body %q, got %q.", payload, got)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageHTTPMethod(t *testing.T) {
+	tests := map[string]struct {
+		method    string
+		wantQuery string
+		wantBody  string
+	}{
+		"default": {
+			wantQuery: "limit=10&offset=20",
+		},
+		"get": {
+			method:    http.MethodGet,
+			wantQuery: "limit=10&offset=20",
+		},
+		// POST searches send the pagination in a JSON body, with numbers.
+		"post": {
+			method:   http.MethodPost,
+			wantBody: `{"limit":10,"offset":20}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotMethod, gotQuery string
+
+			var gotBody []byte
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotMethod = r.Method
+				gotQuery = r.URL.RawQuery
+				gotBody, _ = io.ReadAll(r.Body)
+
+				w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.HTTPMethod = tt.method
+			request.Cursor = "20"
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			wantMethod := tt.method
+			if wantMethod == "" {
+				wantMethod = http.MethodGet
+			}
+
+			if gotMethod != wantMethod {
+				t.Errorf("Expected method %s, got %s.", wantMethod, gotMethod)
+			}
+
+			if gotQuery != tt.wantQuery {
+				t.Errorf("Expected query %q, got %q.", tt.wantQuery, gotQuery)
+			}
+
+			if string(gotBody) != tt.wantBody {
+				t.Errorf("Expected body %q, got %q.", tt.wantBody, gotBody)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageAuthMode(t *testing.T) {
+	tests := map[string]struct {
+		authMode   string
+		token      string
+		username   string
+		password   string
+		wantHeader string
+	}{
+		"default": {
+			token:      "Token token=testtoken",
+			wantHeader: "Token token=testtoken",
+		},
+		"token": {
+			authMode:   AuthModeToken,
+			token:      "Token token=testtoken",
+			wantHeader: "Token token=testtoken",
+		},
+		"basic": {
+			authMode:   AuthModeBasic,
+			username:   "user",
+			password:   "pass",
+			wantHeader: "Basic dXNlcjpwYXNz",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotHeader string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header.Get("Authorization")
+
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.AuthMode = tt.authMode
+			request.Token = tt.token
+			request.Username = tt.username
+			request.Password = tt.password
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotHeader != tt.wantHeader {
+				t.Errorf("Expected Authorization header %q, got %q.", tt.wantHeader, gotHeader)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageCursorQueryParam(t *testing.T) {
+	tests := map[string]struct {
+		cursorQueryParam string
+		wantParam        string
+	}{
+		"offset": {
+			wantParam: "offset",
+		},
+		"page": {
+			cursorQueryParam: "page",
+			wantParam:        "page",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotQueries []url.Values
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotQueries = append(gotQueries, r.URL.Query())
+
+				if len(gotQueries) == 1 {
+					w.Header().Set(DefaultCursorHeader, "2")
+				}
+
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			client := NewClient(5)
+			request := newTestDatasourceRequest(server)
+			request.PaginationMode = PaginationHeader
+			request.CursorQueryParam = tt.cursorQueryParam
+
+			response, err := client.GetPage(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if response.Cursor != "2" {
+				t.Fatalf("Expected cursor %q from the %s header, got %q.", "2", DefaultCursorHeader, response.Cursor)
+			}
+
+			request.Cursor = response.Cursor
+
+			if _, err := client.GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if got := gotQueries[1].Get(tt.wantParam); got != "2" {
+				t.Errorf("Expected cursor sent as %s=2, got query %v.", tt.wantParam, gotQueries[1])
+			}
+
+			for _, param := range []string{"offset", "page"} {
+				if param != tt.wantParam && gotQueries[1].Has(param) {
+					t.Errorf("Expected no %s query parameter, got query %v.", param, gotQueries[1])
+				}
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageHeaderPaginationOpaqueToken(t *testing.T) {
+	var gotQueries []url.Values
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotQueries = append(gotQueries, r.URL.Query())
+
+		if len(gotQueries) == 1 {
+			w.Header().Set(DefaultCursorHeader, "eyJwYWdlIjoyfQ")
+		}
+
+		fmt.Fprintf(w, `{"teams":[{"id":"P%d"}]}`, len(gotQueries))
+	})
+
+	client := NewClient(5)
+	request := newTestDatasourceRequest(server)
+	request.PaginationMode = PaginationHeader
+
+	var gotIDs []any
+
+	for page := 0; page < 2; page++ {
+		response, err := client.GetPage(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Expected no error for page %d, got %+v.", page, err)
+		}
+
+		for _, object := range response.Objects {
+			gotIDs = append(gotIDs, object["id"])
+		}
+
+		request.Cursor = response.Cursor
+	}
+
+	// The token isn't an offset, but is sent back as returned.
+	if got := gotQueries[1].Get("offset"); got != "eyJwYWdlIjoyfQ" {
+		t.Errorf("Expected the token sent back as returned, got query %v.", gotQueries[1])
+	}
+
+	if want := []any{"P1", "P2"}; !reflect.DeepEqual(gotIDs, want) {
+		t.Errorf("Expected objects %v, got %v.", want, gotIDs)
+	}
+
+	if request.Cursor != "" {
+		t.Errorf("Expected pagination to end after the second page, got cursor %q.", request.Cursor)
+	}
+}
+
+func TestDatasourceGetPageAuthScheme(t *testing.T) {
+	tests := map[string]struct {
+		authScheme string
+		token      string
+		wantHeader string
+	}{
+		"default": {
+			token:      "testtoken",
+			wantHeader: "Token token=testtoken",
+		},
+		"token": {
+			authScheme: AuthSchemeToken,
+			token:      "testtoken",
+			wantHeader: "Token token=testtoken",
+		},
+		"token_already_prefixed": {
+			authScheme: AuthSchemeToken,
+			token:      "Token token=testtoken",
+			wantHeader: "Token token=testtoken",
+		},
+		"bearer": {
+			authScheme: AuthSchemeBearer,
+			token:      "testtoken",
+			wantHeader: "Bearer testtoken",
+		},
+		"bearer_already_prefixed": {
+			authScheme: AuthSchemeBearer,
+			token:      "Bearer testtoken",
+			wantHeader: "Bearer testtoken",
+		},
+		"basic": {
+			authScheme: AuthSchemeBasic,
+			token:      "dXNlcjpwYXNz",
+			wantHeader: "Basic dXNlcjpwYXNz",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotHeader string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header.Get("Authorization")
+
+				w.Write([]byte(`{"teams":[]}`))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.AuthScheme = tt.authScheme
+			request.Token = tt.token
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotHeader != tt.wantHeader {
+				t.Errorf("Expected Authorization header %q, got %q.", tt.wantHeader, gotHeader)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageNextCursorJSONPath(t *testing.T) {
+	tests := map[string]struct {
+		body       string
+		wantCursor string
+	}{
+		"present": {
+			body:       `{"teams":[{"id":"P1"}],"pagination":{"next":"abc"}}`,
+			wantCursor: "abc",
+		},
+		"numeric": {
+			body:       `{"teams":[{"id":"P1"}],"pagination":{"next":12345678901234567890}}`,
+			wantCursor: "12345678901234567890",
+		},
+		"empty": {
+			body: `{"teams":[{"id":"P1"}],"pagination":{"next":""}}`,
+		},
+		"null": {
+			body: `{"teams":[{"id":"P1"}],"pagination":{"next":null}}`,
+		},
+		"absent": {
+			body: `{"teams":[{"id":"P1"}],"pagination":{}}`,
+		},
+		"top_level_cursor_ignored": {
+			body: `{"teams":[{"id":"P1"}],"cursor":"abc"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotCursor string
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				gotCursor = r.URL.Query().Get(DefaultCursorQueryParam)
+
+				w.Write([]byte(tt.body))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.PaginationMode = PaginationCursor
+			request.NextCursorJSONPath = "pagination.next"
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if response.Cursor != tt.wantCursor {
+				t.Fatalf("Expected cursor %q, got %q.", tt.wantCursor, response.Cursor)
+			}
+
+			if tt.wantCursor == "" {
+				return
+			}
+
+			// The cursor is sent back as is to request the next page.
+			request.Cursor = response.Cursor
+
+			if _, err := NewClient(5).GetPage(context.Background(), request); err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if gotCursor != tt.wantCursor {
+				t.Errorf("Expected cursor %q sent as %s, got %q.", tt.wantCursor, DefaultCursorQueryParam, gotCursor)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageAdditionalObjectsKeys(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"team_references":[{"id":"PR1"},{"id":"PR2"}],"teams":[{"id":"PT1"},{"id":"PT2"}]}`))
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.EntityExternalID = Teams
+	request.ResponseObjectsKey = "teams"
+	request.AdditionalObjectsKeys = []string{"team_references", "missing"}
+
+	response, err := NewClient(5).GetPage(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	var gotIDs []string
+	for _, object := range response.Objects {
+		gotIDs = append(gotIDs, object["id"].(string))
+	}
+
+	// The objects of each key are merged in the declared order of the keys.
+	if wantIDs := []string{"PT1", "PT2", "PR1", "PR2"}; !reflect.DeepEqual(gotIDs, wantIDs) {
+		t.Errorf("Expected objects %v, got %v.", wantIDs, gotIDs)
+	}
+}
+
+func TestDatasourceGetPageObjectsKey(t *testing.T) {
+	tests := map[string]struct {
+		body        string
+		wantIDs     []string
+		wantMessage string
+	}{
+		"missing": {
+			body:        `{"users":[{"id":"PU1"}],"more":false}`,
+			wantMessage: "Response body has no teams key holding the objects of entity teams. Found top-level keys: [more, users].",
+		},
+		"present_empty": {
+			body:    `{"teams":[],"more":false}`,
+			wantIDs: []string{},
+		},
+		"present_populated": {
+			body:    `{"teams":[{"id":"P1"},{"id":"P2"}],"more":false}`,
+			wantIDs: []string{"P1", "P2"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(tt.body))
+			})
+
+			response, err := NewClient(5).GetPage(context.Background(), newTestDatasourceRequest(server))
+
+			if tt.wantMessage != "" {
+				if err == nil || err.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL || !strings.Contains(err.Message, tt.wantMessage) {
+					t.Fatalf("Expected internal error %q, got %+v.", tt.wantMessage, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			gotIDs := []string{}
+			for _, object := range response.Objects {
+				gotIDs = append(gotIDs, object["id"].(string))
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantIDs, gotIDs)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageObjectsTypeMismatch(t *testing.T) {
+	tests := map[string]struct {
+		body        string
+		wantMessage string
+	}{
+		"object_instead_of_array": {
+			body:        `{"teams":{"id":"PT1"},"more":false}`,
+			wantMessage: "field teams holds a JSON object, expected an array of objects",
+		},
+		"array_of_ids": {
+			body:        `{"teams":["PT1","PT2"],"more":false}`,
+			wantMessage: "field teams holds an array of string values, expected an array of objects",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(tt.body))
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.EntityExternalID = Teams
+			request.ResponseObjectsKey = "teams"
+
+			_, err := NewClient(5).GetPage(context.Background(), request)
+			if err == nil || err.Code != api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL || !strings.Contains(err.Message, tt.wantMessage) {
+				t.Fatalf("Expected internal error %q, got %+v.", tt.wantMessage, err)
+			}
+		})
+	}
+}
+
+func TestNewClientWithPool(t *testing.T) {
+	tests := map[string]struct {
+		pool                    PoolConfig
+		wantMaxIdleConns        int
+		wantMaxIdleConnsPerHost int
+		wantIdleConnTimeout     time.Duration
+	}{
+		"defaults": {
+			wantMaxIdleConns:        DefaultMaxIdleConns,
+			wantMaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+			wantIdleConnTimeout:     DefaultIdleConnTimeout,
+		},
+		"configured": {
+			pool: PoolConfig{
+				MaxIdleConns:        500,
+				MaxIdleConnsPerHost: 50,
+				IdleConnTimeout:     30 * time.Second,
+			},
+			wantMaxIdleConns:        500,
+			wantMaxIdleConnsPerHost: 50,
+			wantIdleConnTimeout:     30 * time.Second,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewClientWithPool(5, tt.pool).(*Datasource)
+
+			transport, ok := client.Client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Expected an *http.Transport, got %T.", client.Client.Transport)
+			}
+
+			if transport.MaxIdleConns != tt.wantMaxIdleConns {
+				t.Errorf("Expected MaxIdleConns %d, got %d.", tt.wantMaxIdleConns, transport.MaxIdleConns)
+			}
+
+			if transport.MaxIdleConnsPerHost != tt.wantMaxIdleConnsPerHost {
+				t.Errorf("Expected MaxIdleConnsPerHost %d, got %d.", tt.wantMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+			}
+
+			if transport.IdleConnTimeout != tt.wantIdleConnTimeout {
+				t.Errorf("Expected IdleConnTimeout %v, got %v.", tt.wantIdleConnTimeout, transport.IdleConnTimeout)
+			}
+
+			if client.Client.Timeout != 5*time.Second {
+				t.Errorf("Expected a timeout of 5s, got %v.", client.Client.Timeout)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageResumeCursor(t *testing.T) {
+	tests := map[string]struct {
+		statusCode int
+		body       string
+	}{
+		// The page was fetched, but its body can't be unmarshaled.
+		"malformed_body": {
+			statusCode: http.StatusOK,
+			body:       `{"teams":[{"id":"P21"},`,
+		},
+		"error_status": {
+			statusCode: http.StatusInternalServerError,
+			body:       `{"error":{"message":"Internal Server Error"}}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				fmt.Fprint(w, tt.body)
+			})
+
+			request := newTestDatasourceRequest(server)
+			request.Cursor = "20"
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+			if err == nil {
+				t.Fatalf("Expected an error, got response %+v.", response)
+			}
+
+			// The request's cursor is the last one known to be good.
+			if response == nil || response.Cursor != request.Cursor {
+				t.Errorf("Expected a response with cursor %q to resume from, got %+v.", request.Cursor, response)
+			}
+		})
+	}
+}
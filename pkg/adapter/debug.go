@@ -0,0 +1,201 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+)
+
+const (
+	// DefaultDebugDumpMaxFiles is the maximum number of debug dump files
+	// written by a Datasource if none is configured.
+	DefaultDebugDumpMaxFiles = 100
+
+	// DefaultDebugDumpMaxBytes is the maximum size of each body written to a
+	// debug dump file if none is configured. Longer bodies are truncated.
+	DefaultDebugDumpMaxBytes = 1 << 20
+
+	// redactedValue replaces the secrets in redacted strings.
+	redactedValue = "[REDACTED]"
+
+	// maxErrorBodySnippetBytes is the maximum size of the start of an error
+	// response body included in error messages.
+	maxErrorBodySnippetBytes = 512
+)
+
+// unsafeFileNameChars matches the characters replaced in debug dump file names.
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// debugExchange is a request to the datasource and its response, dumped for
+// debugging.
+type debugExchange struct {
+	entityExternalID string
+	method           string
+	url              string
+	requestBody      []byte
+	statusCode       int
+	responseBody     []byte
+}
+
+// redact returns the given string with all occurrences of the given secrets
+// replaced, e.g. to avoid leaking credentials in logs and debug dumps.
+func redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redactedValue)
+		}
+	}
+
+	return s
+}
+
+// errorBodySnippet returns the start of the given error response body, with
+// the given secrets redacted and whitespace collapsed to fit in an error
+// message.
+func errorBodySnippet(body io.Reader, secrets ...string) string {
+	data, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySnippetBytes+1))
+
+	truncated := len(data) > maxErrorBodySnippetBytes
+	if truncated {
+		data = data[:maxErrorBodySnippetBytes]
+	}
+
+	snippet := strings.Join(strings.Fields(redact(string(data), secrets...)), " ")
+	if truncated {
+		snippet += "..."
+	}
+
+	return snippet
+}
+
+// redactError returns a copy of the given error with the given secrets
+// redacted from its message, or nil if the error is nil.
+func redactError(err *framework.Error, secrets ...string) *framework.Error {
+	if err == nil {
+		return nil
+	}
+
+	redacted := *err
+	redacted.Message = redact(err.Message, secrets...)
+
+	return &redacted
+}
+
+// requestSecrets returns the credentials of the given GetPage request that
+// must never appear in logs or error messages: the Authorization header value
+// and the token it carries, e.g. "abc" in "Token token=abc", the basic auth
+// password, the OAuth2 client secret, and the values of the extra headers.
+func requestSecrets(request *framework.Request[Config]) []string {
+	var authorizations, secrets []string
+
+	if request.Auth != nil {
+		authorizations = append(authorizations, request.Auth.HTTPAuthorization)
+
+		if request.Auth.Basic != nil {
+			secrets = append(secrets, request.Auth.Basic.Password)
+		}
+	}
+
+	if request.Config != nil {
+		authorizations = append(authorizations, request.Config.AuthToken)
+		secrets = append(secrets, request.Config.ClientSecret)
+
+		for _, value := range request.Config.ExtraHeaders {
+			secrets = append(secrets, value)
+		}
+	}
+
+	for _, authorization := range authorizations {
+		secrets = append(secrets, authorizationSecrets(authorization)...)
+	}
+
+	return secrets
+}
+
+// authorizationSecrets returns the given Authorization header value and the
+// token it carries, e.g. "abc" in "Token token=abc" or "Bearer abc".
+func authorizationSecrets(authorization string) []string {
+	secrets := []string{authorization}
+
+	if _, credentials, found := strings.Cut(authorization, " "); found {
+		_, token, _ := strings.Cut(credentials, "token=")
+		secrets = append(secrets, credentials, token)
+	}
+
+	return secrets
+}
+
+// dumpExchange writes the given exchange with the datasource to a new file in
+// the request's debug dump directory, with the given secrets redacted.
+//
+// At most the configured maximum number of files are written by the
+// Datasource, and bodies are truncated to the configured maximum size, so that
+// debug dumps cannot fill the disk.
+func (d *Datasource) dumpExchange(request *Request, exchange debugExchange, secrets ...string) error {
+	maxFiles := int64(request.DebugDumpMaxFiles)
+	if maxFiles <= 0 {
+		maxFiles = DefaultDebugDumpMaxFiles
+	}
+
+	maxBytes := request.DebugDumpMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultDebugDumpMaxBytes
+	}
+
+	index := d.debugDumps.Add(1)
+	if index > maxFiles {
+		return nil
+	}
+
+	// Bodies are redacted before they are truncated, which could otherwise cut
+	// a secret and leave its start unredacted.
+	requestBody := truncate([]byte(redact(string(exchange.requestBody), secrets...)), maxBytes)
+	responseBody := truncate([]byte(redact(string(exchange.responseBody), secrets...)), maxBytes)
+
+	var dump strings.Builder
+
+	fmt.Fprintf(&dump, "%s %s\n\n%s\n\n", exchange.method, exchange.url, requestBody)
+	fmt.Fprintf(&dump, "%d %s\n\n%s\n", exchange.statusCode, http.StatusText(exchange.statusCode), responseBody)
+
+	name := fmt.Sprintf("%s-%s-%d.txt",
+		time.Now().UTC().Format("20060102T150405.000000000Z"),
+		unsafeFileNameChars.ReplaceAllString(exchange.entityExternalID, "_"),
+		index,
+	)
+
+	if err := os.MkdirAll(request.DebugDumpDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create debug dump directory: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(request.DebugDumpDir, name), []byte(redact(dump.String(), secrets...)), 0o600)
+}
+
+// truncate returns the given body as a string truncated to maxBytes.
+func truncate(body []byte, maxBytes int) string {
+	if len(body) <= maxBytes {
+		return string(body)
+	}
+
+	return fmt.Sprintf("%s\n[truncated %d bytes]", body[:maxBytes], len(body)-maxBytes)
+}
@@ -0,0 +1,226 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+func TestRedactError(t *testing.T) {
+	request := newTestRequest(func(r *framework.Request[Config]) {
+		r.Auth.HTTPAuthorization = "Token token=secret-token"
+	})
+
+	tests := map[string]struct {
+		message string
+		want    string
+	}{
+		"authorization": {
+			message: "Request with Token token=secret-token failed.",
+			want:    "Request with [REDACTED] failed.",
+		},
+		"token": {
+			message: `Invalid token "secret-token".`,
+			want:    `Invalid token "[REDACTED]".`,
+		},
+		"no_secret": {
+			message: "Datasource is unavailable.",
+			want:    "Datasource is unavailable.",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := &framework.Error{Message: tt.message, Code: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL}
+
+			got := redactError(err, requestSecrets(request)...)
+
+			if got.Message != tt.want {
+				t.Errorf("Expected message %q, got %q.", tt.want, got.Message)
+			}
+
+			if got.Code != err.Code {
+				t.Errorf("Expected code %v, got %v.", err.Code, got.Code)
+			}
+
+			// The given error is left untouched.
+			if err.Message != tt.message {
+				t.Errorf("Expected the given error not to be redacted, got %q.", err.Message)
+			}
+		})
+	}
+
+	if got := redactError(nil, "secret-token"); got != nil {
+		t.Errorf("Expected nil, got %+v.", got)
+	}
+}
+
+func TestDatasourceGetPageRedactsToken(t *testing.T) {
+	// The datasource echoes the token it received.
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, token, _ := strings.Cut(r.Header.Get("Authorization"), "token=")
+
+		if r.URL.Query().Get("offset") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+		fmt.Fprintf(w, `{"teams":[],"error":"invalid token %s"}`, token)
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.DebugDumpDir = t.TempDir()
+
+	client := NewClient(5)
+
+	if _, err := client.GetPage(context.Background(), request); err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	request.Cursor = "10"
+
+	_, err := client.GetPage(context.Background(), request)
+	if err == nil {
+		t.Fatal("Expected an error, got none.")
+	}
+
+	if !strings.Contains(err.Message, "invalid token "+redactedValue) || strings.Contains(err.Message, "testtoken") {
+		t.Errorf("Expected the token to be redacted from the error message, got %q.", err.Message)
+	}
+
+	dumps, _ := filepath.Glob(filepath.Join(request.DebugDumpDir, "*"))
+	if len(dumps) != 1 {
+		t.Fatalf("Expected 1 debug dump, got %v.", dumps)
+	}
+
+	data, readErr := os.ReadFile(dumps[0])
+	if readErr != nil {
+		t.Fatalf("Failed to read debug dump: %v.", readErr)
+	}
+
+	if !strings.Contains(string(data), "invalid token "+redactedValue) || strings.Contains(string(data), "testtoken") {
+		t.Errorf("Expected the token to be redacted from the debug dump, got %q.", data)
+	}
+}
+
+func TestDatasourceGetPageErrorBodySnippet(t *testing.T) {
+	longMessage := strings.Repeat("a", maxErrorBodySnippetBytes)
+
+	tests := map[string]struct {
+		body        string
+		wantMessage string
+	}{
+		"snippet": {
+			body:        `{"error":{"message":"Invalid Input Provided","code":2001}}`,
+			wantMessage: `Response status: 422, body: {"error":{"message":"Invalid Input Provided","code":2001}}.`,
+		},
+		"whitespace_collapsed": {
+			body:        "{\n  \"error\": {\n    \"message\": \"Invalid Input Provided\"\n  }\n}",
+			wantMessage: `Response status: 422, body: { "error": { "message": "Invalid Input Provided" } }.`,
+		},
+		"truncated": {
+			body:        `{"error":"` + longMessage + `"}`,
+			wantMessage: `Response status: 422, body: {"error":"` + longMessage[:maxErrorBodySnippetBytes-len(`{"error":"`)] + `....`,
+		},
+		"redacted": {
+			body:        `{"error":"Invalid token testtoken"}`,
+			wantMessage: `Response status: 422, body: {"error":"Invalid token [REDACTED]"}.`,
+		},
+		"empty": {
+			body:        "",
+			wantMessage: "Response status: 422.",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := NewClient(5).GetPage(context.Background(), newTestDatasourceRequest(server))
+			if err == nil {
+				t.Fatal("Expected an error, got none.")
+			}
+
+			if !strings.HasSuffix(err.Message, tt.wantMessage) {
+				t.Errorf("Expected error message ending with %q, got %q.", tt.wantMessage, err.Message)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageDebugDump(t *testing.T) {
+	const maxBytes = 64
+
+	// The token is echoed across the size limit of dumped bodies.
+	body := fmt.Sprintf(`{"teams":[{"id":"P1"}],"note":"%s","auth":"testtoken"}`, strings.Repeat("x", 19))
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(body))
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.DebugDumpDir = filepath.Join(t.TempDir(), "dumps")
+	request.DebugDumpMaxFiles = 2
+	request.DebugDumpMaxBytes = maxBytes
+
+	client := NewClient(5)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetPage(context.Background(), request); err != nil {
+			t.Fatalf("Expected no error, got %+v.", err)
+		}
+	}
+
+	dumps, err := os.ReadDir(request.DebugDumpDir)
+	if err != nil {
+		t.Fatalf("Failed to read debug dump directory: %v.", err)
+	}
+
+	// Only the first exchanges are dumped.
+	if len(dumps) != request.DebugDumpMaxFiles {
+		t.Fatalf("Expected %d debug dumps, got %d.", request.DebugDumpMaxFiles, len(dumps))
+	}
+
+	for _, dump := range dumps {
+		if !strings.HasSuffix(dump.Name(), "-teams-1.txt") && !strings.HasSuffix(dump.Name(), "-teams-2.txt") {
+			t.Errorf("Expected a dump named after the entity and its index, got %q.", dump.Name())
+		}
+
+		data, err := os.ReadFile(filepath.Join(request.DebugDumpDir, dump.Name()))
+		if err != nil {
+			t.Fatalf("Failed to read debug dump: %v.", err)
+		}
+
+		redacted := strings.Replace(body, "testtoken", redactedValue, 1)
+
+		want := fmt.Sprintf("GET %s/teams?limit=10\n\n\n\n200 OK\n\n%s\n[truncated %d bytes]\n",
+			server.URL, redacted[:maxBytes], len(redacted)-maxBytes)
+
+		if string(data) != want {
+			t.Errorf("Expected debug dump %q, got %q.", want, data)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// digestAlgorithms maps the names of the algorithms supported in Digest
+// response headers, cf. RFC 3230, to their hash functions.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"md5":     md5.New,
+}
+
+// verifyResponseDigest verifies that the given response body matches the
+// digests sent by the datasource in the Digest or Content-MD5 response headers.
+//
+// The body must be decompressed, i.e. the digests are expected to be computed
+// over the representation of the objects rather than over the encoded content.
+// An error is returned if no digest with a supported algorithm is present.
+func verifyResponseDigest(header http.Header, body []byte) error {
+	verified := false
+
+	for _, value := range header.Values("Digest") {
+		for _, digest := range strings.Split(value, ",") {
+			algorithm, expected, found := strings.Cut(strings.TrimSpace(digest), "=")
+			if !found {
+				return fmt.Errorf("malformed Digest header: %s", digest)
+			}
+
+			newHash, supported := digestAlgorithms[strings.ToLower(algorithm)]
+			if !supported {
+				continue
+			}
+
+			if err := verifyDigest(newHash, body, expected); err != nil {
+				return fmt.Errorf("%s digest: %w", algorithm, err)
+			}
+
+			verified = true
+		}
+	}
+
+	if expected := header.Get("Content-MD5"); expected != "" {
+		if err := verifyDigest(md5.New, body, expected); err != nil {
+			return fmt.Errorf("Content-MD5: %w", err)
+		}
+
+		verified = true
+	}
+
+	if !verified {
+		return errors.New("response contains no Digest or Content-MD5 header with a supported algorithm")
+	}
+
+	return nil
+}
+
+// verifyDigest verifies that the base64-encoded expected digest matches the
+// digest of the given body computed with the given hash function.
+func verifyDigest(newHash func() hash.Hash, body []byte, expected string) error {
+	// The padding of base64-encoded digests is optional in practice.
+	decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(expected, "="))
+	if err != nil {
+		return fmt.Errorf("malformed digest: %s", expected)
+	}
+
+	h := newHash()
+	h.Write(body)
+
+	actual := h.Sum(nil)
+
+	if string(actual) != string(decoded) {
+		return fmt.Errorf("mismatch, expected %s but computed %s",
+			expected, base64.StdEncoding.EncodeToString(actual))
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVerifyResponseDigest(t *testing.T) {
+	body := []byte(`{"teams":[{"id":"P1"}]}`)
+
+	sha256Sum := sha256.Sum256(body)
+	sha512Sum := sha512.Sum512(body)
+	md5Sum := md5.Sum(body)
+
+	sha256Digest := base64.StdEncoding.EncodeToString(sha256Sum[:])
+	sha512Digest := base64.StdEncoding.EncodeToString(sha512Sum[:])
+	md5Digest := base64.StdEncoding.EncodeToString(md5Sum[:])
+
+	tests := map[string]struct {
+		header      http.Header
+		wantErrText string
+	}{
+		"sha_256": {
+			header: http.Header{"Digest": {"sha-256=" + sha256Digest}},
+		},
+		"sha_512_uppercase": {
+			header: http.Header{"Digest": {"SHA-512=" + sha512Digest}},
+		},
+		"unpadded": {
+			header: http.Header{"Digest": {"sha-256=" + strings.TrimRight(sha256Digest, "=")}},
+		},
+		"several_digests": {
+			header: http.Header{"Digest": {"unixsum=123, sha-256=" + sha256Digest + ", md5=" + md5Digest}},
+		},
+		"content_md5": {
+			header: http.Header{"Content-Md5": {md5Digest}},
+		},
+		"sha_256_mismatch": {
+			header:      http.Header{"Digest": {"sha-256=" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))}},
+			wantErrText: "sha-256 digest: mismatch",
+		},
+		"content_md5_mismatch": {
+			header: http.Header{
+				"Digest":      {"sha-256=" + sha256Digest},
+				"Content-Md5": {base64.StdEncoding.EncodeToString(make([]byte, md5.Size))},
+			},
+			wantErrText: "Content-MD5: mismatch",
+		},
+		"malformed_digest": {
+			header:      http.Header{"Digest": {"sha-256=not base64!"}},
+			wantErrText: "malformed digest",
+		},
+		"malformed_header": {
+			header:      http.Header{"Digest": {"sha-256"}},
+			wantErrText: "malformed Digest header",
+		},
+		"unsupported_algorithm": {
+			header:      http.Header{"Digest": {"unixsum=123"}},
+			wantErrText: "no Digest or Content-MD5 header",
+		},
+		"missing": {
+			header:      http.Header{},
+			wantErrText: "no Digest or Content-MD5 header",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := verifyResponseDigest(tt.header, body)
+
+			if tt.wantErrText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Errorf("Expected error containing %q, got %v.", tt.wantErrText, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Expected no error, got %v.", err)
+			}
+		})
+	}
+}
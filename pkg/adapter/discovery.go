@@ -0,0 +1,250 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+// This file contains tooling helpers to onboard new datasources.
+// They are not used to query pages of objects.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+)
+
+var (
+	// nextLinkPattern matches a `Link` header value with the "next" relation.
+	nextLinkPattern = regexp.MustCompile(`(?i)<[^>]*>\s*;[^,]*\brel="?[^",]*\bnext\b`)
+
+	// cursorFields are the usual names of response body fields holding the
+	// cursor of the next page.
+	cursorFields = []string{
+		"next_cursor", "nextCursor", "cursor", "next_token", "nextToken",
+		"next_page_token", "nextPageToken", "continuation", "continuationToken",
+	}
+
+	// pageNumberFields are the usual names of response body fields indicating
+	// pagination by page number, besides a `page` field, which is not
+	// supported.
+	pageNumberFields = []string{"total_pages", "totalPages", "page_count", "pageCount", "per_page", "perPage"}
+
+	// paginationContainers are the usual names of response body objects
+	// grouping the pagination fields.
+	paginationContainers = []string{"pagination", "paging", "meta", "page_info", "pageInfo"}
+)
+
+// ErrPageNumberPagination is returned by DetectPagination and ProbePagination
+// if the response is likely paginated by page number, e.g. with `page` and
+// `total_pages` fields. Such responses are detected, but pagination by page
+// number is not supported, so there is no PaginationType to suggest. Other
+// errors mean that the probe failed or that no pagination style was detected.
+var ErrPageNumberPagination = errors.New("probe response is likely paginated by page number, which is not supported")
+
+// DiscoverObjectsPath returns the likely path of the list of objects in the
+// given sample response body from a datasource, i.e. the path of its largest
+// array of objects, to be used as Config.ObjectsJSONPath.
+//
+// Nested objects are searched too. Among arrays of the same length, the least
+// nested one is preferred. The suggestion is also logged to the given logger,
+// if set.
+func DiscoverObjectsPath(sample []byte, logger Logger) (string, error) {
+	var document any
+	if err := json.Unmarshal(sample, &document); err != nil {
+		return "", fmt.Errorf("failed to unmarshal sample response: %w", err)
+	}
+
+	candidates := findObjectArrays(document, "", 0, nil)
+	if len(candidates) == 0 {
+		return "", errors.New("sample response contains no array of objects")
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].length != candidates[j].length {
+			return candidates[i].length > candidates[j].length
+		}
+
+		return candidates[i].depth < candidates[j].depth
+	})
+
+	best := candidates[0]
+
+	loggerOrNoop(logger).Info("Discovered the likely path of the objects, set objectsJsonPath accordingly.",
+		"objectsJsonPath", best.path, "length", best.length)
+
+	return best.path, nil
+}
+
+// objectArray is an array of objects found in a sample response.
+type objectArray struct {
+	path   string
+	length int
+	depth  int
+}
+
+// findObjectArrays appends the arrays of objects found in the given value,
+// located at the given path, to found.
+// Arrays nested in arrays are not searched since they can't be extracted.
+func findObjectArrays(value any, path string, depth int, found []objectArray) []objectArray {
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		// Sort the keys so that ties are broken deterministically.
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			found = findObjectArrays(value[key], keyPath, depth+1, found)
+		}
+	case []any:
+		if path == "" || len(value) == 0 {
+			return found
+		}
+
+		for _, element := range value {
+			if _, ok := element.(map[string]any); !ok {
+				return found
+			}
+		}
+
+		found = append(found, objectArray{path: path, length: len(value), depth: depth})
+	}
+
+	return found
+}
+
+// ProbePagination sends the given request to a datasource and returns the
+// likely pagination style of its response, as detected by DetectPagination.
+// The suggestion is also logged to the given logger, if set, without the
+// request's credentials. Pagination by page number is not supported, so
+// ErrPageNumberPagination is returned and nothing is logged for it.
+func ProbePagination(
+	ctx context.Context, client *http.Client, req *http.Request, logger Logger,
+) (PaginationType, error) {
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to send probe request: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read probe response: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", fmt.Errorf("probe request failed with status code %d", res.StatusCode)
+	}
+
+	paginationType, err := DetectPagination(res.Header, body)
+	if err != nil {
+		return "", err
+	}
+
+	// The query may hold credentials, e.g. an API key.
+	loggerOrNoop(logger).Info("Detected the likely pagination style of the datasource.",
+		"host", req.URL.Host, "path", req.URL.Path, "paginationMode", paginationType)
+
+	return paginationType, nil
+}
+
+// DetectPagination returns the likely pagination style of a datasource from
+// the headers and body of one of its responses:
+//   - PaginationLinkHeader if a `Link` header has a "next" relation,
+//   - PaginationHeader if an X-Next-Page header is returned,
+//   - PaginationAtlassian if the body has `startAt` and `maxResults` fields,
+//   - PaginationBookmark if the body has a `bookmark` field,
+//   - PaginationCursor if the body has a next cursor field, e.g. `next_cursor`,
+//   - PaginationOffset if the body has an `offset` or `limit` field.
+//
+// If the body has a `page` field and a page count or size field, e.g.
+// `total_pages`, the response is paginated by page number. This is detected but
+// not supported by any PaginationType, so no pagination style is suggested: an
+// empty PaginationType and ErrPageNumberPagination are returned.
+//
+// Body fields are searched at the top level and in usual pagination objects,
+// e.g. `pagination` or `meta`.
+func DetectPagination(header http.Header, body []byte) (PaginationType, error) {
+	for _, link := range header.Values("Link") {
+		if nextLinkPattern.MatchString(link) {
+			return PaginationLinkHeader, nil
+		}
+	}
+
+	if header.Get("X-Next-Page") != "" {
+		return PaginationHeader, nil
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(body, &document); err != nil {
+		return "", fmt.Errorf("failed to unmarshal probe response body as an object: %w", err)
+	}
+
+	fields := make(map[string]bool, len(document))
+
+	for name, value := range document {
+		fields[name] = true
+
+		for _, container := range paginationContainers {
+			if name != container {
+				continue
+			}
+
+			var nested map[string]json.RawMessage
+			if err := json.Unmarshal(value, &nested); err == nil {
+				for nestedName := range nested {
+					fields[nestedName] = true
+				}
+			}
+		}
+	}
+
+	hasAny := func(names ...string) bool {
+		for _, name := range names {
+			if fields[name] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch {
+	case hasAny("startAt") && hasAny("maxResults"):
+		return PaginationAtlassian, nil
+	case hasAny(DefaultBookmarkField):
+		return PaginationBookmark, nil
+	case hasAny(cursorFields...):
+		return PaginationCursor, nil
+	case hasAny("page") && hasAny(pageNumberFields...):
+		return "", ErrPageNumberPagination
+	case hasAny("offset", "limit"):
+		return PaginationOffset, nil
+	default:
+		return "", errors.New("no known pagination style detected in probe response")
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProbePaginationLogging(t *testing.T) {
+	const token = "secret-token"
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Next-Page", "2")
+		w.Write([]byte(`{"users":[]}`))
+	})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/users?api_key="+token, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v.", err)
+	}
+
+	req.Header.Set("Authorization", "Token token="+token)
+
+	var logs bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	got, err := ProbePagination(context.Background(), server.Client(), req, logger)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v.", err)
+	}
+
+	if got != PaginationHeader {
+		t.Errorf("Expected %q pagination, got %q.", PaginationHeader, got)
+	}
+
+	if !strings.Contains(logs.String(), "paginationMode="+string(PaginationHeader)) {
+		t.Errorf("Expected the detected pagination to be logged, got %q.", logs.String())
+	}
+
+	if strings.Contains(logs.String(), token) {
+		t.Errorf("Expected the token not to be logged, got %q.", logs.String())
+	}
+}
+
+func TestDetectPagination(t *testing.T) {
+	tests := map[string]struct {
+		header      http.Header
+		body        string
+		want        PaginationType
+		wantErr     error
+		wantMessage string
+	}{
+		"link_header": {
+			header: http.Header{"Link": {`<https://api.example.com/users?page=2>; rel="next"`}},
+			body:   `{"users":[]}`,
+			want:   PaginationLinkHeader,
+		},
+		"link_header_with_several_relations": {
+			header: http.Header{"Link": {`<https://api.example.com/users?page=1>; rel="prev", <https://api.example.com/users?page=3>; rel="last next"`}},
+			body:   `{"users":[]}`,
+			want:   PaginationLinkHeader,
+		},
+		"link_header_without_next": {
+			header: http.Header{"Link": {`<https://api.example.com/users?page=1>; rel="prev"`}},
+			body:   `{"users":[],"offset":0,"limit":10}`,
+			want:   PaginationOffset,
+		},
+		"next_page_header": {
+			header: http.Header{"X-Next-Page": {"10"}},
+			body:   `{"users":[]}`,
+			want:   PaginationHeader,
+		},
+		"atlassian": {
+			body: `{"startAt":0,"maxResults":50,"total":120,"values":[]}`,
+			want: PaginationAtlassian,
+		},
+		"bookmark": {
+			body: `{"bookmark":"g1AAAA","docs":[]}`,
+			want: PaginationBookmark,
+		},
+		"cursor": {
+			body: `{"users":[],"next_cursor":"abc"}`,
+			want: PaginationCursor,
+		},
+		"nested_cursor": {
+			body: `{"data":[],"meta":{"nextCursor":"abc"}}`,
+			want: PaginationCursor,
+		},
+		"offset": {
+			body: `{"users":[],"offset":0,"limit":25,"more":true}`,
+			want: PaginationOffset,
+		},
+		"nested_offset": {
+			body: `{"data":[],"pagination":{"offset":0,"limit":25}}`,
+			want: PaginationOffset,
+		},
+		"page_number": {
+			body:        `{"users":[],"page":1,"total_pages":5}`,
+			wantErr:     ErrPageNumberPagination,
+			wantMessage: "page number, which is not supported",
+		},
+		"unknown": {
+			body:        `{"users":[]}`,
+			wantMessage: "no known pagination style",
+		},
+		"not_an_object": {
+			body:        `[]`,
+			wantMessage: "failed to unmarshal probe response body",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := DetectPagination(tt.header, []byte(tt.body))
+
+			if tt.wantMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMessage) {
+					t.Fatalf("Expected error %q, got %v.", tt.wantMessage, err)
+				}
+
+				// Only page number pagination is reported with the sentinel error.
+				if errors.Is(err, ErrPageNumberPagination) != (tt.wantErr == ErrPageNumberPagination) {
+					t.Errorf("Expected error %v, got %v.", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected %q pagination, got %q.", tt.want, got)
+			}
+		})
+	}
+}
+
+// Pagination by page number is detected, but no pagination style is suggested
+// since none supports it.
+func TestDetectPaginationPageNumber(t *testing.T) {
+	tests := map[string]string{
+		"top_level":   `{"users":[],"page":1,"total_pages":5}`,
+		"page_size":   `{"users":[],"page":1,"per_page":25}`,
+		"nested_page": `{"data":[],"meta":{"page":2,"pageCount":5}}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := DetectPagination(http.Header{}, []byte(body))
+			if !errors.Is(err, ErrPageNumberPagination) {
+				t.Errorf("Expected error %v, got %v.", ErrPageNumberPagination, err)
+			}
+
+			if got != "" {
+				t.Errorf("Expected no suggested pagination, got %q.", got)
+			}
+		})
+	}
+
+	t.Run("probe", func(t *testing.T) {
+		server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(tests["top_level"]))
+		})
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/users", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v.", err)
+		}
+
+		var logs bytes.Buffer
+
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+		got, err := ProbePagination(context.Background(), server.Client(), req, logger)
+		if !errors.Is(err, ErrPageNumberPagination) {
+			t.Errorf("Expected error %v, got %v.", ErrPageNumberPagination, err)
+		}
+
+		if got != "" {
+			t.Errorf("Expected no suggested pagination, got %q.", got)
+		}
+
+		if strings.Contains(logs.String(), "paginationMode") {
+			t.Errorf("Expected no pagination to be suggested in the logs, got %q.", logs.String())
+		}
+	})
+}
+
+func TestDiscoverObjectsPath(t *testing.T) {
+	tests := map[string]struct {
+		sample      string
+		want        string
+		wantMessage string
+	}{
+		"largest_array": {
+			sample: `{"teams":[{"id":"P1"},{"id":"P2"}],"teams":[{"id":"PT1"}],"tags":["a","b","c"]}`,
+			want:   "teams",
+		},
+		"nested_array": {
+			sample: `{"data":{"items":[{"id":"P1"},{"id":"P2"}]},"links":[{"rel":"self"}]}`,
+			want:   "data.items",
+		},
+		// Among arrays of the same length, the least nested one is preferred,
+		// then the first by name.
+		"ambiguous_depth": {
+			sample: `{"result":{"teams":[{"id":"P1"}]},"teams":[{"id":"P1"}]}`,
+			want:   "teams",
+		},
+		"ambiguous_name": {
+			sample: `{"teams":[{"id":"P1"}],"external_users":[{"id":"P2"}]}`,
+			want:   "external_users",
+		},
+		"no_array_of_objects": {
+			sample:      `{"teams":[],"tags":["a"],"total":0}`,
+			wantMessage: "sample response contains no array of objects",
+		},
+		"not_json": {
+			sample:      `<html></html>`,
+			wantMessage: "failed to unmarshal sample response",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The sample is fetched from the datasource, as when onboarding it.
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(tt.sample))
+			})
+
+			res, err := server.Client().Get(server.URL + "/teams")
+			if err != nil {
+				t.Fatalf("Failed to fetch sample: %v.", err)
+			}
+			defer res.Body.Close()
+
+			sample, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("Failed to read sample: %v.", err)
+			}
+
+			var logs bytes.Buffer
+
+			got, err := DiscoverObjectsPath(sample, slog.New(slog.NewTextHandler(&logs, nil)))
+
+			if tt.wantMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMessage) {
+					t.Fatalf("Expected error %q, got %v.", tt.wantMessage, err)
+				}
+
+				if logs.Len() != 0 {
+					t.Errorf("Expected no suggestion to be logged, got %q.", logs.String())
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected path %q, got %q.", tt.want, got)
+			}
+
+			if !strings.Contains(logs.String(), "objectsJsonPath="+tt.want) {
+				t.Errorf("Expected the suggestion to be logged, got %q.", logs.String())
+			}
+		})
+	}
+}
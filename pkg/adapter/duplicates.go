@@ -0,0 +1,102 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// DuplicateKeysWarn logs a warning when a response body contains
+	// duplicate JSON keys.
+	DuplicateKeysWarn = "warn"
+
+	// DuplicateKeysError fails the request when a response body contains
+	// duplicate JSON keys.
+	DuplicateKeysError = "error"
+)
+
+// findDuplicateKey returns the JSONPath of the first key that is duplicated
+// within the same object in the given JSON document, or an empty string if no
+// key is duplicated.
+//
+// The document is scanned with a streaming tokenizer since Go's JSON decoder
+// silently keeps the last value of duplicate keys.
+func findDuplicateKey(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return findDuplicateKeyInValue(decoder, "$")
+}
+
+// findDuplicateKeyInValue scans the next JSON value read from the decoder,
+// located at the given path, for duplicate keys.
+func findDuplicateKeyInValue(decoder *json.Decoder, path string) (string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return "", err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return "", nil
+	}
+
+	switch delim {
+	case '{':
+		keys := make(map[string]struct{})
+
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return "", err
+			}
+
+			key, ok := keyToken.(string)
+			if !ok {
+				return "", fmt.Errorf("unexpected token %v at %s", keyToken, path)
+			}
+
+			keyPath := path + "." + key
+
+			if _, found := keys[key]; found {
+				return keyPath, nil
+			}
+
+			keys[key] = struct{}{}
+
+			if duplicate, err := findDuplicateKeyInValue(decoder, keyPath); err != nil || duplicate != "" {
+				return duplicate, err
+			}
+		}
+	case '[':
+		for i := 0; decoder.More(); i++ {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+
+			if duplicate, err := findDuplicateKeyInValue(decoder, elementPath); err != nil || duplicate != "" {
+				return duplicate, err
+			}
+		}
+	}
+
+	// Consume the closing delimiter.
+	if _, err := decoder.Token(); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"testing"
+)
+
+func TestFindDuplicateKey(t *testing.T) {
+	tests := map[string]struct {
+		body    string
+		want    string
+		wantErr bool
+	}{
+		"no_duplicates": {
+			body: `{"users":[{"id":"P1","name":"a"},{"id":"P2","name":"b"}],"more":false}`,
+		},
+		"top_level": {
+			body: `{"users":[],"more":false,"more":true}`,
+			want: "$.more",
+		},
+		"nested_object": {
+			body: `{"users":[{"id":"P1"},{"id":"P2","contact":{"email":"a","email":"b"}}]}`,
+			want: "$.users[1].contact.email",
+		},
+		"same_key_in_sibling_objects": {
+			body: `{"users":[{"id":"P1"},{"id":"P2"}],"teams":[{"id":"T1"}]}`,
+		},
+		"first_duplicate": {
+			body: `{"users":[{"id":"P1","id":"P2"}],"more":false,"more":true}`,
+			want: "$.users[0].id",
+		},
+		"scalar": {
+			body: `42`,
+		},
+		"malformed": {
+			body:    `{"users":[`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := findDuplicateKey([]byte(tt.body))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got duplicate %q.", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected duplicate key %q, got %q.", tt.want, got)
+			}
+		})
+	}
+}
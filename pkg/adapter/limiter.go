@@ -0,0 +1,65 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+)
+
+// Limiter bounds the number of pages requested concurrently from the
+// datasource. A Limiter may be shared by several adapters in the same process
+// via WithLimiter, so that they coordinate a single global concurrency budget
+// towards a datasource.
+type Limiter struct {
+	// slots holds a value for each page request in progress. Nil if the
+	// Limiter doesn't bound concurrency.
+	slots chan struct{}
+}
+
+// NewLimiter returns a Limiter allowing at most max concurrent page requests.
+// If max is not positive, the Limiter doesn't bound concurrency.
+func NewLimiter(max int) *Limiter {
+	if max <= 0 {
+		return &Limiter{}
+	}
+
+	return &Limiter{
+		slots: make(chan struct{}, max),
+	}
+}
+
+// Acquire blocks until a page request may be sent, or the context is done.
+// Every successful call must be followed by a call to Release.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if l.slots == nil {
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release marks a page request acquired with Acquire as done.
+func (l *Limiter) Release() {
+	if l.slots == nil {
+		return
+	}
+
+	<-l.slots
+}
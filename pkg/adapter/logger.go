@@ -0,0 +1,78 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import "net/url"
+
+// Logger logs leveled messages with structured context given as alternating
+// keys and values, e.g. a *slog.Logger.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// noopLogger is a Logger that discards all messages.
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...any) {}
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
+
+// loggerOrNoop returns the given logger, or a Logger discarding all messages
+// if nil.
+func loggerOrNoop(logger Logger) Logger {
+	if logger == nil {
+		return noopLogger{}
+	}
+
+	return logger
+}
+
+// redactedConfig returns a copy of the given config safe to be logged, with
+// its secrets redacted.
+func redactedConfig(config *Config) *Config {
+	if config == nil {
+		return nil
+	}
+
+	redacted := *config
+
+	if redacted.ClientSecret != "" {
+		redacted.ClientSecret = redactedValue
+	}
+
+	if redacted.AuthToken != "" {
+		redacted.AuthToken = redactedValue
+	}
+
+	// The proxy URL may hold the proxy's credentials.
+	if proxyURL, err := url.Parse(config.ProxyURL); err == nil && proxyURL.User != nil {
+		redacted.ProxyURL = proxyURL.Redacted()
+	}
+
+	// Extra headers commonly hold API keys.
+	if len(redacted.ExtraHeaders) > 0 {
+		redacted.ExtraHeaders = make(map[string]string, len(config.ExtraHeaders))
+
+		for name := range config.ExtraHeaders {
+			redacted.ExtraHeaders[name] = redactedValue
+		}
+	}
+
+	return &redacted
+}
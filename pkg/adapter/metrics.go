@@ -0,0 +1,70 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"time"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+)
+
+// MetricsRecorder records metrics of the pages requested from the datasource,
+// e.g. to export them as Prometheus metrics. It must be safe for concurrent
+// use.
+type MetricsRecorder interface {
+	// RequestStarted is called before a page of the given entity is requested
+	// from the datasource.
+	RequestStarted(entityExternalID string)
+
+	// RequestFinished is called once a page of the given entity was returned
+	// by the datasource, or failed.
+	RequestFinished(entityExternalID string, metrics RequestMetrics)
+}
+
+// RequestMetrics are the metrics of a page requested from the datasource.
+type RequestMetrics struct {
+	// StatusCode is the HTTP status code of the datasource's final response.
+	// Zero if no response was received.
+	StatusCode int
+
+	// Duration is the time taken to return the page, including retries.
+	Duration time.Duration
+
+	// BytesRead is the size of the response body read, after decompression.
+	BytesRead int64
+
+	// Err is the error returned instead of the page, if any.
+	Err *framework.Error
+
+	// RetriesExhausted is whether the request failed after all configured
+	// retries were exhausted.
+	RetriesExhausted bool
+}
+
+// noopMetricsRecorder is a MetricsRecorder that discards all metrics.
+type noopMetricsRecorder struct{}
+
+func (noopMetricsRecorder) RequestStarted(string)                  {}
+func (noopMetricsRecorder) RequestFinished(string, RequestMetrics) {}
+
+// metricsRecorderOrNoop returns the given recorder, or a MetricsRecorder
+// discarding all metrics if nil.
+func metricsRecorderOrNoop(recorder MetricsRecorder) MetricsRecorder {
+	if recorder == nil {
+		return noopMetricsRecorder{}
+	}
+
+	return recorder
+}
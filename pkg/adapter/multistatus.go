@@ -0,0 +1,159 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxLoggedMultiStatusFailures is the maximum number of failed elements of a
+// multi-status response whose details are logged.
+const maxLoggedMultiStatusFailures = 5
+
+// MultiStatus configures the parsing of `207 Multi-Status` responses, in which
+// each element of the list of objects has its own status.
+// Fields that are not set default to the names used by most datasources.
+type MultiStatus struct {
+	// StatusField is the name of the element field holding the element's HTTP
+	// status code, as a number or a string such as "200 OK".
+	// Defaults to "status".
+	StatusField string `json:"statusField,omitempty"`
+
+	// BodyField is the name of the element field holding the object.
+	// Elements without this field are the object themselves.
+	// Defaults to "body".
+	BodyField string `json:"bodyField,omitempty"`
+
+	// MaxFailedPercent is the maximum percentage of failed elements in a page.
+	// Failed elements are skipped with a warning, and the page fails if the
+	// percentage of failed elements exceeds this threshold.
+	// Defaults to 0, i.e. any failed element fails the page.
+	MaxFailedPercent float64 `json:"maxFailedPercent,omitempty"`
+}
+
+// withDefaults returns a copy of the given configuration with the names that
+// are not set replaced by their defaults.
+func (m *MultiStatus) withDefaults() MultiStatus {
+	config := MultiStatus{
+		StatusField: "status",
+		BodyField:   "body",
+	}
+
+	if m == nil {
+		return config
+	}
+
+	if m.StatusField != "" {
+		config.StatusField = m.StatusField
+	}
+
+	if m.BodyField != "" {
+		config.BodyField = m.BodyField
+	}
+
+	config.MaxFailedPercent = m.MaxFailedPercent
+
+	return config
+}
+
+// splitMultiStatus returns the objects of the successful elements of a
+// multi-status response, i.e. the elements with a 2xx status.
+//
+// The details of failed elements are logged, and an error is returned if the
+// percentage of failed elements exceeds the configured threshold.
+func splitMultiStatus(elements []map[string]any, config MultiStatus, logger Logger) ([]map[string]any, error) {
+	objects := make([]map[string]any, 0, len(elements))
+
+	var failures []string
+
+	for i, element := range elements {
+		status, err := elementStatus(element[config.StatusField])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("element %d: %v", i, err))
+
+			continue
+		}
+
+		body, found := element[config.BodyField]
+
+		if status < 200 || status >= 300 {
+			details, _ := json.Marshal(body)
+			failures = append(failures, fmt.Sprintf("element %d: status %d: %s", i, status, details))
+
+			continue
+		}
+
+		if !found {
+			objects = append(objects, element)
+
+			continue
+		}
+
+		object, ok := body.(map[string]any)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("element %d: %s is not an object", i, config.BodyField))
+
+			continue
+		}
+
+		objects = append(objects, object)
+	}
+
+	if len(failures) == 0 {
+		return objects, nil
+	}
+
+	failedPercent := float64(len(failures)) / float64(len(elements)) * 100
+
+	logged := failures
+	if len(logged) > maxLoggedMultiStatusFailures {
+		logged = logged[:maxLoggedMultiStatusFailures]
+	}
+
+	if failedPercent > config.MaxFailedPercent {
+		return nil, fmt.Errorf("%d of %d multi-status elements failed (%.1f%%), exceeding the threshold of %.1f%%: %s",
+			len(failures), len(elements), failedPercent, config.MaxFailedPercent, strings.Join(logged, "; "))
+	}
+
+	logger.Warn("Skipped failed multi-status elements.",
+		"skipped", len(failures), "total", len(elements), "failures", strings.Join(logged, "; "))
+
+	return objects, nil
+}
+
+// elementStatus returns the HTTP status code in the given status field value
+// of a multi-status element.
+func elementStatus(value any) (int, error) {
+	switch value := value.(type) {
+	case float64:
+		return int(value), nil
+	case string:
+		code, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			return 0, fmt.Errorf("invalid status %q", value)
+		}
+
+		return status, nil
+	case nil:
+		return 0, fmt.Errorf("missing status")
+	default:
+		return 0, fmt.Errorf("invalid status %v", value)
+	}
+}
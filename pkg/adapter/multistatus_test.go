@@ -0,0 +1,107 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitMultiStatus(t *testing.T) {
+	elements := []map[string]any{
+		{"status": float64(200), "body": map[string]any{"id": "P1"}},
+		{"status": "201 Created", "body": map[string]any{"id": "P2"}},
+		{"status": float64(404), "body": map[string]any{"error": "not found"}},
+		{"status": float64(200), "id": "P3"},
+	}
+
+	tests := map[string]struct {
+		elements    []map[string]any
+		config      *MultiStatus
+		wantIDs     []string
+		wantErrText string
+	}{
+		"all_successful": {
+			elements: elements[:2],
+			wantIDs:  []string{"P1", "P2"},
+		},
+		"element_without_body": {
+			elements: elements[3:],
+			wantIDs:  []string{"P3"},
+		},
+		"failure_under_threshold": {
+			elements: elements,
+			config:   &MultiStatus{MaxFailedPercent: 25},
+			wantIDs:  []string{"P1", "P2", "P3"},
+		},
+		"failure_over_threshold": {
+			elements:    elements,
+			config:      &MultiStatus{MaxFailedPercent: 20},
+			wantErrText: `1 of 4 multi-status elements failed (25.0%), exceeding the threshold of 20.0%: element 2: status 404: {"error":"not found"}`,
+		},
+		"failure_without_threshold": {
+			elements:    elements,
+			wantErrText: "1 of 4 multi-status elements failed",
+		},
+		"custom_fields": {
+			elements: []map[string]any{
+				{"code": float64(200), "item": map[string]any{"id": "P1"}},
+				{"code": float64(500), "item": nil},
+			},
+			config:  &MultiStatus{StatusField: "code", BodyField: "item", MaxFailedPercent: 50},
+			wantIDs: []string{"P1"},
+		},
+		"missing_status": {
+			elements:    []map[string]any{{"body": map[string]any{"id": "P1"}}},
+			wantErrText: "element 0: missing status",
+		},
+		"invalid_status": {
+			elements:    []map[string]any{{"status": "OK", "body": map[string]any{"id": "P1"}}},
+			wantErrText: `element 0: invalid status "OK"`,
+		},
+		"non_object_body": {
+			elements:    []map[string]any{{"status": float64(200), "body": "P1"}},
+			wantErrText: "element 0: body is not an object",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			objects, err := splitMultiStatus(tt.elements, tt.config.withDefaults(), loggerOrNoop(nil))
+
+			if tt.wantErrText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Errorf("Expected error containing %q, got %v.", tt.wantErrText, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			gotIDs := make([]string, 0, len(objects))
+			for _, object := range objects {
+				gotIDs = append(gotIDs, object["id"].(string))
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantIDs, gotIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// AuthModeToken authenticates requests to the datasource with the API
+	// token passed in the request's auth credentials.
+	AuthModeToken = "token"
+
+	// AuthModeBasic authenticates requests to the datasource with HTTP Basic
+	// auth, using the username and password passed in the request's auth
+	// credentials, e.g. for on-premises datasources without API tokens.
+	AuthModeBasic = "basic"
+
+	// AuthModeOAuth2TokenExchange authenticates requests to the datasource with
+	// an access token obtained by exchanging the token passed in the request's
+	// auth credentials, cf. RFC 8693.
+	AuthModeOAuth2TokenExchange = "oauth2_token_exchange"
+
+	// AuthModeOAuth2ClientCredentials authenticates requests to the datasource
+	// with an access token obtained with the configured client credentials,
+	// cf. RFC 6749 section 4.4.
+	AuthModeOAuth2ClientCredentials = "oauth2_client_credentials"
+
+	// AuthSchemeToken sends the API token in the Authorization header as
+	// "Token token=<token>".
+	AuthSchemeToken = "token"
+
+	// AuthSchemeBearer sends the API token in the Authorization header as
+	// "Bearer <token>".
+	AuthSchemeBearer = "bearer"
+
+	// AuthSchemeBasic sends the API token in the Authorization header as
+	// "Basic <token>", the token holding the already encoded credentials.
+	AuthSchemeBasic = "basic"
+
+	// ClientCredentialsGrantType is the OAuth2 grant type of client credentials
+	// requests.
+	ClientCredentialsGrantType = "client_credentials"
+
+	// TokenExchangeGrantType is the OAuth2 grant type of token exchange requests.
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// DefaultSubjectTokenType is the type of the subject token sent in token
+	// exchange requests if none is configured.
+	DefaultSubjectTokenType = "urn:ietf:params:oauth:token-type:access_token"
+
+	// tokenExpiryMargin is subtracted from the lifetime of cached access tokens
+	// so that they are renewed before the datasource rejects them. It is at
+	// most a tenth of the lifetime, so that short-lived tokens are reused.
+	tokenExpiryMargin = 30 * time.Second
+
+	// maxTokenResponseBytes is the maximum size of a token endpoint response.
+	maxTokenResponseBytes = 1 << 20
+
+	// defaultTokenLifetime is the time for which access tokens are cached if
+	// the token endpoint announces no expiry.
+	defaultTokenLifetime = time.Hour
+
+	// maxCachedTokens is the maximum number of access tokens cached by a
+	// Datasource, e.g. for as many subject tokens exchanged.
+	maxCachedTokens = 1000
+)
+
+// cachedToken is an access token obtained from an OAuth2 token endpoint.
+type cachedToken struct {
+	accessToken string
+
+	// expiresAt is the time after which the token must be renewed.
+	expiresAt time.Time
+}
+
+// tokenResponse is a successful response from an OAuth2 token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type,omitempty"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
+}
+
+// exchangeToken returns an access token for the request's subject token,
+// obtained via OAuth2 token exchange. Access tokens are cached per subject
+// token, audience and token endpoint until they expire. The token request
+// times out after the request timeout.
+func (d *Datasource) exchangeToken(ctx context.Context, client *http.Client, request *Request) (string, error) {
+	subjectToken := strings.TrimSpace(strings.TrimPrefix(request.Token, "Bearer "))
+
+	key := tokenCacheKey(request.TokenURL, request.Audience, subjectToken)
+
+	if accessToken, found := d.cachedAccessToken(key); found {
+		return accessToken, nil
+	}
+
+	subjectTokenType := request.SubjectTokenType
+	if subjectTokenType == "" {
+		subjectTokenType = DefaultSubjectTokenType
+	}
+
+	form := url.Values{
+		"grant_type":         {TokenExchangeGrantType},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {subjectTokenType},
+	}
+
+	if request.Audience != "" {
+		form.Set("audience", request.Audience)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout(ctx, request))
+	defer cancel()
+
+	response, err := requestToken(ctx, client, request.TokenURL, request.userAgent(), form)
+	if err != nil {
+		return "", err
+	}
+
+	return d.cacheAccessToken(key, response), nil
+}
+
+// clientCredentialsToken returns an access token obtained with the request's
+// client credentials. Access tokens are cached per client, audience and token
+// endpoint until they expire or are invalidated with invalidateClientCredentialsToken.
+// The token request times out after the request timeout.
+func (d *Datasource) clientCredentialsToken(ctx context.Context, client *http.Client, request *Request) (string, error) {
+	key := clientCredentialsCacheKey(request)
+
+	if accessToken, found := d.cachedAccessToken(key); found {
+		return accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {ClientCredentialsGrantType},
+		"client_id":     {request.ClientID},
+		"client_secret": {request.ClientSecret},
+	}
+
+	if request.Audience != "" {
+		form.Set("audience", request.Audience)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout(ctx, request))
+	defer cancel()
+
+	response, err := requestToken(ctx, client, request.TokenURL, request.userAgent(), form)
+	if err != nil {
+		return "", err
+	}
+
+	return d.cacheAccessToken(key, response), nil
+}
+
+// invalidateClientCredentialsToken removes the cached access token obtained
+// with the request's client credentials, e.g. after the datasource rejected it
+// before its announced expiry.
+func (d *Datasource) invalidateClientCredentialsToken(request *Request) {
+	key := clientCredentialsCacheKey(request)
+
+	d.tokensMu.Lock()
+	defer d.tokensMu.Unlock()
+
+	delete(d.tokens, key)
+}
+
+// clientCredentialsCacheKey returns the key of the access token cache for the
+// request's client credentials and the audience they are requested for.
+func clientCredentialsCacheKey(request *Request) string {
+	return tokenCacheKey(request.TokenURL, request.ClientID, request.ClientSecret, request.Audience)
+}
+
+// tokenCacheKey returns the key of the access token cache for the given
+// values, hashed so that no credential is kept in memory longer than needed.
+func tokenCacheKey(values ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(values, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedAccessToken returns the cached access token with the given key, if it
+// has not expired.
+func (d *Datasource) cachedAccessToken(key string) (string, bool) {
+	d.tokensMu.Lock()
+	defer d.tokensMu.Unlock()
+
+	token, found := d.tokens[key]
+	if !found || !time.Now().Before(token.expiresAt) {
+		return "", false
+	}
+
+	return token.accessToken, true
+}
+
+// cacheAccessToken caches the access token in the given response with the
+// given key until it expires, or for defaultTokenLifetime if it announces no
+// expiry, and returns it.
+//
+// Expired tokens are evicted once the cache holds maxCachedTokens tokens, and
+// then the token expiring first if none has expired.
+func (d *Datasource) cacheAccessToken(key string, response *tokenResponse) string {
+	now := time.Now()
+
+	token := cachedToken{
+		accessToken: response.AccessToken,
+		expiresAt:   now.Add(defaultTokenLifetime),
+	}
+
+	if response.ExpiresIn > 0 {
+		lifetime := time.Duration(response.ExpiresIn) * time.Second
+		token.expiresAt = now.Add(lifetime - min(tokenExpiryMargin, lifetime/10))
+	}
+
+	d.tokensMu.Lock()
+	defer d.tokensMu.Unlock()
+
+	if d.tokens == nil {
+		d.tokens = make(map[string]cachedToken)
+	}
+
+	if _, found := d.tokens[key]; !found && len(d.tokens) >= maxCachedTokens {
+		d.evictAccessTokens(now)
+	}
+
+	d.tokens[key] = token
+
+	return token.accessToken
+}
+
+// evictAccessTokens removes the expired access tokens from the cache, or the
+// token expiring first if none has expired. d.tokensMu must be held.
+func (d *Datasource) evictAccessTokens(now time.Time) {
+	var firstKey string
+
+	var firstExpiry time.Time
+
+	for key, token := range d.tokens {
+		if !now.Before(token.expiresAt) {
+			delete(d.tokens, key)
+
+			continue
+		}
+
+		if firstKey == "" || token.expiresAt.Before(firstExpiry) {
+			firstKey, firstExpiry = key, token.expiresAt
+		}
+	}
+
+	if len(d.tokens) >= maxCachedTokens {
+		delete(d.tokens, firstKey)
+	}
+}
+
+// requestToken sends the given form to an OAuth2 token endpoint with the given
+// User-Agent header and returns the token in the response.
+func requestToken(
+	ctx context.Context, client *http.Client, tokenURL, userAgent string, form url.Values,
+) (*tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send token request: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxTokenResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if len(body) > maxTokenResponseBytes {
+		return nil, fmt.Errorf("token response exceeds the maximum size of %d bytes", maxTokenResponseBytes)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status code %d", res.StatusCode)
+	}
+
+	var response tokenResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+
+	if response.AccessToken == "" {
+		return nil, errors.New("token response contains no access token")
+	}
+
+	return &response, nil
+}
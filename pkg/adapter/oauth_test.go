@@ -0,0 +1,498 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testOAuthServer is a datasource with an OAuth2 token endpoint at /token,
+// which records the token requests and the Authorization headers of the
+// requests to the datasource.
+type testOAuthServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+
+	// tokenForms are the forms of the requests to the token endpoint.
+	tokenForms []url.Values
+
+	// authorizations are the Authorization headers of the requests to the
+	// datasource.
+	authorizations []string
+
+	// rejected is the set of access tokens the datasource rejects with a 401.
+	rejected map[string]bool
+}
+
+// newTestOAuthServer returns a testOAuthServer whose token endpoint returns
+// the access tokens "access-1", "access-2"... in order, with the given
+// expires_in, which is closed at the end of the test.
+func newTestOAuthServer(t *testing.T, expiresIn int) *testOAuthServer {
+	t.Helper()
+
+	s := &testOAuthServer{rejected: make(map[string]bool)}
+
+	s.Server = newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Path == "/token" {
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("Failed to parse token request form: %v.", err)
+			}
+
+			s.tokenForms = append(s.tokenForms, r.PostForm)
+
+			fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":%d}`,
+				len(s.tokenForms), expiresIn)
+
+			return
+		}
+
+		authorization := r.Header.Get("Authorization")
+		s.authorizations = append(s.authorizations, authorization)
+
+		if s.rejected[authorization] {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+	})
+
+	return s
+}
+
+// reject makes the datasource reject the given access token.
+func (s *testOAuthServer) reject(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejected["Bearer "+accessToken] = true
+}
+
+// requests returns the token request forms and the datasource Authorization
+// headers received so far.
+func (s *testOAuthServer) requests() ([]url.Values, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]url.Values(nil), s.tokenForms...), append([]string(nil), s.authorizations...)
+}
+
+func TestDatasourceGetPageTokenExchange(t *testing.T) {
+	server := newTestOAuthServer(t, 3600)
+
+	request := newTestDatasourceRequest(server.Server)
+	request.AuthMode = AuthModeOAuth2TokenExchange
+	request.TokenURL = server.URL + "/token"
+	request.Token = "Bearer subject-token"
+	request.Audience = "https://api.pagerduty.com"
+
+	client := NewClient(5)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPage(context.Background(), request); err != nil {
+			t.Fatalf("Expected no error, got %+v.", err)
+		}
+	}
+
+	tokenForms, authorizations := server.requests()
+
+	// The exchanged token is cached for the second request.
+	if len(tokenForms) != 1 {
+		t.Fatalf("Expected 1 token request, got %d.", len(tokenForms))
+	}
+
+	wantForm := url.Values{
+		"grant_type":         {TokenExchangeGrantType},
+		"subject_token":      {"subject-token"},
+		"subject_token_type": {DefaultSubjectTokenType},
+		"audience":           {"https://api.pagerduty.com"},
+	}
+
+	if got := tokenForms[0].Encode(); got != wantForm.Encode() {
+		t.Errorf("Expected token request form %q, got %q.", wantForm.Encode(), got)
+	}
+
+	for i, authorization := range authorizations {
+		if authorization != "Bearer access-1" {
+			t.Errorf("Expected request %d sent with the exchanged token, got %q.", i, authorization)
+		}
+	}
+
+	// Another subject token is exchanged separately.
+	request.Token = "Bearer other-subject-token"
+
+	if _, err := client.GetPage(context.Background(), request); err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	if tokenForms, _ = server.requests(); len(tokenForms) != 2 {
+		t.Errorf("Expected a token request for the other subject token, got %d token requests.", len(tokenForms))
+	}
+}
+
+func TestDatasourceAccessTokenCacheEviction(t *testing.T) {
+	datasource := NewClient(5).(*Datasource)
+
+	for i := 0; i < maxCachedTokens; i++ {
+		datasource.cacheAccessToken(fmt.Sprintf("key-%d", i), &tokenResponse{AccessToken: "token", ExpiresIn: 3600})
+	}
+
+	// An expired token is evicted first.
+	datasource.tokens["key-expired"] = cachedToken{accessToken: "token", expiresAt: time.Now().Add(-time.Second)}
+	delete(datasource.tokens, "key-0")
+
+	datasource.cacheAccessToken("key-new", &tokenResponse{AccessToken: "token", ExpiresIn: 3600})
+
+	if _, found := datasource.tokens["key-expired"]; found {
+		t.Error("Expected the expired token to be evicted.")
+	}
+
+	// Without expired tokens, the token expiring first is evicted.
+	datasource.tokens["key-1"] = cachedToken{accessToken: "token", expiresAt: time.Now().Add(time.Minute)}
+
+	datasource.cacheAccessToken("key-newer", &tokenResponse{AccessToken: "token", ExpiresIn: 3600})
+
+	if _, found := datasource.tokens["key-1"]; found {
+		t.Error("Expected the token expiring first to be evicted.")
+	}
+
+	if len(datasource.tokens) != maxCachedTokens {
+		t.Errorf("Expected %d cached tokens, got %d.", maxCachedTokens, len(datasource.tokens))
+	}
+
+	// Tokens without an announced expiry expire after the default lifetime.
+	datasource.cacheAccessToken("key-newer", &tokenResponse{AccessToken: "token"})
+
+	if got := time.Until(datasource.tokens["key-newer"].expiresAt); got > defaultTokenLifetime || got < defaultTokenLifetime-time.Minute {
+		t.Errorf("Expected the token to expire after %v, got %v.", defaultTokenLifetime, got)
+	}
+}
+
+func TestDatasourceGetPageClientCredentials(t *testing.T) {
+	server := newTestOAuthServer(t, 3600)
+
+	request := newTestDatasourceRequest(server.Server)
+	request.AuthMode = AuthModeOAuth2ClientCredentials
+	request.Token = ""
+	request.TokenURL = server.URL + "/token"
+	request.ClientID = "client-id"
+	request.ClientSecret = "client-secret"
+
+	client := NewClient(5)
+
+	// The access token is fetched, then reused from the cache.
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPage(context.Background(), request); err != nil {
+			t.Fatalf("Expected no error, got %+v.", err)
+		}
+	}
+
+	tokenForms, authorizations := server.requests()
+
+	if len(tokenForms) != 1 {
+		t.Fatalf("Expected 1 token request, got %d.", len(tokenForms))
+	}
+
+	wantForm := url.Values{
+		"grant_type":    {ClientCredentialsGrantType},
+		"client_id":     {"client-id"},
+		"client_secret": {"client-secret"},
+	}
+
+	if got := tokenForms[0].Encode(); got != wantForm.Encode() {
+		t.Errorf("Expected token request form %q, got %q.", wantForm.Encode(), got)
+	}
+
+	if want := []string{"Bearer access-1", "Bearer access-1"}; !reflect.DeepEqual(authorizations, want) {
+		t.Errorf("Expected requests sent with %v, got %v.", want, authorizations)
+	}
+
+	// A token revoked before its expiry is refreshed, and the request is sent
+	// again with the new token.
+	server.reject("access-1")
+
+	if _, err := client.GetPage(context.Background(), request); err != nil {
+		t.Fatalf("Expected no error after refreshing the token, got %+v.", err)
+	}
+
+	tokenForms, authorizations = server.requests()
+
+	if len(tokenForms) != 2 {
+		t.Errorf("Expected the token to be refreshed, got %d token requests.", len(tokenForms))
+	}
+
+	if want := []string{"Bearer access-1", "Bearer access-2"}; !reflect.DeepEqual(authorizations[2:], want) {
+		t.Errorf("Expected requests sent with %v after the revocation, got %v.", want, authorizations[2:])
+	}
+}
+
+func TestDatasourceGetPageClientCredentialsAudiences(t *testing.T) {
+	server := newTestOAuthServer(t, 3600)
+
+	request := newTestDatasourceRequest(server.Server)
+	request.AuthMode = AuthModeOAuth2ClientCredentials
+	request.Token = ""
+	request.TokenURL = server.URL + "/token"
+	request.ClientID = "client-id"
+	request.ClientSecret = "client-secret"
+
+	client := NewClient(5)
+
+	// The same client gets a distinct token for each audience.
+	for _, audience := range []string{"audience-a", "audience-b", "audience-a"} {
+		request.Audience = audience
+
+		if _, err := client.GetPage(context.Background(), request); err != nil {
+			t.Fatalf("Expected no error, got %+v.", err)
+		}
+	}
+
+	tokenForms, authorizations := server.requests()
+
+	if len(tokenForms) != 2 {
+		t.Fatalf("Expected 2 token requests, got %d.", len(tokenForms))
+	}
+
+	for i, want := range []string{"audience-a", "audience-b"} {
+		if got := tokenForms[i].Get("audience"); got != want {
+			t.Errorf("Expected token request %d for audience %q, got %q.", i, want, got)
+		}
+	}
+
+	if want := []string{"Bearer access-1", "Bearer access-2", "Bearer access-1"}; !reflect.DeepEqual(authorizations, want) {
+		t.Errorf("Expected requests sent with %v, got %v.", want, authorizations)
+	}
+
+	// A token rejected for one audience leaves the other audience's token
+	// cached.
+	server.reject("access-2")
+
+	for _, audience := range []string{"audience-b", "audience-a"} {
+		request.Audience = audience
+
+		if _, err := client.GetPage(context.Background(), request); err != nil {
+			t.Fatalf("Expected no error, got %+v.", err)
+		}
+	}
+
+	tokenForms, authorizations = server.requests()
+
+	if len(tokenForms) != 3 {
+		t.Errorf("Expected only the rejected token to be refreshed, got %d token requests.", len(tokenForms))
+	}
+
+	if want := []string{"Bearer access-2", "Bearer access-3", "Bearer access-1"}; !reflect.DeepEqual(authorizations[3:], want) {
+		t.Errorf("Expected requests sent with %v after the rejection, got %v.", want, authorizations[3:])
+	}
+}
+
+func TestDatasourceGetPageTokenRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+
+	request := newTestDatasourceRequest(server)
+	request.AuthMode = AuthModeOAuth2ClientCredentials
+	request.TokenURL = server.URL + "/token"
+	request.ClientID = "client-id"
+	request.ClientSecret = "client-secret"
+	request.RequestTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+
+	if _, err := NewClient(10).GetPage(context.Background(), request); err == nil {
+		t.Fatal("Expected an error, got none.")
+	}
+
+	// The token request is bounded by the request timeout, not the client's.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the token request to time out with the request, took %v.", elapsed)
+	}
+}
+
+func TestDatasourceAccessTokenExpiry(t *testing.T) {
+	tests := map[string]struct {
+		expiresIn    int64
+		wantLifetime time.Duration
+	}{
+		"long_lived": {
+			expiresIn:    3600,
+			wantLifetime: 3600*time.Second - tokenExpiryMargin,
+		},
+		// The margin is at most a tenth of the lifetime, so that tokens living
+		// no longer than the margin are still reused.
+		"lifetime_of_margin": {
+			expiresIn:    30,
+			wantLifetime: 27 * time.Second,
+		},
+		"short_lived": {
+			expiresIn:    1,
+			wantLifetime: 900 * time.Millisecond,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			datasource := NewClient(5).(*Datasource)
+
+			start := time.Now()
+			datasource.cacheAccessToken("key", &tokenResponse{AccessToken: "token", ExpiresIn: tt.expiresIn})
+
+			got := datasource.tokens["key"].expiresAt.Sub(start)
+			if got < tt.wantLifetime || got > tt.wantLifetime+time.Second {
+				t.Errorf("Expected the token to be cached for %v, got %v.", tt.wantLifetime, got)
+			}
+
+			if _, found := datasource.cachedAccessToken("key"); !found {
+				t.Error("Expected the token to be reused.")
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageTokenResponseLimit(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"access_token":"%s"}`, strings.Repeat("a", maxTokenResponseBytes))
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.AuthMode = AuthModeOAuth2ClientCredentials
+	request.TokenURL = server.URL + "/token"
+	request.ClientID = "client-id"
+	request.ClientSecret = "client-secret"
+
+	_, err := NewClient(5).GetPage(context.Background(), request)
+	if err == nil || !strings.Contains(err.Message, "exceeds the maximum size") {
+		t.Errorf("Expected an error for the oversized token response, got %+v.", err)
+	}
+}
+
+// newTestSelfSignedTLSServer returns a TLS server calling the given handler
+// with a newly generated self-signed certificate, unlike the certificate shared
+// by all the servers created with httptest.NewTLSServer. It is closed at the
+// end of the test.
+func newTestSelfSignedTLSServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v.", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:     []string{"localhost"},
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v.", err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{certificate}, PrivateKey: key}},
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDatasourceGetPageTokenEndpointTLSPinning(t *testing.T) {
+	// The token endpoint is on another host than the datasource, with a
+	// certificate that is trusted but not pinned.
+	tokenServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"access_token":"access-1","token_type":"Bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	server := newTestSelfSignedTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer access-1" {
+			t.Errorf("Expected request sent with the access token, got %q.", got)
+		}
+
+		w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+	})
+
+	sum := sha256.Sum256(server.Certificate().Raw)
+
+	tests := map[string]func(request *Request){
+		"token_exchange": func(request *Request) {
+			request.AuthMode = AuthModeOAuth2TokenExchange
+			request.Token = "Bearer subject-token"
+		},
+		"client_credentials": func(request *Request) {
+			request.AuthMode = AuthModeOAuth2ClientCredentials
+			request.Token = ""
+			request.ClientID = "client-id"
+			request.ClientSecret = "client-secret"
+		},
+	}
+
+	for name, configureAuth := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := newTestDatasourceRequest(server)
+			request.TokenURL = tokenServer.URL + "/token"
+			request.TLSPinnedSHA256 = []string{fmt.Sprintf("%x", sum)}
+			request.RootCAsPEM = string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: tokenServer.Certificate().Raw,
+			}))
+			configureAuth(request)
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %v.", response.Objects)
+			}
+		})
+	}
+}
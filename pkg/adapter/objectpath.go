@@ -0,0 +1,204 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// objectPathStep is a step of an object-list path: either the name of a field,
+// an array index, or an array wildcard.
+type objectPathStep struct {
+	field    string
+	index    int
+	wildcard bool
+	isIndex  bool
+}
+
+// parseObjectPath parses a JSONPath-style object-list path, e.g.
+// "result.groups[*].teams" or "$.data[0].items", or a JSON Pointer, e.g.
+// "/data/results/items", into its steps.
+func parseObjectPath(path string) ([]objectPathStep, error) {
+	if strings.HasPrefix(path, "/") {
+		return parseObjectPointer(path)
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+
+	if path == "" {
+		return nil, fmt.Errorf("object path is empty")
+	}
+
+	var steps []objectPathStep
+
+	for _, segment := range strings.Split(path, ".") {
+		field, subscripts, _ := strings.Cut(segment, "[")
+
+		if field == "" && subscripts == "" {
+			return nil, fmt.Errorf("object path %q contains an empty field name", path)
+		}
+
+		if field != "" {
+			steps = append(steps, objectPathStep{field: field})
+		}
+
+		if subscripts == "" {
+			continue
+		}
+
+		for _, subscript := range strings.Split(strings.TrimSuffix(subscripts, "]"), "][") {
+			if subscript == "*" {
+				steps = append(steps, objectPathStep{wildcard: true})
+
+				continue
+			}
+
+			index, err := strconv.Atoi(subscript)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("object path %q contains an invalid array subscript: %q", path, subscript)
+			}
+
+			steps = append(steps, objectPathStep{index: index, isIndex: true})
+		}
+	}
+
+	return steps, nil
+}
+
+// parseObjectPointer parses a JSON Pointer as defined in RFC 6901 into the
+// steps of an object-list path. Since a pointer doesn't tell apart array
+// indices from field names, numeric tokens match either.
+func parseObjectPointer(pointer string) ([]objectPathStep, error) {
+	if pointer == "/" {
+		return nil, fmt.Errorf("object path %q contains an empty field name", pointer)
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+
+	var steps []objectPathStep
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		if token == "" {
+			return nil, fmt.Errorf("object path %q contains an empty field name", pointer)
+		}
+
+		step := objectPathStep{field: unescape.Replace(token)}
+
+		if index, err := strconv.Atoi(token); err == nil && index >= 0 {
+			step.index = index
+			step.isIndex = true
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// extractObjects returns the objects found at the given object-list path in a
+// decoded JSON document. The arrays matched by the path are concatenated in
+// order, e.g. "result.groups[*].teams" returns the teams of all groups.
+// Fields that are missing from the document are ignored.
+func extractObjects(document any, path string) ([]map[string]any, error) {
+	steps, err := parseObjectPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := matchObjectPath(document, steps)
+
+	objects := make([]map[string]any, 0)
+
+	for _, node := range nodes {
+		switch value := node.(type) {
+		case []any:
+			for _, element := range value {
+				object, ok := element.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("object path %q matches a non-object array element of type %T", path, element)
+				}
+
+				objects = append(objects, object)
+			}
+		case map[string]any:
+			objects = append(objects, value)
+		case nil:
+		default:
+			return nil, fmt.Errorf("object path %q matches a value of type %T instead of an array", path, node)
+		}
+	}
+
+	return objects, nil
+}
+
+// matchObjectPath returns the values matched by the given steps of an
+// object-list path in a decoded JSON document, in order.
+func matchObjectPath(document any, steps []objectPathStep) []any {
+	nodes := []any{document}
+
+	for _, step := range steps {
+		var next []any
+
+		for _, node := range nodes {
+			switch {
+			case step.wildcard:
+				if array, ok := node.([]any); ok {
+					next = append(next, array...)
+				}
+			case step.isIndex:
+				switch value := node.(type) {
+				case []any:
+					if step.index < len(value) {
+						next = append(next, value[step.index])
+					}
+				case map[string]any:
+					// Numeric JSON Pointer tokens may also be field names.
+					if field, found := value[step.field]; found && step.field != "" {
+						next = append(next, field)
+					}
+				}
+			default:
+				if object, ok := node.(map[string]any); ok {
+					if value, found := object[step.field]; found {
+						next = append(next, value)
+					}
+				}
+			}
+		}
+
+		nodes = next
+	}
+
+	return nodes
+}
+
+// validCursorPath returns whether the given path is a valid object path which
+// matches at most a single value, i.e. one without array wildcards.
+func validCursorPath(path string) bool {
+	steps, err := parseObjectPath(path)
+	if err != nil {
+		return false
+	}
+
+	for _, step := range steps {
+		if step.wildcard {
+			return false
+		}
+	}
+
+	return true
+}
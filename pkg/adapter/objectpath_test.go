@@ -0,0 +1,124 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractObjects(t *testing.T) {
+	tests := map[string]struct {
+		document    string
+		path        string
+		wantIDs     []string
+		wantErrText string
+	}{
+		"wildcard": {
+			document: `{"result":{"groups":[{"teams":[{"id":"T1"},{"id":"T2"}]},{"teams":[{"id":"T3"}]}]}}`,
+			path:     "result.groups[*].teams",
+			wantIDs:  []string{"T1", "T2", "T3"},
+		},
+		"wildcard_with_root": {
+			document: `{"result":{"groups":[{"teams":[{"id":"T1"}]},{"teams":[{"id":"T2"}]}]}}`,
+			path:     "$.result.groups[*].teams",
+			wantIDs:  []string{"T1", "T2"},
+		},
+		"nested_wildcards": {
+			document: `{"orgs":[{"groups":[{"teams":[{"id":"T1"}]},{"teams":[{"id":"T2"}]}]},{"groups":[{"teams":[{"id":"T3"}]}]}]}`,
+			path:     "orgs[*].groups[*].teams",
+			wantIDs:  []string{"T1", "T2", "T3"},
+		},
+		"wildcard_over_objects": {
+			document: `{"teams":[{"id":"T1"},{"id":"T2"}]}`,
+			path:     "teams[*]",
+			wantIDs:  []string{"T1", "T2"},
+		},
+		"wildcard_skips_missing_fields": {
+			document: `{"groups":[{"teams":[{"id":"T1"}]},{"name":"empty"},{"teams":null},{"teams":[{"id":"T2"}]}]}`,
+			path:     "groups[*].teams",
+			wantIDs:  []string{"T1", "T2"},
+		},
+		"index": {
+			document: `{"groups":[{"teams":[{"id":"T1"}]},{"teams":[{"id":"T2"}]}]}`,
+			path:     "groups[1].teams",
+			wantIDs:  []string{"T2"},
+		},
+		"index_out_of_range": {
+			document: `{"groups":[{"teams":[{"id":"T1"}]}]}`,
+			path:     "groups[3].teams",
+			wantIDs:  []string{},
+		},
+		"missing_field": {
+			document: `{"result":{}}`,
+			path:     "result.groups[*].teams",
+			wantIDs:  []string{},
+		},
+		"non_object_element": {
+			document:    `{"groups":[{"teams":["T1"]}]}`,
+			path:        "groups[*].teams",
+			wantErrText: "non-object array element",
+		},
+		"non_array_value": {
+			document:    `{"groups":[{"teams":"T1"}]}`,
+			path:        "groups[*].teams",
+			wantErrText: "instead of an array",
+		},
+		"invalid_subscript": {
+			document:    `{}`,
+			path:        "groups[x].teams",
+			wantErrText: "invalid array subscript",
+		},
+		"empty_field": {
+			document:    `{}`,
+			path:        "groups..teams",
+			wantErrText: "empty field name",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var document any
+			if err := json.Unmarshal([]byte(tt.document), &document); err != nil {
+				t.Fatalf("Failed to decode document: %v.", err)
+			}
+
+			objects, err := extractObjects(document, tt.path)
+
+			if tt.wantErrText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Errorf("Expected error containing %q, got %v.", tt.wantErrText, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			gotIDs := make([]string, 0, len(objects))
+			for _, object := range objects {
+				gotIDs = append(gotIDs, object["id"].(string))
+			}
+
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("Expected objects %v, got %v.", tt.wantIDs, gotIDs)
+			}
+		})
+	}
+}
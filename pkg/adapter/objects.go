@@ -0,0 +1,295 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+	"github.com/sgnl-ai/adapter-framework/web"
+)
+
+const (
+	// EmptyStringToNull converts empty string attribute values into nulls.
+	EmptyStringToNull = "empty_to_null"
+
+	// NullToEmptyString converts null string attribute values into empty
+	// strings.
+	NullToEmptyString = "null_to_empty"
+)
+
+// matchAttributeKeysIgnoringCase returns a copy of the given objects where
+// each key that matches the external ID of a requested attribute regardless
+// of case is renamed to that external ID.
+//
+// If an object contains a key that exactly matches an attribute's external ID,
+// that key is used. Otherwise, of several keys differing only by case, the
+// first one in lexicographic order is used. A warning is logged whenever
+// several keys match.
+func matchAttributeKeysIgnoringCase(
+	entity *framework.EntityConfig, objects []map[string]any, logger Logger,
+) []map[string]any {
+	matched := make([]map[string]any, 0, len(objects))
+
+	for _, object := range objects {
+		matchedObject := make(map[string]any, len(object))
+
+		for key, value := range object {
+			matchedObject[key] = value
+		}
+
+		for _, attribute := range entity.Attributes {
+			externalID := attribute.ExternalId
+
+			// JSONPath attribute names are not matched against object keys.
+			if strings.HasPrefix(externalID, "$") {
+				continue
+			}
+
+			var candidates []string
+
+			for key := range object {
+				if strings.EqualFold(key, externalID) {
+					candidates = append(candidates, key)
+				}
+			}
+
+			if len(candidates) == 0 {
+				continue
+			}
+
+			sort.Strings(candidates)
+
+			// The exact key is preferred over its case variants.
+			key := candidates[0]
+			if _, found := object[externalID]; found {
+				key = externalID
+			}
+
+			if len(candidates) > 1 {
+				logger.Warn("Several object keys match attribute ignoring case.",
+					"attribute", externalID, "keys", candidates, "key", key)
+			}
+
+			matchedObject[externalID] = object[key]
+		}
+
+		matched = append(matched, matchedObject)
+	}
+
+	return matched
+}
+
+// addSyntheticIDs returns a copy of the given objects where each object that
+// has no value for the unique ID attribute gets a synthetic ID computed from the
+// values of the given fields.
+//
+// The synthetic ID is the hex-encoded SHA-256 hash of the JSON encoding of the
+// field values, so it is stable across runs for objects with identical values.
+func addSyntheticIDs(objects []map[string]any, uniqueIDAttribute string, fields []string) ([]map[string]any, error) {
+	withIDs := make([]map[string]any, 0, len(objects))
+
+	for _, object := range objects {
+		if id, found := object[uniqueIDAttribute]; found && id != nil && id != "" {
+			withIDs = append(withIDs, object)
+
+			continue
+		}
+
+		// Maps are encoded with sorted keys, which makes the encoding deterministic.
+		values := make(map[string]any, len(fields))
+
+		for _, field := range fields {
+			values[field] = object[field]
+		}
+
+		data, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal fields of synthetic ID: %w", err)
+		}
+
+		sum := sha256.Sum256(data)
+
+		objectWithID := make(map[string]any, len(object)+1)
+
+		for key, value := range object {
+			objectWithID[key] = value
+		}
+
+		objectWithID[uniqueIDAttribute] = hex.EncodeToString(sum[:])
+
+		withIDs = append(withIDs, objectWithID)
+	}
+
+	return withIDs, nil
+}
+
+// normalizeUniqueIDs returns a copy of the given objects where each string
+// unique ID is replaced by its normalized form.
+func normalizeUniqueIDs(objects []map[string]any, uniqueIDAttribute string, normalizer IDNormalizer) []map[string]any {
+	normalized := make([]map[string]any, 0, len(objects))
+
+	for _, object := range objects {
+		id, ok := object[uniqueIDAttribute].(string)
+		if !ok {
+			normalized = append(normalized, object)
+
+			continue
+		}
+
+		normalizedObject := make(map[string]any, len(object))
+
+		for key, value := range object {
+			normalizedObject[key] = value
+		}
+
+		normalizedObject[uniqueIDAttribute] = normalizer(id)
+
+		normalized = append(normalized, normalizedObject)
+	}
+
+	return normalized
+}
+
+// deduplicateObjects returns the given objects without those whose unique ID
+// was already seen earlier in the list, and the number of objects dropped.
+// Objects without a unique ID are kept.
+func deduplicateObjects(objects []map[string]any, uniqueIDAttribute string) ([]map[string]any, int) {
+	seen := make(map[string]struct{}, len(objects))
+	unique := make([]map[string]any, 0, len(objects))
+
+	for _, object := range objects {
+		id, found := object[uniqueIDAttribute]
+		if !found || id == nil {
+			unique = append(unique, object)
+
+			continue
+		}
+
+		key := fmt.Sprint(id)
+
+		if _, duplicate := seen[key]; duplicate {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		unique = append(unique, object)
+	}
+
+	return unique, len(objects) - len(unique)
+}
+
+// normalizeEmptyValues returns a copy of the given objects where the values of
+// the requested attributes are normalized according to the given mode, i.e.
+// EmptyStringToNull or NullToEmptyString.
+//
+// Only the given attributes are normalized, or all the requested attributes if
+// none is given. Nulls are only converted for single-valued string attributes,
+// since an empty string is not a valid value of other types.
+func normalizeEmptyValues(
+	entity *framework.EntityConfig, objects []map[string]any, mode string, attributes []string,
+) []map[string]any {
+	selected := make(map[string]bool, len(attributes))
+
+	for _, attribute := range attributes {
+		selected[attribute] = true
+	}
+
+	normalized := make([]map[string]any, 0, len(objects))
+
+	for _, object := range objects {
+		normalizedObject := make(map[string]any, len(object))
+
+		for key, value := range object {
+			normalizedObject[key] = value
+		}
+
+		for _, attribute := range entity.Attributes {
+			if len(selected) > 0 && !selected[attribute.ExternalId] {
+				continue
+			}
+
+			value, found := object[attribute.ExternalId]
+			if !found {
+				continue
+			}
+
+			switch {
+			case mode == EmptyStringToNull && value == "":
+				normalizedObject[attribute.ExternalId] = nil
+			case mode == NullToEmptyString && value == nil &&
+				attribute.Type == framework.AttributeTypeString && !attribute.List:
+				normalizedObject[attribute.ExternalId] = ""
+			}
+		}
+
+		normalized = append(normalized, normalizedObject)
+	}
+
+	return normalized
+}
+
+// convertJSONObjectListLeniently converts the given objects like
+// web.ConvertJSONObjectList, except that objects that fail to be converted are
+// skipped instead of failing the whole page.
+//
+// An error is returned if the percentage of skipped objects exceeds
+// maxSkippedPercent.
+func convertJSONObjectListLeniently(
+	entity *framework.EntityConfig, objects []map[string]any, maxSkippedPercent float64, logger Logger,
+	opts ...web.JSONOption,
+) ([]framework.Object, *framework.Error) {
+	parsedObjects := make([]framework.Object, 0, len(objects))
+
+	var lastErr error
+
+	for _, object := range objects {
+		parsed, err := web.ConvertJSONObjectList(entity, []map[string]any{object}, opts...)
+		if err != nil {
+			lastErr = err
+
+			continue
+		}
+
+		parsedObjects = append(parsedObjects, parsed...)
+	}
+
+	skipped := len(objects) - len(parsedObjects)
+	if skipped == 0 {
+		return parsedObjects, nil
+	}
+
+	skippedPercent := float64(skipped) / float64(len(objects)) * 100
+
+	if skippedPercent > maxSkippedPercent {
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to convert %d of %d datasource response objects (%.1f%%), "+
+				"exceeding the threshold of %.1f%%, last error: %v.",
+				skipped, len(objects), skippedPercent, maxSkippedPercent, lastErr),
+			Code: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	logger.Warn("Skipped datasource response objects that failed to be converted.",
+		"skipped", skipped, "total", len(objects), "lastError", lastErr)
+
+	return parsedObjects, nil
+}
@@ -0,0 +1,361 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PaginationType is a pagination style supported by the datasource.
+type PaginationType string
+
+const (
+	// PaginationAtlassian paginates with `startAt` and `maxResults` query
+	// parameters, and `startAt`, `maxResults`, `isLast` and `total` response
+	// fields, as in Atlassian APIs.
+	PaginationAtlassian PaginationType = "atlassian"
+
+	// PaginationBookmark paginates with a bookmark returned in a response body
+	// field and sent back in the body of the next POST request, as in CouchDB
+	// and Cloudant APIs. The last page is the first one without objects.
+	PaginationBookmark PaginationType = "bookmark"
+
+	// PaginationOffset paginates with `offset` and `limit` query parameters.
+	// The next offset is returned in the X-Next-Page response header, or else
+	// derived from the `offset`, `limit` and `more` response fields. This is
+	// the default pagination mode. Detected by ProbePagination.
+	PaginationOffset PaginationType = "offset"
+
+	// PaginationCursor paginates with an opaque cursor returned in a response
+	// body field, DefaultCursorResponseField unless configured, and sent back
+	// in a query parameter, DefaultCursorQueryParam unless configured.
+	// Detected by ProbePagination.
+	PaginationCursor PaginationType = "cursor"
+
+	// PaginationHeader paginates with a cursor returned in the X-Next-Page
+	// response header, or the configured cursor header, only.
+	// Detected by ProbePagination.
+	PaginationHeader PaginationType = "header"
+
+	// PaginationLinkHeader paginates with the URL of the next page returned in
+	// a `Link` response header with the "next" relation, cf. RFC 8288. The
+	// URL is the cursor, and the next page is requested from it verbatim,
+	// provided it is on the same host as the base URL.
+	// Detected by ProbePagination.
+	PaginationLinkHeader PaginationType = "link_header"
+
+	// DefaultBookmarkField is the name of the bookmark field in requests and
+	// responses in bookmark pagination if none is configured.
+	DefaultBookmarkField = "bookmark"
+
+	// DefaultSortParam is the name of the query parameter holding the sort
+	// order of stable-sorted entities if none is configured.
+	DefaultSortParam = "sort_by"
+
+	// DefaultCursorResponseField is the name of the response body field
+	// holding the next cursor in cursor pagination if none is configured.
+	DefaultCursorResponseField = "cursor"
+
+	// DefaultCursorQueryParam is the name of the query parameter holding the
+	// cursor in cursor pagination if none is configured.
+	DefaultCursorQueryParam = "cursor"
+
+	// DefaultCursorHeader is the name of the response header holding the
+	// cursor of the next page if none is configured.
+	DefaultCursorHeader = "X-Next-Page"
+)
+
+// AtlassianPagination configures the names of the query parameters and
+// response fields used by Atlassian-style pagination.
+// Fields that are not set default to the names used by Atlassian APIs.
+type AtlassianPagination struct {
+	// StartAtField is the name of the index of the first object of the page.
+	StartAtField string `json:"startAtField,omitempty"`
+
+	// MaxResultsField is the name of the page size.
+	MaxResultsField string `json:"maxResultsField,omitempty"`
+
+	// IsLastField is the name of the response field indicating whether the
+	// page is the last one.
+	IsLastField string `json:"isLastField,omitempty"`
+
+	// TotalField is the name of the response field holding the total number
+	// of objects. Used to detect the last page if IsLastField is not returned.
+	TotalField string `json:"totalField,omitempty"`
+}
+
+// withDefaults returns a copy of the given configuration with the names that
+// are not set replaced by their defaults.
+func (p *AtlassianPagination) withDefaults() AtlassianPagination {
+	names := AtlassianPagination{
+		StartAtField:    "startAt",
+		MaxResultsField: "maxResults",
+		IsLastField:     "isLast",
+		TotalField:      "total",
+	}
+
+	if p == nil {
+		return names
+	}
+
+	if p.StartAtField != "" {
+		names.StartAtField = p.StartAtField
+	}
+
+	if p.MaxResultsField != "" {
+		names.MaxResultsField = p.MaxResultsField
+	}
+
+	if p.IsLastField != "" {
+		names.IsLastField = p.IsLastField
+	}
+
+	if p.TotalField != "" {
+		names.TotalField = p.TotalField
+	}
+
+	return names
+}
+
+// nextAtlassianCursor returns the cursor of the page following the page in the
+// given response fields in Atlassian-style pagination, i.e. the `startAt` of
+// the next page, or an empty string if this is the last page.
+//
+// The page is the last one if the response's `isLast` field is true, or if it
+// is not returned, if the next `startAt` reaches the response's `total`, or if
+// neither is returned, if the page is not full.
+func nextAtlassianCursor(
+	fields map[string]json.RawMessage, names AtlassianPagination, startAt, pageSize int64, objectCount int,
+) (string, error) {
+	if raw, found := fields[names.StartAtField]; found {
+		if err := json.Unmarshal(raw, &startAt); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", names.StartAtField, err)
+		}
+	}
+
+	maxResults := pageSize
+
+	if raw, found := fields[names.MaxResultsField]; found {
+		if err := json.Unmarshal(raw, &maxResults); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", names.MaxResultsField, err)
+		}
+	}
+
+	if maxResults <= 0 {
+		maxResults = int64(objectCount)
+	}
+
+	next := startAt + maxResults
+
+	if raw, found := fields[names.IsLastField]; found {
+		var isLast bool
+		if err := json.Unmarshal(raw, &isLast); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", names.IsLastField, err)
+		}
+
+		if isLast {
+			return "", nil
+		}
+	} else if raw, found := fields[names.TotalField]; found {
+		var total int64
+		if err := json.Unmarshal(raw, &total); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", names.TotalField, err)
+		}
+
+		if next >= total {
+			return "", nil
+		}
+	} else if objectCount == 0 || int64(objectCount) < maxResults {
+		return "", nil
+	}
+
+	if objectCount == 0 {
+		// Guard against looping over the same page forever.
+		return "", nil
+	}
+
+	return strconv.FormatInt(next, 10), nil
+}
+
+// bodyCursor returns the cursor of the next page held in the given field of
+// the response fields, or an empty string if the field is not set, i.e. if this
+// is the last page. String and numeric cursors are supported.
+func bodyCursor(fields map[string]json.RawMessage, field string) (string, error) {
+	raw, found := fields[field]
+	if !found {
+		return "", nil
+	}
+
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", field, err)
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case float64:
+		// Keep the number as sent to avoid any loss of precision.
+		return string(raw), nil
+	default:
+		return "", fmt.Errorf("%s is neither a string nor a number", field)
+	}
+}
+
+// nestedBodyCursor returns the cursor of the next page held at the given
+// object path of the response body, e.g. "pagination.next", or an empty string
+// if the path matches no value, i.e. if this is the last page. String and
+// numeric cursors are supported.
+func nestedBodyCursor(body []byte, path string) (string, error) {
+	steps, err := parseObjectPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // Keep numbers as sent to avoid any loss of precision.
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return "", fmt.Errorf("failed to parse response body: %w", err)
+	}
+
+	nodes := matchObjectPath(document, steps)
+	if len(nodes) == 0 {
+		return "", nil
+	}
+
+	switch value := nodes[0].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	default:
+		return "", fmt.Errorf("%s is neither a string nor a number", path)
+	}
+}
+
+// cursorFromNextURL returns the value of the given query parameter in the
+// given URL of the next page, e.g. the offset "100" in
+// "https://api.example.com/teams?offset=100&limit=50".
+// An empty URL, or one without the parameter, indicates the last page.
+func cursorFromNextURL(nextURL, param string) (string, error) {
+	if nextURL == "" {
+		return "", nil
+	}
+
+	parsed, err := url.Parse(nextURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse next page URL: %w", err)
+	}
+
+	return parsed.Query().Get(param), nil
+}
+
+// nextPageURL returns the URL of the next page to request given as the cursor
+// in link header pagination, which must be an absolute URL with the same
+// scheme and host as the given base URL, so that a datasource cannot direct
+// requests, and their credentials, to other hosts.
+func nextPageURL(baseURL, cursor string) (*url.URL, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base URL: %w", err)
+	}
+
+	next, err := url.Parse(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse next page URL: %w", err)
+	}
+
+	if !next.IsAbs() {
+		return nil, fmt.Errorf("next page URL is not absolute: %s", cursor)
+	}
+
+	if !strings.EqualFold(next.Scheme, base.Scheme) || !strings.EqualFold(next.Host, base.Host) {
+		return nil, fmt.Errorf("next page URL is not on host %s: %s", base.Host, cursor)
+	}
+
+	return next, nil
+}
+
+// nextLinkURL returns the URL of the next page in the given `Link` header
+// values, i.e. the target of the link with the "next" relation, cf. RFC 8288.
+// An empty URL is returned if there is no such link, i.e. on the last page.
+func nextLinkURL(values []string) string {
+	for _, value := range values {
+		for _, link := range splitLinkHeader(value, ',') {
+			params := splitLinkHeader(link, ';')
+
+			target := strings.TrimSpace(params[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range params[1:] {
+				name, relations, _ := strings.Cut(param, "=")
+				if !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+
+				// The relation may be a quoted, space-separated list, e.g. "next last".
+				for _, relation := range strings.Fields(strings.Trim(strings.TrimSpace(relations), `"`)) {
+					if strings.EqualFold(relation, "next") {
+						return strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// splitLinkHeader splits the given `Link` header value around the given
+// separator, ignoring separators within link targets, e.g. commas in
+// "<https://api.example.com/users?fields=id,name>", and within quoted strings.
+func splitLinkHeader(value string, sep byte) []string {
+	var parts []string
+
+	var inTarget, inQuotes bool
+
+	start := 0
+
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case inQuotes && c == '\\':
+			i++
+		case c == '"' && !inTarget:
+			inQuotes = !inQuotes
+		case c == '<' && !inQuotes:
+			inTarget = true
+		case c == '>' && !inQuotes:
+			inTarget = false
+		case c == sep && !inTarget && !inQuotes:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
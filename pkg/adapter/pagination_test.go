@@ -0,0 +1,144 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNextLinkURL(t *testing.T) {
+	tests := map[string]struct {
+		values []string
+		want   string
+	}{
+		"next": {
+			values: []string{`<https://api.example.com/users?page=2>; rel="next"`},
+			want:   "https://api.example.com/users?page=2",
+		},
+		"unquoted_relation": {
+			values: []string{`<https://api.example.com/users?page=2>;rel=next`},
+			want:   "https://api.example.com/users?page=2",
+		},
+		"several_links": {
+			values: []string{
+				`<https://api.example.com/users?page=1>; rel="prev", <https://api.example.com/users?page=3>; rel="next"`,
+			},
+			want: "https://api.example.com/users?page=3",
+		},
+		"several_relations": {
+			values: []string{`<https://api.example.com/users?page=3>; rel="last next"`},
+			want:   "https://api.example.com/users?page=3",
+		},
+		"several_values": {
+			values: []string{
+				`<https://api.example.com/users?page=1>; rel="prev"`,
+				`<https://api.example.com/users?page=3>; rel="next"`,
+			},
+			want: "https://api.example.com/users?page=3",
+		},
+		"comma_in_url": {
+			values: []string{
+				`<https://api.example.com/users?page=1&fields=id,name>; rel="prev", ` +
+					`<https://api.example.com/users?page=3&fields=id,name>; rel="next"`,
+			},
+			want: "https://api.example.com/users?page=3&fields=id,name",
+		},
+		"semicolon_in_url": {
+			values: []string{`<https://api.example.com/users;v=2?page=3>; rel="next"`},
+			want:   "https://api.example.com/users;v=2?page=3",
+		},
+		"comma_in_quoted_param": {
+			values: []string{
+				`<https://api.example.com/users?page=1>; title="first, then; more"; rel="prev", ` +
+					`<https://api.example.com/users?page=3>; rel="next"`,
+			},
+			want: "https://api.example.com/users?page=3",
+		},
+		"no_next": {
+			values: []string{`<https://api.example.com/users?page=1>; rel="prev"`},
+		},
+		"next_in_other_param": {
+			values: []string{`<https://api.example.com/users?page=1>; title="next"`},
+		},
+		"malformed": {
+			values: []string{`https://api.example.com/users?page=3; rel="next"`},
+		},
+		"none": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := nextLinkURL(tt.values); got != tt.want {
+				t.Errorf("Expected %q, got %q.", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCursorFromNextURL(t *testing.T) {
+	tests := map[string]struct {
+		nextURL     string
+		want        string
+		wantMessage string
+	}{
+		"cursor": {
+			nextURL: "https://api.example.com/teams?offset=100&limit=50",
+			want:    "100",
+		},
+		"escaped_cursor": {
+			nextURL: "https://api.example.com/teams?offset=a%2Bb%3D&limit=50",
+			want:    "a+b=",
+		},
+		"relative_url": {
+			nextURL: "/teams?limit=50&offset=100",
+			want:    "100",
+		},
+		// A next URL without the cursor parameter ends the pagination.
+		"missing_cursor": {
+			nextURL: "https://api.example.com/teams?limit=50",
+		},
+		"empty_cursor": {
+			nextURL: "https://api.example.com/teams?offset=&limit=50",
+		},
+		"last_page": {},
+		"invalid_url": {
+			nextURL:     "https://api.example.com:port/teams?offset=100",
+			wantMessage: "failed to parse next page URL",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := cursorFromNextURL(tt.nextURL, "offset")
+
+			if tt.wantMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMessage) {
+					t.Fatalf("Expected error %q, got %v.", tt.wantMessage, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected cursor %q, got %q.", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,579 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+// paginator implements a pagination type: it sets the parameters selecting the
+// requested page in the request to the datasource, then computes the cursor of
+// the next page from the datasource's response. A paginator is created for
+// each page with newPaginator.
+type paginator interface {
+	// buildRequest sets the parameters selecting the requested page.
+	buildRequest(page *pageRequest) *framework.Error
+
+	// nextCursor returns the cursor of the page following the requested one,
+	// or nil if the requested page is the last one.
+	nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error)
+}
+
+// pageRequest holds the parts of a request to the datasource which select the
+// requested page.
+type pageRequest struct {
+	request *Request
+
+	// cursor is the cursor of the requested page, unwrapped from the composite
+	// cursor if any.
+	cursor string
+
+	// composite is the composite cursor of the requested page, or nil if the
+	// request doesn't use one.
+	composite *compositeCursor
+
+	// query holds the query parameters of the request.
+	query url.Values
+
+	// method is the HTTP method of the request.
+	method string
+
+	// pageSizeParam is the name of the query parameter holding the page size.
+	pageSizeParam string
+
+	// cursorParam is the name of the query parameter holding the cursor, from
+	// which the cursor is also extracted from the URLs of next pages.
+	cursorParam string
+
+	// bodyFields are set in the JSON body of POST-query requests, over the
+	// query parameters.
+	bodyFields map[string]any
+
+	// url is the URL from which the page is requested instead of the entity's
+	// URL, if set.
+	url *url.URL
+
+	// cookie is sent with the request, if set.
+	cookie *http.Cookie
+}
+
+// newPageRequest returns the pageRequest of the page with the given cursor,
+// whose query parameters are initialized with the given ones.
+func newPageRequest(request *Request, cursor string, composite *compositeCursor, query url.Values) *pageRequest {
+	method := http.MethodGet
+	if request.HTTPMethod != "" {
+		method = request.HTTPMethod
+	}
+
+	cursorParam := "offset"
+	if request.CursorQueryParam != "" {
+		cursorParam = request.CursorQueryParam
+	}
+
+	return &pageRequest{
+		request:       request,
+		cursor:        cursor,
+		composite:     composite,
+		query:         query,
+		method:        method,
+		pageSizeParam: "limit",
+		cursorParam:   cursorParam,
+	}
+}
+
+// addCursorParam adds the cursor of the requested page, if any, to the query
+// parameters.
+func (page *pageRequest) addCursorParam() {
+	if page.cursor != "" {
+		page.query.Add(page.cursorParam, page.cursor)
+	}
+}
+
+// pageResponse holds the parts of a response of the datasource from which the
+// cursor of the next page is computed.
+type pageResponse struct {
+	header  http.Header
+	cookies []*http.Cookie
+
+	// url is the URL from which the page was requested, against which
+	// relative URLs of the next page are resolved.
+	url *url.URL
+
+	// body is the response body, or "{}" for a response without content.
+	body []byte
+
+	// fields are the pagination fields of the response body.
+	fields *DatasourceResponse
+
+	// objectCount is the number of objects in the page, before failed
+	// multi-status elements and objects returned from previous sources are
+	// dropped.
+	objectCount int
+
+	// returnedCount is the number of objects returned for the page.
+	returnedCount int
+}
+
+// newPaginator returns the paginator of the request's pagination type.
+func newPaginator(request *Request) paginator {
+	switch {
+	case request.HybridPagination != "":
+		return hybridPaginator{}
+	case request.CursorCookie != "":
+		return cookiePaginator{}
+	}
+
+	switch request.PaginationMode {
+	case PaginationCursor:
+		return cursorPaginator{}
+	case PaginationHeader:
+		return headerPaginator{}
+	case PaginationLinkHeader:
+		return linkHeaderPaginator{}
+	case PaginationAtlassian:
+		return &atlassianPaginator{names: request.AtlassianPagination.withDefaults()}
+	case PaginationBookmark:
+		return bookmarkPaginator{}
+	default:
+		return offsetPaginator{}
+	}
+}
+
+// tokenCursor returns the cursor holding the given continuation token, or nil
+// if the token is empty, i.e. on the last page.
+func tokenCursor(token string) *compositeCursor {
+	if token == "" {
+		return nil
+	}
+
+	return &compositeCursor{Token: token}
+}
+
+// offsetPaginator implements PaginationOffset, the default pagination type.
+// The cursor is sent in the `offset` query parameter, or CursorQueryParam.
+type offsetPaginator struct{}
+
+func (offsetPaginator) buildRequest(page *pageRequest) *framework.Error {
+	request := page.request
+
+	// Unless a custom parameter is configured, the cursor is an offset. A
+	// malformed one, e.g. from a corrupted state store, is rejected here
+	// rather than by the datasource.
+	if page.cursor != "" && request.usesOffsetPagination() && request.CursorQueryParam == "" &&
+		!validOffset(page.cursor) {
+		return &framework.Error{
+			Message: fmt.Sprintf("Cursor is not a valid offset: %s.", page.cursor),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		}
+	}
+
+	page.addCursorParam()
+
+	return nil
+}
+
+func (offsetPaginator) nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	cursor, err := nextOffsetToken(page, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenCursor(cursor), nil
+}
+
+// nextOffsetToken returns the cursor of the page following the requested one
+// in offset pagination, or an empty string on the last page.
+//
+// The cursor is read from the cursor header, or else derived from the
+// `offset`, `limit` and `more` fields of the response body. It is read from
+// CursorResponseField or NextCursorJSONPath instead if either is configured,
+// and extracted from the URL of the next page if CursorFromNextURL is set.
+func nextOffsetToken(page *pageRequest, res *pageResponse) (string, *framework.Error) {
+	request := page.request
+
+	cursor, err := headerCursor(page, res)
+	if err != nil {
+		return "", err
+	}
+
+	if cursor == "" && res.fields.More {
+		// Datasources that don't echo the offset of the page are assumed to
+		// have returned the requested one, rather than the first page, which
+		// would otherwise be requested again after the second page.
+		offset := int64(res.fields.Offset)
+		if offset == 0 && validOffset(page.cursor) {
+			offset, _ = strconv.ParseInt(page.cursor, 10, 64)
+		}
+
+		if step := effectivePageSize(int64(res.objectCount), res.fields.Limit); step > 0 {
+			cursor = strconv.FormatInt(offset+step, 10)
+		}
+	}
+
+	if request.CursorResponseField != "" {
+		if cursor, err = responseFieldCursor(res, request.CursorResponseField); err != nil {
+			return "", err
+		}
+	}
+
+	if request.NextCursorJSONPath != "" {
+		if cursor, err = nestedResponseCursor(res, request.NextCursorJSONPath); err != nil {
+			return "", err
+		}
+	}
+
+	return nextURLCursor(page, cursor)
+}
+
+// cursorPaginator implements PaginationCursor. The cursor is read from
+// CursorResponseField or NextCursorJSONPath, or else from
+// DefaultCursorResponseField, and sent in CursorQueryParam, or else in
+// DefaultCursorQueryParam.
+type cursorPaginator struct{}
+
+func (cursorPaginator) buildRequest(page *pageRequest) *framework.Error {
+	if page.request.CursorQueryParam == "" {
+		page.cursorParam = DefaultCursorQueryParam
+	}
+
+	page.addCursorParam()
+
+	return nil
+}
+
+func (cursorPaginator) nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	request := page.request
+
+	var cursor string
+
+	var err *framework.Error
+
+	if request.NextCursorJSONPath != "" {
+		cursor, err = nestedResponseCursor(res, request.NextCursorJSONPath)
+	} else {
+		field := request.CursorResponseField
+		if field == "" {
+			field = DefaultCursorResponseField
+		}
+
+		cursor, err = responseFieldCursor(res, field)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor, err = nextURLCursor(page, cursor); err != nil {
+		return nil, err
+	}
+
+	return tokenCursor(cursor), nil
+}
+
+// headerPaginator implements PaginationHeader. The cursor is read from the
+// cursor header only, and sent like in offset pagination.
+type headerPaginator struct{}
+
+func (headerPaginator) buildRequest(page *pageRequest) *framework.Error {
+	page.addCursorParam()
+
+	return nil
+}
+
+func (headerPaginator) nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	cursor, err := headerCursor(page, res)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor, err = nextURLCursor(page, cursor); err != nil {
+		return nil, err
+	}
+
+	return tokenCursor(cursor), nil
+}
+
+// linkHeaderPaginator implements PaginationLinkHeader. The cursor is the URL of
+// the next page, from which the page is requested verbatim.
+type linkHeaderPaginator struct{}
+
+func (linkHeaderPaginator) buildRequest(page *pageRequest) *framework.Error {
+	if page.cursor == "" {
+		return nil
+	}
+
+	// The URL of the next page holds all the query parameters.
+	url, err := nextPageURL(page.request.BaseURL, page.cursor)
+	if err != nil {
+		return &framework.Error{
+			Message: fmt.Sprintf("Cursor is not a valid next page URL: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+		}
+	}
+
+	page.url = url
+
+	return nil
+}
+
+func (linkHeaderPaginator) nextCursor(_ *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	nextURL := nextLinkURL(res.header.Values("Link"))
+	if nextURL == "" {
+		return nil, nil
+	}
+
+	// The URL may be relative to the URL of the current page.
+	next, err := res.url.Parse(nextURL)
+	if err != nil {
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to parse next page URL from Link header: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return tokenCursor(next.String()), nil
+}
+
+// atlassianPaginator implements PaginationAtlassian.
+type atlassianPaginator struct {
+	names AtlassianPagination
+
+	// startAt is the index of the first object of the requested page.
+	startAt int64
+}
+
+func (p *atlassianPaginator) buildRequest(page *pageRequest) *framework.Error {
+	page.pageSizeParam = p.names.MaxResultsField
+
+	if page.cursor != "" {
+		startAt, err := strconv.ParseInt(page.cursor, 10, 64)
+		if err != nil || startAt < 0 {
+			return &framework.Error{
+				Message: fmt.Sprintf("Cursor is not a valid %s: %s.", p.names.StartAtField, page.cursor),
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_PAGE_REQUEST_CONFIG,
+			}
+		}
+
+		p.startAt = startAt
+	}
+
+	page.query.Add(p.names.StartAtField, strconv.FormatInt(p.startAt, 10))
+
+	return nil
+}
+
+func (p *atlassianPaginator) nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(res.body, &fields)
+
+	var cursor string
+	if err == nil {
+		cursor, err = nextAtlassianCursor(fields, p.names, p.startAt, page.request.PageSize, res.objectCount)
+	}
+
+	if err != nil {
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to parse pagination fields in response body: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return tokenCursor(cursor), nil
+}
+
+// bookmarkPaginator implements PaginationBookmark. The bookmark can only be
+// sent in the request body, so pages are requested with POST.
+type bookmarkPaginator struct{}
+
+// bookmarkField returns the name of the request's bookmark field.
+func bookmarkField(request *Request) string {
+	if request.BookmarkField != "" {
+		return request.BookmarkField
+	}
+
+	return DefaultBookmarkField
+}
+
+func (bookmarkPaginator) buildRequest(page *pageRequest) *framework.Error {
+	page.method = http.MethodPost
+
+	// The bookmark is opaque, so it's set after the conversion of numbers.
+	if page.cursor != "" {
+		page.bodyFields = map[string]any{bookmarkField(page.request): page.cursor}
+	}
+
+	return nil
+}
+
+func (bookmarkPaginator) nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(res.body, &fields)
+
+	var cursor string
+	if err == nil {
+		cursor, err = bodyCursor(fields, bookmarkField(page.request))
+	}
+
+	if err != nil {
+		return nil, &framework.Error{
+			Message: fmt.Sprintf("Failed to parse bookmark in response body: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	// The datasource keeps returning a bookmark after the last object.
+	if res.returnedCount == 0 {
+		return nil, nil
+	}
+
+	return tokenCursor(cursor), nil
+}
+
+// hybridPaginator implements HybridPagination: both the cursor of offset
+// pagination and the computed offset of the next page are carried in the
+// cursor, and one of them is sent in the `offset` query parameter.
+type hybridPaginator struct{}
+
+func (hybridPaginator) buildRequest(page *pageRequest) *framework.Error {
+	if page.request.HybridPagination == HybridPaginationOffset {
+		if page.composite.Offset > 0 {
+			page.query.Add("offset", strconv.FormatInt(page.composite.Offset, 10))
+		}
+
+		return nil
+	}
+
+	page.addCursorParam()
+
+	return nil
+}
+
+func (hybridPaginator) nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	token, err := nextOffsetToken(page, res)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := effectivePageSize(page.request.PageSize, res.fields.Limit)
+
+	return nextHybridCursor(page.composite, token, pageSize, res.objectCount), nil
+}
+
+// cookiePaginator implements cookie pagination: the continuation cookie set by
+// the datasource is carried in the cursor and sent back as a cookie.
+type cookiePaginator struct{}
+
+func (cookiePaginator) buildRequest(page *pageRequest) *framework.Error {
+	if page.composite.Cookie != "" {
+		page.cookie = &http.Cookie{
+			Name:  page.request.CursorCookie,
+			Value: page.composite.Cookie,
+		}
+	}
+
+	return nil
+}
+
+func (cookiePaginator) nextCursor(page *pageRequest, res *pageResponse) (*compositeCursor, *framework.Error) {
+	return nextCookieCursor(res.cookies, page.request.CursorCookie), nil
+}
+
+// headerCursor returns the cursor held in the request's cursor header. A `Link`
+// cursor header holds the URL of the next page, from which the cursor is
+// extracted.
+func headerCursor(page *pageRequest, res *pageResponse) (string, *framework.Error) {
+	cursorHeader := DefaultCursorHeader
+	if page.request.CursorHeader != "" {
+		cursorHeader = page.request.CursorHeader
+	}
+
+	if !strings.EqualFold(cursorHeader, "Link") {
+		return res.header.Get(cursorHeader), nil
+	}
+
+	cursor, err := cursorFromNextURL(nextLinkURL(res.header.Values("Link")), page.cursorParam)
+	if err != nil {
+		return "", &framework.Error{
+			Message: fmt.Sprintf("Failed to extract cursor from Link header: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return cursor, nil
+}
+
+// responseFieldCursor returns the cursor held in the given top-level field of
+// the response body.
+func responseFieldCursor(res *pageResponse, field string) (string, *framework.Error) {
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(res.body, &fields)
+
+	var cursor string
+	if err == nil {
+		cursor, err = bodyCursor(fields, field)
+	}
+
+	if err != nil {
+		return "", &framework.Error{
+			Message: fmt.Sprintf("Failed to parse cursor field in response body: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return cursor, nil
+}
+
+// nestedResponseCursor returns the cursor held at the given object path of the
+// response body.
+func nestedResponseCursor(res *pageResponse, path string) (string, *framework.Error) {
+	cursor, err := nestedBodyCursor(res.body, path)
+	if err != nil {
+		return "", &framework.Error{
+			Message: fmt.Sprintf("Failed to parse cursor in response body: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return cursor, nil
+}
+
+// nextURLCursor returns the given cursor, or the cursor extracted from it if
+// it is the URL of the next page, i.e. if CursorFromNextURL is set.
+func nextURLCursor(page *pageRequest, cursor string) (string, *framework.Error) {
+	if !page.request.CursorFromNextURL {
+		return cursor, nil
+	}
+
+	cursor, err := cursorFromNextURL(cursor, page.cursorParam)
+	if err != nil {
+		return "", &framework.Error{
+			Message: fmt.Sprintf("Failed to extract cursor from next page URL: %v.", err),
+			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestNewPaginator(t *testing.T) {
+	tests := map[string]struct {
+		request *Request
+		want    string
+	}{
+		"default": {
+			request: &Request{},
+			want:    "adapter.offsetPaginator",
+		},
+		"offset": {
+			request: &Request{PaginationMode: PaginationOffset},
+			want:    "adapter.offsetPaginator",
+		},
+		"cursor": {
+			request: &Request{PaginationMode: PaginationCursor},
+			want:    "adapter.cursorPaginator",
+		},
+		"header": {
+			request: &Request{PaginationMode: PaginationHeader},
+			want:    "adapter.headerPaginator",
+		},
+		"link_header": {
+			request: &Request{PaginationMode: PaginationLinkHeader},
+			want:    "adapter.linkHeaderPaginator",
+		},
+		"atlassian": {
+			request: &Request{PaginationMode: PaginationAtlassian},
+			want:    "*adapter.atlassianPaginator",
+		},
+		"bookmark": {
+			request: &Request{PaginationMode: PaginationBookmark},
+			want:    "adapter.bookmarkPaginator",
+		},
+		"hybrid": {
+			request: &Request{HybridPagination: HybridPaginationOffset},
+			want:    "adapter.hybridPaginator",
+		},
+		"cookie": {
+			request: &Request{CursorCookie: "session"},
+			want:    "adapter.cookiePaginator",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := fmt.Sprintf("%T", newPaginator(tt.request)); got != tt.want {
+				t.Errorf("Expected paginator %s, got %s.", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPaginatorBuildRequest(t *testing.T) {
+	tests := map[string]struct {
+		request    *Request
+		wantQuery  string
+		wantMethod string
+		wantCookie string
+		wantErr    bool
+	}{
+		"offset_first_page": {
+			request:    &Request{},
+			wantQuery:  "",
+			wantMethod: http.MethodGet,
+		},
+		"offset_start_cursor": {
+			request:    &Request{StartCursor: "40"},
+			wantQuery:  "offset=40",
+			wantMethod: http.MethodGet,
+		},
+		"offset_cursor_over_start_cursor": {
+			request:    &Request{Cursor: "80", StartCursor: "40"},
+			wantQuery:  "offset=80",
+			wantMethod: http.MethodGet,
+		},
+		"offset_invalid_cursor": {
+			request: &Request{Cursor: "abc"},
+			wantErr: true,
+		},
+		"offset_custom_query_param": {
+			request:    &Request{Cursor: "abc", CursorQueryParam: "after"},
+			wantQuery:  "after=abc",
+			wantMethod: http.MethodGet,
+		},
+		"cursor_default_query_param": {
+			request:    &Request{Cursor: "abc", PaginationMode: PaginationCursor},
+			wantQuery:  DefaultCursorQueryParam + "=abc",
+			wantMethod: http.MethodGet,
+		},
+		"hybrid_offset_start_cursor": {
+			request:    &Request{HybridPagination: HybridPaginationOffset, StartCursor: "40"},
+			wantQuery:  "offset=40",
+			wantMethod: http.MethodGet,
+		},
+		"hybrid_offset_start_cursor_with_sources": {
+			request: &Request{
+				HybridPagination: HybridPaginationOffset,
+				StartCursor:      "40",
+				Sources:          []string{"/users", "/groups"},
+			},
+			wantQuery:  "offset=40",
+			wantMethod: http.MethodGet,
+		},
+		"hybrid_header_start_cursor": {
+			request:    &Request{HybridPagination: HybridPaginationHeader, StartCursor: "abc"},
+			wantQuery:  "offset=abc",
+			wantMethod: http.MethodGet,
+		},
+		"cookie_start_cursor": {
+			request:    &Request{CursorCookie: "session", StartCursor: "abc"},
+			wantQuery:  "",
+			wantMethod: http.MethodGet,
+			wantCookie: "abc",
+		},
+		"atlassian_first_page": {
+			request:    &Request{PaginationMode: PaginationAtlassian},
+			wantQuery:  "startAt=0",
+			wantMethod: http.MethodGet,
+		},
+		"atlassian_invalid_cursor": {
+			request: &Request{PaginationMode: PaginationAtlassian, Cursor: "abc"},
+			wantErr: true,
+		},
+		"bookmark": {
+			request:    &Request{PaginationMode: PaginationBookmark, Cursor: "abc"},
+			wantQuery:  "",
+			wantMethod: http.MethodPost,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cursor, composite, err := requestedCursor(tt.request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v.", err)
+			}
+
+			page := newPageRequest(tt.request, cursor, composite, url.Values{})
+
+			adapterErr := newPaginator(tt.request).buildRequest(page)
+			if tt.wantErr {
+				if adapterErr == nil {
+					t.Fatal("Expected an error, got nil.")
+				}
+
+				return
+			}
+
+			if adapterErr != nil {
+				t.Fatalf("Unexpected error: %v.", adapterErr.Message)
+			}
+
+			if got := page.query.Encode(); got != tt.wantQuery {
+				t.Errorf("Expected query %q, got %q.", tt.wantQuery, got)
+			}
+
+			if page.method != tt.wantMethod {
+				t.Errorf("Expected method %s, got %s.", tt.wantMethod, page.method)
+			}
+
+			var gotCookie string
+			if page.cookie != nil {
+				gotCookie = page.cookie.Value
+			}
+
+			if gotCookie != tt.wantCookie {
+				t.Errorf("Expected cookie %q, got %q.", tt.wantCookie, gotCookie)
+			}
+		})
+	}
+}
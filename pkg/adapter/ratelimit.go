@@ -0,0 +1,82 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// epochResetThreshold is the value above which a rate limit reset header
+	// is interpreted as a Unix timestamp rather than a number of seconds.
+	epochResetThreshold = 1_000_000_000
+)
+
+// RateLimitInfo is a snapshot of the datasource's rate limit state, as returned
+// in the response headers, which callers can use to pace future requests.
+type RateLimitInfo struct {
+	// Remaining is the number of requests remaining in the current window.
+	// Nil if not returned by the datasource.
+	Remaining *int64
+
+	// Reset is the time at which the current window resets.
+	// Zero if not returned by the datasource.
+	Reset time.Time
+}
+
+// parseRateLimit returns the rate limit state in the given response headers,
+// or nil if none of the remaining and reset headers are set or valid.
+//
+// The headers are read either from the X-RateLimit-Remaining and
+// X-RateLimit-Reset headers, or from the RateLimit-Remaining and
+// RateLimit-Reset headers returned by PagerDuty. The reset header may be
+// either a number of seconds or a Unix timestamp.
+func parseRateLimit(header http.Header, now time.Time) *RateLimitInfo {
+	var info RateLimitInfo
+
+	remainingHeader := firstHeader(header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	if remaining, err := strconv.ParseInt(remainingHeader, 10, 64); err == nil {
+		info.Remaining = &remaining
+	}
+
+	resetHeader := firstHeader(header, "X-RateLimit-Reset", "RateLimit-Reset")
+	if reset, err := strconv.ParseInt(resetHeader, 10, 64); err == nil {
+		if reset >= epochResetThreshold {
+			info.Reset = time.Unix(reset, 0)
+		} else {
+			info.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	if info.Remaining == nil && info.Reset.IsZero() {
+		return nil
+	}
+
+	return &info
+}
+
+// firstHeader returns the value of the first of the given headers that is set,
+// or "" if none is.
+func firstHeader(header http.Header, names ...string) string {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
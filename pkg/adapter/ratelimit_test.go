@@ -0,0 +1,108 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	remaining := func(n int64) *int64 {
+		return &n
+	}
+
+	tests := map[string]struct {
+		header http.Header
+		want   *RateLimitInfo
+	}{
+		"x_rate_limit_headers": {
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"42"},
+				"X-Ratelimit-Reset":     {"30"},
+			},
+			want: &RateLimitInfo{Remaining: remaining(42), Reset: now.Add(30 * time.Second)},
+		},
+		"pagerduty_headers": {
+			header: http.Header{
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"5"},
+			},
+			want: &RateLimitInfo{Remaining: remaining(0), Reset: now.Add(5 * time.Second)},
+		},
+		"epoch_reset": {
+			header: http.Header{
+				"X-Ratelimit-Reset": {"1685621400"},
+			},
+			want: &RateLimitInfo{Reset: time.Unix(1685621400, 0)},
+		},
+		"remaining_only": {
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"7"},
+			},
+			want: &RateLimitInfo{Remaining: remaining(7)},
+		},
+		"x_rate_limit_headers_take_precedence": {
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"1"},
+				"Ratelimit-Remaining":   {"2"},
+			},
+			want: &RateLimitInfo{Remaining: remaining(1)},
+		},
+		"invalid_headers": {
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"many"},
+				"X-Ratelimit-Reset":     {"soon"},
+			},
+		},
+		"no_headers": {
+			header: http.Header{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := parseRateLimit(tt.header, now)
+
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("Expected no rate limit, got %+v.", got)
+				}
+
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Expected rate limit %+v, got none.", tt.want)
+			}
+
+			switch {
+			case tt.want.Remaining == nil && got.Remaining != nil:
+				t.Errorf("Expected no remaining requests, got %d.", *got.Remaining)
+			case tt.want.Remaining != nil && got.Remaining == nil:
+				t.Errorf("Expected %d remaining requests, got none.", *tt.want.Remaining)
+			case tt.want.Remaining != nil && *got.Remaining != *tt.want.Remaining:
+				t.Errorf("Expected %d remaining requests, got %d.", *tt.want.Remaining, *got.Remaining)
+			}
+
+			if !got.Reset.Equal(tt.want.Reset) {
+				t.Errorf("Expected reset at %v, got %v.", tt.want.Reset, got.Reset)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+const (
+	// DefaultRetryBaseDelay is the delay before the first retry of a failed
+	// request if none is configured. The delay doubles for every subsequent
+	// retry.
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+
+	// dnsRetries is the number of times a request is retried when the
+	// datasource's host name fails to resolve, independently of the retries
+	// configured for failed requests.
+	dnsRetries = 2
+
+	// dnsRetryDelay is the delay before retrying a request whose host name
+	// failed to resolve.
+	dnsRetryDelay = 100 * time.Millisecond
+)
+
+// RetriesExhausted returns the number of requests to the datasource that failed
+// after all retries were exhausted since the Datasource was created. Each such
+// request is also reported to the Datasource's MetricsRecorder.
+func (d *Datasource) RetriesExhausted() int64 {
+	return d.retriesExhausted.Load()
+}
+
+// isRetryableStatus returns whether a request that failed with the given
+// HTTP status code is transient and may succeed if retried.
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// doWithRetries sends the given request to the datasource, and retries it up to
+// maxRetries times if it fails with a transport error, e.g. a timeout, or a
+// retryable status code. Retries are delayed as requested by the datasource in
+// the Retry-After response header, or else with exponential backoff from
+// baseDelay.
+//
+// Each attempt times out after the given timeout, and the whole retry loop is
+// bounded by ctx: retries stop early if the next attempt would start after the
+// deadline of ctx. If all attempts fail, the returned error reports the number
+// of attempts made and the last status code or error, and the exhausted retries
+// are reported in metrics.
+//
+// The attempt's timeout keeps running while the returned response's body is
+// read, and is released when the body is closed.
+func (d *Datasource) doWithRetries(
+	ctx context.Context, client *http.Client, req *http.Request, timeout time.Duration, maxRetries int,
+	baseDelay time.Duration, metrics *RequestMetrics,
+) (*http.Response, *framework.Error) {
+	if baseDelay <= 0 {
+		baseDelay = DefaultRetryBaseDelay
+	}
+
+	var lastErr string
+
+	// retryAfter is the delay requested by the datasource before the next
+	// attempt, if any.
+	var retryAfter time.Duration
+
+	attempts := 0
+
+	for attempts <= maxRetries {
+		if attempts > 0 {
+			delay := baseDelay << (attempts - 1)
+			if retryAfter > 0 {
+				delay = retryAfter
+			}
+
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+				break
+			}
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, abortedRequestError(ctx, false, "waiting to retry the request")
+			}
+		}
+
+		attemptReq := req
+
+		if attempts > 0 {
+			var err error
+
+			attemptReq, err = rewoundRequest(req)
+			if err != nil {
+				return nil, &framework.Error{
+					Message: fmt.Sprintf("Failed to rewind request body for retry: %v.", err),
+					Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+				}
+			}
+		}
+
+		attempts++
+
+		retryAfter = 0
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+
+		res, err := doResolvingDNS(attemptCtx, client, attemptReq.WithContext(attemptCtx))
+		if err != nil {
+			timedOut := attemptCtx.Err() != nil
+
+			cancel()
+
+			switch {
+			case ctx.Err() != nil:
+				return nil, abortedRequestError(ctx, false, "sending the request")
+			case timedOut && maxRetries == 0:
+				return nil, abortedRequestError(ctx, true, "sending the request")
+			case timedOut:
+				lastErr = fmt.Sprintf("timed out after %v", timeout)
+			default:
+				lastErr = err.Error()
+			}
+
+			continue
+		}
+
+		// Without retries, the response is returned as is whatever its status.
+		if maxRetries == 0 || !isRetryableStatus(res.StatusCode) {
+			res.Body = &attemptBody{ReadCloser: res.Body, ctx: attemptCtx, cancel: cancel}
+
+			return res, nil
+		}
+
+		lastErr = fmt.Sprintf("status code %d", res.StatusCode)
+		retryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+
+		// Drain the body so that the connection can be reused for the retry.
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		cancel()
+	}
+
+	if maxRetries > 0 {
+		d.retriesExhausted.Add(1)
+
+		metrics.RetriesExhausted = true
+	}
+
+	return nil, retriesFailedError(attempts, lastErr)
+}
+
+// attemptBody is the body of the response to an attempt of doWithRetries,
+// which releases the attempt's timeout when closed.
+type attemptBody struct {
+	io.ReadCloser
+
+	// ctx is the context of the attempt, bounded by its timeout.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (b *attemptBody) Close() error {
+	defer b.cancel()
+
+	return b.ReadCloser.Close()
+}
+
+// attemptTimedOut returns whether the attempt of doWithRetries which returned
+// the given response timed out, e.g. while its body was read.
+func attemptTimedOut(res *http.Response) bool {
+	body, ok := res.Body.(*attemptBody)
+
+	return ok && body.ctx.Err() == context.DeadlineExceeded
+}
+
+// parseRetryAfter returns the delay requested in the given Retry-After header
+// value, either in seconds or as an HTTP date, or 0 if the value is not set or
+// invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+// doResolvingDNS sends the given request, and retries it up to dnsRetries
+// times after a short delay if the datasource's host name fails to resolve,
+// since DNS failures are often transient in containerized environments.
+func doResolvingDNS(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	attemptReq := req
+
+	for attempt := 0; ; attempt++ {
+		res, err := client.Do(attemptReq)
+
+		var dnsErr *net.DNSError
+		if err == nil || attempt >= dnsRetries || !errors.As(err, &dnsErr) {
+			return res, err
+		}
+
+		select {
+		case <-time.After(dnsRetryDelay):
+		case <-ctx.Done():
+			return nil, err
+		}
+
+		if attemptReq, err = rewoundRequest(req); err != nil {
+			return nil, fmt.Errorf("failed to rewind request body for retry: %w", err)
+		}
+	}
+}
+
+// rewoundRequest returns a copy of the given request with a new body, to send
+// the request again after its body was consumed.
+func rewoundRequest(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	rewound := req.Clone(req.Context())
+	rewound.Body = body
+
+	return rewound, nil
+}
+
+// retriesFailedError returns the error returned when a request to the
+// datasource failed after the given number of attempts.
+func retriesFailedError(attempts int, lastErr string) *framework.Error {
+	return &framework.Error{
+		Message: fmt.Sprintf("Failed to send request to datasource after %d attempt(s), last error: %s.",
+			attempts, lastErr),
+		Code: api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+	}
+}
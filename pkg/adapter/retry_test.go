@@ -0,0 +1,365 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDatasourceGetPageRetries(t *testing.T) {
+	tests := map[string]struct {
+		// failures is the number of 429 responses before a 200.
+		failures      int
+		maxRetries    int
+		parentTimeout time.Duration
+		wantAttempts  int64
+		wantMessage   string
+	}{
+		// The Retry-After delay exceeds the request timeout, which only bounds
+		// each attempt.
+		"429_twice_then_200": {
+			failures:     2,
+			maxRetries:   2,
+			wantAttempts: 3,
+		},
+		"retries_exhausted": {
+			failures:     3,
+			maxRetries:   1,
+			wantAttempts: 2,
+			wantMessage:  "after 2 attempt(s), last error: status code 429",
+		},
+		// The caller's deadline bounds the retry loop.
+		"retry_after_past_caller_deadline": {
+			failures:      2,
+			maxRetries:    2,
+			parentTimeout: 500 * time.Millisecond,
+			wantAttempts:  1,
+			wantMessage:   "after 1 attempt(s), last error: status code 429",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var attempts atomic.Int64
+
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				if attempts.Add(1) <= int64(tt.failures) {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusTooManyRequests)
+
+					return
+				}
+
+				w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+			})
+
+			ctx := context.Background()
+
+			if tt.parentTimeout > 0 {
+				var cancel context.CancelFunc
+
+				ctx, cancel = context.WithTimeout(ctx, tt.parentTimeout)
+				defer cancel()
+			}
+
+			request := newTestDatasourceRequest(server)
+			request.MaxRetries = tt.maxRetries
+			request.RequestTimeout = 200 * time.Millisecond
+
+			response, err := NewClient(10).GetPage(ctx, request)
+
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d.", tt.wantAttempts, got)
+			}
+
+			if tt.wantMessage != "" {
+				if err == nil || !strings.Contains(err.Message, tt.wantMessage) {
+					t.Fatalf("Expected error %q, got %+v.", tt.wantMessage, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %v.", response.Objects)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageRetriesTimeout(t *testing.T) {
+	var attempts atomic.Int64
+
+	release := make(chan struct{})
+
+	// The first attempt hangs until it times out, the second one succeeds.
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+
+			return
+		}
+
+		w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+	})
+	defer close(release)
+
+	request := newTestDatasourceRequest(server)
+	request.MaxRetries = 1
+	request.RetryBaseDelay = 10 * time.Millisecond
+	request.RequestTimeout = 100 * time.Millisecond
+
+	if _, err := NewClient(10).GetPage(context.Background(), request); err != nil {
+		t.Fatalf("Expected the timed out attempt to be retried, got %+v.", err)
+	}
+
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("Expected 2 attempts, got %d.", got)
+	}
+}
+
+// failingResolverDialer dials connections for an http.Transport. The first
+// failures connections resolve the datasource's host name with a resolver
+// which cannot reach any DNS server, so they fail as when DNS is unavailable.
+// The others are connected to addr.
+type failingResolverDialer struct {
+	failures int64
+	addr     string
+	attempts atomic.Int64
+}
+
+func (d *failingResolverDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.attempts.Add(1) <= d.failures {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(context.Context, string, string) (net.Conn, error) {
+				return nil, errors.New("DNS server unreachable")
+			},
+		}
+
+		return (&net.Dialer{Resolver: resolver}).DialContext(ctx, network, address)
+	}
+
+	return (&net.Dialer{}).DialContext(ctx, network, d.addr)
+}
+
+// newFailingResolverTransport returns a transport which connects with the
+// given dialer.
+func newFailingResolverTransport(dialer *failingResolverDialer) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	return transport
+}
+
+// newUnresolvedTestDatasourceRequest returns a request to the given test server
+// through a host name which is only known to the test, so that it must be
+// resolved before each connection.
+func newUnresolvedTestDatasourceRequest(t *testing.T, server *httptest.Server) *Request {
+	t.Helper()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse server URL: %v.", err)
+	}
+
+	request := newTestDatasourceRequest(server)
+	request.BaseURL = "http://datasource.test:" + serverURL.Port()
+
+	return request
+}
+
+func TestDatasourceGetPageDNSRetries(t *testing.T) {
+	// A closed port, to which connections are refused.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v.", err)
+	}
+
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	tests := map[string]struct {
+		failures     int64
+		wantAttempts int64
+	}{
+		"dns_error": {
+			failures:     dnsRetries + 1,
+			wantAttempts: dnsRetries + 1,
+		},
+		"other_error": {
+			wantAttempts: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+			})
+
+			dialer := &failingResolverDialer{failures: tt.failures, addr: closedAddr}
+
+			request := newUnresolvedTestDatasourceRequest(t, server)
+
+			client := NewClientWithTransport(5, newFailingResolverTransport(dialer))
+
+			if _, err := client.GetPage(context.Background(), request); err == nil {
+				t.Fatal("Expected an error, got none.")
+			}
+
+			if got := dialer.attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d.", tt.wantAttempts, got)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageDNSRetriesRecover(t *testing.T) {
+	tests := map[string]struct {
+		failures     int64
+		maxRetries   int
+		method       string
+		wantAttempts int64
+		wantErr      bool
+	}{
+		"one_failure": {
+			failures:     1,
+			wantAttempts: 2,
+		},
+		"dns_retries_exhausted": {
+			failures:     dnsRetries + 1,
+			wantAttempts: dnsRetries + 1,
+			wantErr:      true,
+		},
+		// The DNS retries have their own budget, after which the request is
+		// retried by the general retry logic.
+		"general_retry_after_dns_retries": {
+			failures:     dnsRetries + 1,
+			maxRetries:   1,
+			wantAttempts: dnsRetries + 2,
+		},
+		"post_body_resent": {
+			failures:     dnsRetries,
+			method:       http.MethodPost,
+			wantAttempts: dnsRetries + 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					body, _ := io.ReadAll(r.Body)
+					if !strings.Contains(string(body), `"limit":10`) {
+						t.Errorf("Expected the request body to be resent, got %q.", body)
+					}
+				}
+
+				w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+			})
+
+			dialer := &failingResolverDialer{failures: tt.failures, addr: server.Listener.Addr().String()}
+
+			request := newUnresolvedTestDatasourceRequest(t, server)
+			request.MaxRetries = tt.maxRetries
+			request.RetryBaseDelay = time.Millisecond
+			request.HTTPMethod = tt.method
+
+			client := NewClientWithTransport(5, newFailingResolverTransport(dialer))
+
+			response, err := client.GetPage(context.Background(), request)
+
+			if got := dialer.attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d.", tt.wantAttempts, got)
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error, got none.")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %d.", len(response.Objects))
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageRetriesExhausted(t *testing.T) {
+	var attempts atomic.Int64
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	metrics := &recordingMetrics{}
+
+	client := NewClient(5).(*Datasource)
+	client.Metrics = metrics
+
+	request := newTestDatasourceRequest(server)
+	request.MaxRetries = 1
+
+	_, err := client.GetPage(context.Background(), request)
+	if err == nil {
+		t.Fatal("Expected an error, got none.")
+	}
+
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("Expected 2 attempts, got %d.", got)
+	}
+
+	// The error reports the number of attempts and the last failure.
+	for _, want := range []string{"2 attempt(s)", "status code 503"} {
+		if !strings.Contains(err.Message, want) {
+			t.Errorf("Expected error message to contain %q, got %q.", want, err.Message)
+		}
+	}
+
+	if got := client.RetriesExhausted(); got != 1 {
+		t.Errorf("Expected 1 request with exhausted retries, got %d.", got)
+	}
+
+	// The exhausted retries are also reported to the metrics recorder.
+	if len(metrics.finished) != 1 || !metrics.finished[0].RetriesExhausted {
+		t.Errorf("Expected 1 request finished with exhausted retries, got %+v.", metrics.finished)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
+)
+
+const (
+	// DefaultRequestTimeout is the timeout of each attempt to send a request to
+	// the datasource if none is set.
+	DefaultRequestTimeout = 5 * time.Second
+)
+
+// contextKey is the type of the keys of the context values read by the adapter.
+type contextKey string
+
+// RequestTimeoutContextKey is the key of the optional context value holding the
+// timeout of each request sent to the datasource, as a time.Duration.
+// Embedders can set it with WithRequestTimeout, e.g. to use a different timeout
+// per entity. It is bounded by the timeout of the Datasource's HTTP client.
+const RequestTimeoutContextKey contextKey = "requestTimeout"
+
+// WithRequestTimeout returns a copy of ctx which carries the given timeout for
+// each request sent to the datasource.
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, RequestTimeoutContextKey, timeout)
+}
+
+// requestTimeout returns the timeout of the given request sent to the
+// datasource: the timeout carried by ctx, or else the request's configured
+// timeout, or else DefaultRequestTimeout, bounded by the HTTP client's timeout,
+// if any, and by the time remaining before the deadline of ctx, if any, so that
+// the caller's deadline is honored whether it is shorter or longer.
+func (d *Datasource) requestTimeout(ctx context.Context, request *Request) time.Duration {
+	timeout, ok := ctx.Value(RequestTimeoutContextKey).(time.Duration)
+
+	switch {
+	case ok && timeout > 0:
+	case request.RequestTimeout > 0:
+		timeout = request.RequestTimeout
+	default:
+		timeout = DefaultRequestTimeout
+	}
+
+	if d.Client.Timeout > 0 && timeout > d.Client.Timeout {
+		timeout = d.Client.Timeout
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+
+	return timeout
+}
+
+// abortedRequestError returns the error reporting that a request to the
+// datasource was aborted while performing the given action, because ctx was
+// cancelled or reached its deadline, or because the request timed out. Nil is
+// returned if neither happened, e.g. if the request failed with a transport
+// error instead.
+func abortedRequestError(ctx context.Context, timedOut bool, action string) *framework.Error {
+	var message string
+
+	switch {
+	case ctx.Err() == context.Canceled:
+		message = fmt.Sprintf("Request to datasource was cancelled while %s.", action)
+	case ctx.Err() != nil:
+		message = fmt.Sprintf("Request to datasource reached the caller's deadline while %s.", action)
+	case timedOut:
+		message = fmt.Sprintf("Request to datasource timed out while %s.", action)
+	default:
+		return nil
+	}
+
+	return &framework.Error{
+		Message: message,
+		Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INTERNAL,
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxIdleConns is the maximum number of idle connections kept open
+	// to all hosts if none is set in PoolConfig.
+	DefaultMaxIdleConns = 100
+
+	// DefaultMaxIdleConnsPerHost is the maximum number of idle connections
+	// kept open to each host if none is set in PoolConfig. It is higher than
+	// http.DefaultMaxIdleConnsPerHost, so that the connections of concurrent
+	// page requests to the datasource are reused rather than reopened.
+	DefaultMaxIdleConnsPerHost = 10
+
+	// DefaultIdleConnTimeout is the time after which idle connections are
+	// closed if none is set in PoolConfig.
+	DefaultIdleConnTimeout = 90 * time.Second
+
+	// maxCachedClients is the maximum number of HTTP clients cached by a
+	// Datasource, e.g. for as many proxies or sets of pinned certificates.
+	maxCachedClients = 100
+)
+
+// PoolConfig configures the pool of connections to the datasource of a Client
+// created with NewClientWithPool. Fields that are not set default to
+// DefaultMaxIdleConns, DefaultMaxIdleConnsPerHost and DefaultIdleConnTimeout.
+type PoolConfig struct {
+	// MaxIdleConns is the maximum number of idle connections to all hosts.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections to each
+	// host.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is the time after which idle connections are closed.
+	IdleConnTimeout time.Duration
+}
+
+// newPooledTransport returns a transport derived from http.DefaultTransport
+// with the given connection pool configuration.
+func newPooledTransport(pool PoolConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.MaxIdleConns = DefaultMaxIdleConns
+	if pool.MaxIdleConns > 0 {
+		transport.MaxIdleConns = pool.MaxIdleConns
+	}
+
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	if pool.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	}
+
+	transport.IdleConnTimeout = DefaultIdleConnTimeout
+	if pool.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = pool.IdleConnTimeout
+	}
+
+	return transport
+}
+
+// transportConfig is the request-specific configuration of the HTTP transport
+// used to send requests to the datasource.
+// It is used as a key to cache HTTP clients, so it must remain comparable.
+type transportConfig struct {
+	// pinnedSHA256 is the sorted, comma-separated list of normalized SHA-256
+	// fingerprints of the allowed leaf certificates.
+	pinnedSHA256 string
+
+	// disableKeepAlives disables the reuse of connections across requests.
+	disableKeepAlives bool
+
+	// proxyURL is the URL of the proxy through which requests are sent.
+	proxyURL string
+
+	// rootCAsPEM is the PEM-encoded bundle of the CA certificates trusted
+	// instead of the system's.
+	rootCAsPEM string
+}
+
+// newTransportConfig returns the transport configuration for the given request.
+func newTransportConfig(request *Request) transportConfig {
+	pins := make([]string, 0, len(request.TLSPinnedSHA256))
+
+	for _, pin := range request.TLSPinnedSHA256 {
+		pins = append(pins, normalizeFingerprint(pin))
+	}
+
+	sort.Strings(pins)
+
+	return transportConfig{
+		pinnedSHA256:      strings.Join(pins, ","),
+		disableKeepAlives: request.DisableKeepAlives,
+		proxyURL:          request.ProxyURL,
+		rootCAsPEM:        request.RootCAsPEM,
+	}
+}
+
+// httpClient returns the HTTP client to use to send the given request.
+// Requests that don't customize the transport are sent with d.Client, others
+// with a client derived from it which is cached per transport configuration.
+func (d *Datasource) httpClient(request *Request) (*http.Client, error) {
+	return d.cachedHTTPClient(newTransportConfig(request))
+}
+
+// tokenHTTPClient returns the HTTP client to use to send requests to the
+// request's OAuth2 token endpoint. The token endpoint may be on another host
+// than the datasource, so only the proxy and root CAs of the request apply,
+// not the pinned certificates of the datasource.
+func (d *Datasource) tokenHTTPClient(request *Request) (*http.Client, error) {
+	return d.cachedHTTPClient(transportConfig{
+		proxyURL:   request.ProxyURL,
+		rootCAsPEM: request.RootCAsPEM,
+	})
+}
+
+// cachedHTTPClient returns the HTTP client with the given transport
+// configuration, which is d.Client for the zero configuration.
+//
+// Once the cache holds maxCachedClients clients, any one of them is evicted
+// and its idle connections are closed. Requests in progress with the evicted
+// client are not affected.
+func (d *Datasource) cachedHTTPClient(config transportConfig) (*http.Client, error) {
+	if config == (transportConfig{}) {
+		return d.Client, nil
+	}
+
+	d.clientsMu.Lock()
+	defer d.clientsMu.Unlock()
+
+	if client, found := d.clients[config]; found {
+		return client, nil
+	}
+
+	var base *http.Transport
+
+	switch transport := d.Client.Transport.(type) {
+	case nil:
+		base = http.DefaultTransport.(*http.Transport)
+	case *http.Transport:
+		base = transport
+	default:
+		return nil, fmt.Errorf("transport of type %T cannot be customized", d.Client.Transport)
+	}
+
+	transport := base.Clone()
+
+	if config.pinnedSHA256 != "" {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+
+		// The pinned fingerprints replace CA-based trust: the chain and host name
+		// are not verified, only the fingerprint of the leaf certificate. Unlike
+		// VerifyPeerCertificate, VerifyConnection also runs on resumed sessions.
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		transport.TLSClientConfig.VerifyConnection = verifyPinnedCertificate(
+			strings.Split(config.pinnedSHA256, ","),
+		)
+	}
+
+	transport.DisableKeepAlives = transport.DisableKeepAlives || config.disableKeepAlives
+
+	if config.proxyURL != "" {
+		proxyURL, err := url.Parse(config.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy URL: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.rootCAsPEM != "" {
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM([]byte(config.rootCAsPEM)) {
+			return nil, errors.New("root CA bundle contains no valid PEM certificate")
+		}
+
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+
+		transport.TLSClientConfig.RootCAs = rootCAs
+	}
+
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: d.Client.CheckRedirect,
+		Jar:           d.Client.Jar,
+		Timeout:       d.Client.Timeout,
+	}
+
+	if d.clients == nil {
+		d.clients = make(map[transportConfig]*http.Client)
+	}
+
+	if len(d.clients) >= maxCachedClients {
+		for evicted, evictedClient := range d.clients {
+			evictedClient.CloseIdleConnections()
+			delete(d.clients, evicted)
+
+			break
+		}
+	}
+
+	d.clients[config] = client
+
+	return client, nil
+}
+
+// verifyPinnedCertificate returns a function for tls.Config.VerifyConnection
+// which rejects connections whose leaf certificate's SHA-256 fingerprint is not
+// one of the given normalized fingerprints.
+func verifyPinnedCertificate(pins []string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("datasource presented no TLS certificate")
+		}
+
+		sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		for _, pin := range pins {
+			if pin == fingerprint {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("datasource TLS certificate fingerprint %s is not pinned", fingerprint)
+	}
+}
+
+// normalizeFingerprint returns the given hex-encoded fingerprint in lower case
+// and without colon separators, e.g. "AB:CD:..." is normalized into "abcd...".
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+}
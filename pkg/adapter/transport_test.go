@@ -0,0 +1,337 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDatasourceGetPageTLSPinning(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	sum := sha256.Sum256(server.Certificate().Raw)
+	pin := fmt.Sprintf("%x", sum)
+
+	// The same fingerprint in the colon-separated upper case form of openssl.
+	var colonPin []string
+	for _, b := range sum {
+		colonPin = append(colonPin, fmt.Sprintf("%02X", b))
+	}
+
+	tests := map[string]struct {
+		pins        []string
+		wantErr     bool
+		wantMessage string
+	}{
+		"matching_pin": {
+			pins: []string{pin},
+		},
+		"matching_colon_separated_pin": {
+			pins: []string{strings.Join(colonPin, ":")},
+		},
+		"one_of_several_pins": {
+			pins: []string{strings.Repeat("0", 64), pin},
+		},
+		"mismatched_pin": {
+			pins:        []string{strings.Repeat("0", 64)},
+			wantErr:     true,
+			wantMessage: "is not pinned",
+		},
+		// Without pins, the test server's self-signed certificate is not
+		// trusted.
+		"no_pin": {
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := newTestDatasourceRequest(server)
+			request.TLSPinnedSHA256 = tt.pins
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Message, tt.wantMessage) {
+					t.Fatalf("Expected error %q, got response %+v, error %+v.", tt.wantMessage, response, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %v.", response.Objects)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageTLSPinningResumedSession(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	sum := sha256.Sum256(server.Certificate().Raw)
+
+	// The session cache is shared by the transports derived for each pin set.
+	client := &Datasource{Client: &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(8)},
+	}}}
+
+	request := newTestDatasourceRequest(server)
+	request.TLSPinnedSHA256 = []string{fmt.Sprintf("%x", sum)}
+
+	if _, err := client.GetPage(context.Background(), request); err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	// A session resumed with the cached ticket is still checked against the
+	// pins.
+	request.TLSPinnedSHA256 = []string{strings.Repeat("0", 64)}
+
+	response, err := client.GetPage(context.Background(), request)
+	if err == nil || !strings.Contains(err.Message, "is not pinned") {
+		t.Errorf("Expected the resumed session to be rejected, got response %+v, error %+v.", response, err)
+	}
+}
+
+func TestDatasourceHTTPClientDisableKeepAlives(t *testing.T) {
+	tests := map[string]struct {
+		disableKeepAlives bool
+		wantConnections   int
+	}{
+		"keep_alives_disabled": {
+			disableKeepAlives: true,
+			wantConnections:   3,
+		},
+		"keep_alives_enabled": {
+			wantConnections: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+
+			remoteAddrs := map[string]bool{}
+
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				remoteAddrs[r.RemoteAddr] = true
+				mu.Unlock()
+
+				w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+			})
+
+			datasource := NewClient(5).(*Datasource)
+
+			request := newTestDatasourceRequest(server)
+			request.DisableKeepAlives = tt.disableKeepAlives
+
+			client, err := datasource.httpClient(request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v.", err)
+			}
+
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Expected an *http.Transport, got %T.", client.Transport)
+			}
+
+			if transport.DisableKeepAlives != tt.disableKeepAlives {
+				t.Errorf("Expected DisableKeepAlives %v, got %v.", tt.disableKeepAlives, transport.DisableKeepAlives)
+			}
+
+			// The shared client is left untouched.
+			if datasource.Client.Transport.(*http.Transport).DisableKeepAlives {
+				t.Error("Expected the shared transport to keep connections alive.")
+			}
+
+			for i := 0; i < 3; i++ {
+				if _, err := datasource.GetPage(context.Background(), request); err != nil {
+					t.Fatalf("Expected no error, got %+v.", err)
+				}
+			}
+
+			if len(remoteAddrs) != tt.wantConnections {
+				t.Errorf("Expected %d connections, got %d.", tt.wantConnections, len(remoteAddrs))
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := map[string]struct {
+		rootCAsPEM  string
+		wantErr     bool
+		wantMessage string
+	}{
+		"trusted_root_ca": {
+			rootCAsPEM: serverCA,
+		},
+		// The test server's self-signed certificate is not trusted by the
+		// system's CAs.
+		"system_root_cas": {
+			wantErr: true,
+		},
+		"invalid_bundle": {
+			rootCAsPEM:  "not a certificate",
+			wantErr:     true,
+			wantMessage: "no valid PEM certificate",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := newTestDatasourceRequest(server)
+			request.RootCAsPEM = tt.rootCAsPEM
+
+			response, err := NewClient(5).GetPage(context.Background(), request)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Message, tt.wantMessage) {
+					t.Fatalf("Expected error %q, got response %+v, error %+v.", tt.wantMessage, response, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %+v.", err)
+			}
+
+			if len(response.Objects) != 1 {
+				t.Errorf("Expected 1 object, got %v.", response.Objects)
+			}
+		})
+	}
+}
+
+func TestDatasourceGetPageProxy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"teams":[{"id":"P1"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	var (
+		mu           sync.Mutex
+		connectHosts []string
+	)
+
+	// The proxy tunnels CONNECT requests to their target.
+	proxy := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			t.Errorf("Expected a CONNECT request, got %s %s.", r.Method, r.URL)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		mu.Lock()
+		connectHosts = append(connectHosts, r.Host)
+		mu.Unlock()
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+		defer target.Close()
+
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Failed to hijack the proxy connection: %v.", err)
+
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+		go io.Copy(target, conn)
+		io.Copy(conn, target)
+	})
+
+	request := newTestDatasourceRequest(server)
+	request.ProxyURL = proxy.URL
+	request.RootCAsPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	response, err := NewClient(5).GetPage(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got %+v.", err)
+	}
+
+	if len(response.Objects) != 1 {
+		t.Errorf("Expected 1 object, got %v.", response.Objects)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if want := []string{server.Listener.Addr().String()}; !reflect.DeepEqual(connectHosts, want) {
+		t.Errorf("Expected CONNECT requests to %v, got %v.", want, connectHosts)
+	}
+}
+
+func TestDatasourceHTTPClientCacheBound(t *testing.T) {
+	datasource := NewClient(5).(*Datasource)
+
+	request := &Request{}
+
+	for i := 0; i < maxCachedClients+10; i++ {
+		request.ProxyURL = fmt.Sprintf("http://proxy-%d.example.com:3128", i)
+
+		if _, err := datasource.httpClient(request); err != nil {
+			t.Fatalf("Expected no error, got %v.", err)
+		}
+	}
+
+	if len(datasource.clients) != maxCachedClients {
+		t.Errorf("Expected %d cached clients, got %d.", maxCachedClients, len(datasource.clients))
+	}
+
+	// The client just created is cached.
+	if _, found := datasource.clients[newTransportConfig(request)]; !found {
+		t.Error("Expected the most recent client to be cached.")
+	}
+}
@@ -16,6 +16,7 @@ package adapter
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	framework "github.com/sgnl-ai/adapter-framework"
 	api_adapter_v1 "github.com/sgnl-ai/adapter-framework/api/adapter/v1"
@@ -30,36 +31,71 @@ const (
 
 // ValidateGetPageRequest validates the fields of the GetPage Request.
 func (a *Adapter) ValidateGetPageRequest(ctx context.Context, request *framework.Request[Config]) *framework.Error {
-	fmt.Printf("DEBUG: Decoded Config: %+v\n", request.Config)
+	a.log().Debug("Validating GetPage request.",
+		"entity", request.Entity.ExternalId, "config", redactedConfig(request.Config))
 
 	if err := request.Config.Validate(ctx); err != nil {
 		return &framework.Error{
-			Message: fmt.Sprintf("Provided config is invalid: %v.", err.Error()),
+			Message: redact(fmt.Sprintf("Provided config is invalid: %v.", err.Error()), requestSecrets(request)...),
 			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
 		}
 	}
 
 	// SCAFFOLDING #8 - pkg/adapter/validation.go: Modify this validation to match the authn mechanism(s) supported by the SoR.
-	// Ensure that an API token is provided, as PagerDuty does not use basic auth.
-	if request.Auth == nil || request.Auth.HTTPAuthorization == "" {
-		return &framework.Error{
-			Message: "PagerDuty auth is missing required token.",
-			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+	// Ensure that the credentials of the configured auth mode are provided: an
+	// API token, as PagerDuty does not use basic auth, unless basic auth is
+	// configured for a compatible datasource. The token may be provided in the
+	// request's auth credentials or in the config, cf. authToken.
+	switch request.Config.AuthMode {
+	case AuthModeOAuth2ClientCredentials:
+		// With client credentials, the adapter obtains its own access tokens.
+	case AuthModeBasic:
+		if request.Auth == nil || request.Auth.Basic == nil ||
+			request.Auth.Basic.Username == "" || request.Auth.Basic.Password == "" {
+			return &framework.Error{
+				Message: "Basic auth is missing required username or password.",
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+			}
+		}
+	default:
+		if authToken(request) == "" {
+			return &framework.Error{
+				Message: "PagerDuty auth is missing required token.",
+				Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_DATASOURCE_CONFIG,
+			}
 		}
 	}
 
-	// Ensure that the expected external_id is valid by checking against the predefined valid entities.
-	if _, exists := ValidEntityExternalIDs[request.Entity.ExternalId]; !exists {
+	// Ensure that the expected external_id is valid by checking against the predefined valid entities,
+	// or the ad hoc entities if allowed.
+	entity, adHoc, exists := lookupEntity(request.Config, request.Entity.ExternalId)
+	if !exists {
 		return &framework.Error{
 			Message: fmt.Sprintf("Invalid entity external ID: %s", request.Entity.ExternalId),
 			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
 		}
 	}
 
+	if adHoc {
+		a.log().Warn("Querying ad hoc entity which is not supported by the adapter.",
+			"entity", request.Entity.ExternalId, "endpoint", entity.endpoint)
+	}
+
+	// Ensure that the entity exists in the configured API version.
+	if versions := entityAPIVersions(request.Config, request.Entity.ExternalId); !versions.contains(
+		request.Config.APIVersion,
+	) {
+		return &framework.Error{
+			Message: fmt.Sprintf("Entity %s is not supported in API version %s, it requires %s.",
+				request.Entity.ExternalId, request.Config.APIVersion, versions),
+			Code: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		}
+	}
+
 	// Validate that at least the unique ID attribute for the requested entity is requested.
 	var uniqueIDAttributeFound bool
 	for _, attribute := range request.Entity.Attributes {
-		if attribute.ExternalId == "id" { // The unique identifier for PagerDuty teams is 'id'.
+		if attribute.ExternalId == entity.uniqueIDAttrExternalID {
 			uniqueIDAttributeFound = true
 			break
 		}
@@ -67,26 +103,54 @@ func (a *Adapter) ValidateGetPageRequest(ctx context.Context, request *framework
 
 	if !uniqueIDAttributeFound {
 		return &framework.Error{
-			Message: "Requested entity attributes are missing unique ID attribute ('id').",
-			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+			Message: fmt.Sprintf("Requested entity attributes are missing unique ID attribute ('%s').",
+				entity.uniqueIDAttrExternalID),
+			Code: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+		}
+	}
+
+	// Validate that the requested attributes exist in the entity's objects, if
+	// enabled and the entity declares its fields.
+	if request.Config.ValidateAttributes && entity.knownAttributes != nil {
+		for _, attribute := range request.Entity.Attributes {
+			if _, known := entity.knownAttributes[attributeRootField(attribute.ExternalId)]; !known {
+				return &framework.Error{
+					Message: fmt.Sprintf("Requested attribute %s is not a known attribute of entity %s.",
+						attribute.ExternalId, request.Entity.ExternalId),
+					Code: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+				}
+			}
 		}
 	}
 
-	// Validate that no child entities are requested.
+	// Validate that only the entity's child entities are requested, and that
+	// they have no child entities themselves.
 	//
 	// SCAFFOLDING #9 - pkg/adapter/validation.go: Modify this validation if the entity contains child entities.
-	if len(request.Entity.ChildEntities) > 0 {
-		return &framework.Error{
-			Message: "Requested entity does not support child entities.",
-			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+	for _, childEntity := range request.Entity.ChildEntities {
+		if _, found := entity.childEntities[childEntity.ExternalId]; !found {
+			return &framework.Error{
+				Message: fmt.Sprintf("Requested entity %s does not support child entity %s.",
+					request.Entity.ExternalId, childEntity.ExternalId),
+				Code: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+			}
+		}
+
+		if len(childEntity.ChildEntities) > 0 {
+			return &framework.Error{
+				Message: fmt.Sprintf("Requested child entity %s does not support child entities.",
+					childEntity.ExternalId),
+				Code: api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
+			}
 		}
 	}
 
 	// SCAFFOLDING #10 - pkg/adapter/validation.go: Check for Ordered responses.
-	// PagerDuty does not enforce ordered responses, so Ordered can be false.
-	if request.Ordered {
+	// PagerDuty does not order responses by default, so Ordered can only be
+	// true if a sort order is configured.
+	if request.Ordered && request.Config.SortBy == "" {
 		return &framework.Error{
-			Message: "Ordered must be set to false for PagerDuty API.",
+			Message: "Ordered must be set to false for PagerDuty API unless sortBy is configured.",
 			Code:    api_adapter_v1.ErrorCode_ERROR_CODE_INVALID_ENTITY_CONFIG,
 		}
 	}
@@ -100,3 +164,16 @@ func (a *Adapter) ValidateGetPageRequest(ctx context.Context, request *framework
 
 	return nil
 }
+
+// attributeRootField returns the top-level field of the objects designated by
+// the given attribute external ID, which may be a JSONPath, e.g. "teams" for
+// "$.teams[0].id".
+func attributeRootField(externalID string) string {
+	field := strings.TrimPrefix(strings.TrimPrefix(externalID, "$"), ".")
+
+	if end := strings.IndexAny(field, ".["); end >= 0 {
+		field = field[:end]
+	}
+
+	return field
+}
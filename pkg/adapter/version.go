@@ -0,0 +1,150 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"strconv"
+	"strings"
+
+	framework "github.com/sgnl-ai/adapter-framework"
+)
+
+// compareAPIVersions compares two API versions of the form "v1", "2" or
+// "v2.1", component by component, and returns -1, 0 or +1 if a is lower than,
+// equal to, or greater than b. Missing components are considered to be 0, and
+// non-numeric components are compared lexicographically.
+func compareAPIVersions(a, b string) int {
+	aComponents := strings.Split(strings.TrimPrefix(strings.ToLower(a), "v"), ".")
+	bComponents := strings.Split(strings.TrimPrefix(strings.ToLower(b), "v"), ".")
+
+	for i := 0; i < len(aComponents) || i < len(bComponents); i++ {
+		aComponent, bComponent := "0", "0"
+
+		if i < len(aComponents) {
+			aComponent = aComponents[i]
+		}
+
+		if i < len(bComponents) {
+			bComponent = bComponents[i]
+		}
+
+		aNumber, aErr := strconv.Atoi(aComponent)
+		bNumber, bErr := strconv.Atoi(bComponent)
+
+		switch {
+		case aErr == nil && bErr == nil && aNumber < bNumber:
+			return -1
+		case aErr == nil && bErr == nil && aNumber > bNumber:
+			return 1
+		case (aErr != nil || bErr != nil) && aComponent < bComponent:
+			return -1
+		case (aErr != nil || bErr != nil) && aComponent > bComponent:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// APIVersionRange is the range of API versions in which an entity exists.
+type APIVersionRange struct {
+	// Min is the lowest API version supporting the entity.
+	// Optional. If not set, the entity exists in all versions up to Max.
+	Min string `json:"min,omitempty"`
+
+	// Max is the highest API version supporting the entity.
+	// Optional. If not set, the entity exists in all versions from Min.
+	Max string `json:"max,omitempty"`
+}
+
+// contains returns whether the given API version is within the range.
+func (r APIVersionRange) contains(apiVersion string) bool {
+	if r.Min != "" && compareAPIVersions(apiVersion, r.Min) < 0 {
+		return false
+	}
+
+	if r.Max != "" && compareAPIVersions(apiVersion, r.Max) > 0 {
+		return false
+	}
+
+	return true
+}
+
+// String returns the range in a human-readable form, e.g. "v2 to v3".
+func (r APIVersionRange) String() string {
+	switch {
+	case r.Min != "" && r.Max != "":
+		return r.Min + " to " + r.Max
+	case r.Min != "":
+		return r.Min + " or later"
+	case r.Max != "":
+		return r.Max + " or earlier"
+	default:
+		return "any version"
+	}
+}
+
+// entityAPIVersions returns the range of API versions supporting the given
+// entity, as configured in the given config, or else as built into the adapter.
+func entityAPIVersions(config *Config, entityExternalID string) APIVersionRange {
+	if versions, found := config.EntityAPIVersions[entityExternalID]; found {
+		return versions
+	}
+
+	return ValidEntityExternalIDs[entityExternalID].apiVersions
+}
+
+// attributeMinAPIVersions returns the minimum API versions of the given
+// entity's attributes, as configured in the given config, or else as built into
+// the adapter.
+func attributeMinAPIVersions(config *Config, entityExternalID string) map[string]string {
+	if versions, found := config.AttributeMinAPIVersions[entityExternalID]; found {
+		return versions
+	}
+
+	return ValidEntityExternalIDs[entityExternalID].attributeMinAPIVersions
+}
+
+// supportedAttributes returns the given entity's requested attributes, without
+// those that require a newer API version than the configured one.
+// A warning is logged for each dropped attribute.
+func supportedAttributes(
+	entity *framework.EntityConfig, config *Config, logger Logger,
+) []*framework.AttributeConfig {
+	apiVersion := config.APIVersion
+
+	minVersions := attributeMinAPIVersions(config, entity.ExternalId)
+	if len(minVersions) == 0 {
+		return entity.Attributes
+	}
+
+	attributes := make([]*framework.AttributeConfig, 0, len(entity.Attributes))
+
+	for _, attribute := range entity.Attributes {
+		minVersion, found := minVersions[attribute.ExternalId]
+
+		if found && compareAPIVersions(apiVersion, minVersion) < 0 {
+			logger.Warn("Skipping attribute which requires a newer API version.",
+				"entity", entity.ExternalId, "attribute", attribute.ExternalId,
+				"minApiVersion", minVersion, "apiVersion", apiVersion)
+
+			continue
+		}
+
+		attributes = append(attributes, attribute)
+	}
+
+	return attributes
+}
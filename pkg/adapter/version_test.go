@@ -0,0 +1,44 @@
+// Copyright 2023 SGNL.ai, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"testing"
+)
+
+func TestCompareAPIVersions(t *testing.T) {
+	tests := map[string]struct {
+		a, b string
+		want int
+	}{
+		"equal":                {a: "v2", b: "v2", want: 0},
+		"without_prefix":       {a: "2", b: "v2", want: 0},
+		"uppercase_prefix":     {a: "V3", b: "v2", want: 1},
+		"lower":                {a: "v1", b: "v2", want: -1},
+		"numeric_components":   {a: "v10", b: "v9", want: 1},
+		"minor_version":        {a: "v2.1", b: "v2", want: 1},
+		"missing_component":    {a: "v2.0", b: "v2", want: 0},
+		"non_numeric_suffix":   {a: "v2.beta", b: "v2.alpha", want: 1},
+		"non_numeric_vs_digit": {a: "v2.1", b: "v2.beta", want: -1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := compareAPIVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("Expected %d comparing %q to %q, got %d.", tt.want, tt.a, tt.b, got)
+			}
+		})
+	}
+}